@@ -0,0 +1,449 @@
+// Package ast defines the syntax tree built by the parse package.
+package ast
+
+import "lol/scan"
+
+// Node is any node of the syntax tree. Start and End are the byte offsets
+// of the node in the source, so source[node.Start():node.End()] is the text
+// it was parsed from.
+type Node interface {
+	Start() int
+	End() int
+}
+
+type Expr interface {
+	Node
+	expr()
+}
+
+type Stmt interface {
+	Node
+	stmt()
+}
+
+// TypeExpr is a type as written in a declaration.
+type TypeExpr interface {
+	Node
+	typeExpr()
+}
+
+// Pattern is a pattern of a match arm.
+type Pattern interface {
+	Node
+	pattern()
+}
+
+// Decl is a statement that declares a named type or function. Declarations
+// can appear wherever statements can.
+type Decl interface {
+	Stmt
+	decl()
+}
+
+// CommentGroup is a run of comments with no other tokens between them,
+// such as the doc comments of a declaration.
+type CommentGroup struct {
+	List []scan.Token
+}
+
+// Program is a whole source file. Comments holds all of its comments in
+// source order, if the scanner kept them; NewCommentMap tells which node
+// each belongs to.
+type Program struct {
+	Stmts    []Stmt
+	EOF      scan.Token
+	Comments []*CommentGroup
+}
+
+// Expressions
+
+// Ident is a name used in an expression. Obj is the object it refers to,
+// once the resolve package has bound it.
+type Ident struct {
+	Token scan.Token
+	Obj   *Object `json:"-"`
+}
+
+type NumberLit struct {
+	Token scan.Token
+	Value float64
+}
+
+// StringLit is a quoted or raw string without interpolations, or the text
+// between the interpolations of an InterpolatedString.
+type StringLit struct {
+	Token scan.Token
+	Value string
+}
+
+// InterpolatedString is a string with "${...}" parts. Parts alternates
+// between the text segments, as *StringLit, and the interpolated
+// expressions, starting and ending with a segment.
+type InterpolatedString struct {
+	Parts []Expr
+}
+
+type CharLit struct {
+	Token scan.Token
+	Value rune
+}
+
+type BoolLit struct {
+	Token scan.Token
+	Value bool
+}
+
+type UnaryExpr struct {
+	Operator scan.Token
+	Operand  Expr
+}
+
+// PostfixExpr is an increment or decrement written after its operand.
+type PostfixExpr struct {
+	Operand  Expr
+	Operator scan.Token
+}
+
+type BinaryExpr struct {
+	Left     Expr
+	Operator scan.Token
+	Right    Expr
+}
+
+// AssignExpr is a plain or compound assignment such as "x = 1" or "x += 1".
+type AssignExpr struct {
+	Target   Expr
+	Operator scan.Token
+	Value    Expr
+}
+
+type CallExpr struct {
+	Callee Expr
+	Args   []Expr
+	Close  scan.Token
+}
+
+// FieldExpr selects a field or method, as in "point.x".
+type FieldExpr struct {
+	Object Expr
+	Name   scan.Token
+}
+
+type Grouping struct {
+	Open  scan.Token
+	Expr  Expr
+	Close scan.Token
+}
+
+// BadExpr stands in for an expression with a syntax error. From and To are
+// the byte offsets of the text it replaces.
+type BadExpr struct {
+	From int
+	To   int
+}
+
+// ArrayLit is an array literal: "[1, 2, 3]".
+type ArrayLit struct {
+	Open  scan.Token
+	Elems []Expr
+	Close scan.Token
+}
+
+// MapLit is a map literal: "{"a": 1, "b": 2}".
+type MapLit struct {
+	Open    scan.Token
+	Entries []*KeyValue
+	Close   scan.Token
+}
+
+// KeyValue is an entry of a map literal.
+type KeyValue struct {
+	Key   Expr
+	Value Expr
+}
+
+// IndexExpr is an element of an array or map: "xs[0]".
+type IndexExpr struct {
+	Object Expr
+	Open   scan.Token
+	Index  Expr
+	Close  scan.Token
+}
+
+// SliceExpr is a part of an array: "xs[low:high]". Low and High are nil
+// when left out, meaning the start and the end.
+type SliceExpr struct {
+	Object Expr
+	Open   scan.Token
+	Low    Expr
+	High   Expr
+	Close  scan.Token
+}
+
+// InstanceExpr gives the type arguments of a generic function, as in the
+// callee of "f<int>(x)".
+type InstanceExpr struct {
+	Object   Expr
+	Open     scan.Token
+	TypeArgs []TypeExpr
+	Close    scan.Token
+}
+
+// MatchExpr evaluates to the body of the first arm with a pattern that
+// matches Value. The last arm is the else arm, which matches anything.
+type MatchExpr struct {
+	Match scan.Token
+	Value Expr
+	Arms  []*MatchArm
+	Close scan.Token
+}
+
+// MatchArm is an arm of a match: "1 | 2 => x" or "else => y". Patterns is
+// empty for the else arm, which has its keyword in Else.
+type MatchArm struct {
+	Patterns []Pattern
+	Else     scan.Token
+	Body     Expr
+}
+
+func (*Ident) expr()              {}
+func (*NumberLit) expr()          {}
+func (*StringLit) expr()          {}
+func (*InterpolatedString) expr() {}
+func (*CharLit) expr()            {}
+func (*BoolLit) expr()            {}
+func (*UnaryExpr) expr()          {}
+func (*PostfixExpr) expr()        {}
+func (*BinaryExpr) expr()         {}
+func (*AssignExpr) expr()         {}
+func (*CallExpr) expr()           {}
+func (*FieldExpr) expr()          {}
+func (*Grouping) expr()           {}
+func (*BadExpr) expr()            {}
+func (*ArrayLit) expr()           {}
+func (*MapLit) expr()             {}
+func (*IndexExpr) expr()          {}
+func (*SliceExpr) expr()          {}
+func (*InstanceExpr) expr()       {}
+func (*MatchExpr) expr()          {}
+
+// Patterns
+
+// LiteralPattern matches values equal to a literal, which may be a negated
+// number.
+type LiteralPattern struct {
+	Value Expr
+}
+
+// RangePattern matches the values from Low to High, both included: "1..9".
+type RangePattern struct {
+	Low      Expr
+	Operator scan.Token
+	High     Expr
+}
+
+// BindingPattern matches any value and binds it to Name.
+type BindingPattern struct {
+	Name scan.Token
+}
+
+// StructPattern matches a struct of type Name whose fields match the field
+// patterns: "Point { x: 0, y }". Fields that are not named can have any
+// value.
+type StructPattern struct {
+	Name   scan.Token
+	Open   scan.Token
+	Fields []*FieldPattern
+	Close  scan.Token
+	Obj    *Object `json:"-"`
+}
+
+// FieldPattern matches a field of a struct. Without a Pattern, as in
+// "Point { y }", it matches any value and binds it to a variable named
+// like the field.
+type FieldPattern struct {
+	Name    scan.Token
+	Pattern Pattern
+}
+
+func (*LiteralPattern) pattern() {}
+func (*RangePattern) pattern()   {}
+func (*BindingPattern) pattern() {}
+func (*StructPattern) pattern()  {}
+
+// Types
+
+// TypeName names a type: one of the built-in type keywords or a struct.
+type TypeName struct {
+	Name scan.Token
+	Obj  *Object `json:"-"`
+}
+
+// ArrayType is the type of arrays of Elem: "[int]".
+type ArrayType struct {
+	Open  scan.Token
+	Elem  TypeExpr
+	Close scan.Token
+}
+
+// MapType is the type of maps from Key to Value: "[string: int]".
+type MapType struct {
+	Open  scan.Token
+	Key   TypeExpr
+	Value TypeExpr
+	Close scan.Token
+}
+
+// GenericType is a generic type with its type arguments: "List<int>".
+type GenericType struct {
+	Name  scan.Token
+	Open  scan.Token
+	Args  []TypeExpr
+	Close scan.Token
+	Obj   *Object `json:"-"`
+}
+
+// FuncType is the type of functions: "fn(int, int) -> bool". Result is nil
+// for functions that return nothing.
+type FuncType struct {
+	Fn     scan.Token
+	Params []TypeExpr
+	Close  scan.Token
+	Result TypeExpr
+}
+
+// TypeParam is a type parameter of a generic struct or function.
+// Constraint is the type it must satisfy, as in "<T: Ord>", or nil.
+type TypeParam struct {
+	Name       scan.Token
+	Constraint TypeExpr
+}
+
+func (*TypeName) typeExpr()    {}
+func (*ArrayType) typeExpr()   {}
+func (*MapType) typeExpr()     {}
+func (*GenericType) typeExpr() {}
+func (*FuncType) typeExpr()    {}
+
+// Statements
+
+// LetStmt declares a variable, optionally with its type: "let x: int = 1".
+type LetStmt struct {
+	Let   scan.Token
+	Name  scan.Token
+	Type  TypeExpr
+	Value Expr
+}
+
+// IfStmt is an if statement. Else is nil, a *Block or, for "else if", an
+// *IfStmt.
+type IfStmt struct {
+	If        scan.Token
+	Condition Expr
+	Then      *Block
+	Else      Stmt
+}
+
+// ForInStmt loops over the elements of Iterable: "for x in xs { ... }".
+type ForInStmt struct {
+	For      scan.Token
+	Var      scan.Token
+	Iterable Expr
+	Body     *Block
+}
+
+// WhileStmt runs Body for as long as Condition holds.
+type WhileStmt struct {
+	While     scan.Token
+	Condition Expr
+	Body      *Block
+}
+
+// BranchStmt is a "break" or "continue" of the innermost loop.
+type BranchStmt struct {
+	Keyword scan.Token
+}
+
+// StructDecl declares a struct type with named, typed fields. Its methods
+// are declared apart from it, as FuncDecls with a receiver. TypeParams is
+// empty unless the struct is generic.
+type StructDecl struct {
+	Doc        *CommentGroup
+	Struct     scan.Token
+	Name       scan.Token
+	TypeParams []*TypeParam
+	Fields     []*Field
+	Close      scan.Token
+}
+
+// Field is a field of a struct. Default is the value the field has when it
+// is not given one, or nil.
+type Field struct {
+	Doc     *CommentGroup
+	Name    scan.Token
+	Type    TypeExpr
+	Default Expr
+}
+
+// FuncDecl declares a function, "fn add(a: int, b: int) -> int { ... }",
+// or, with a receiver, a method, "fn (p: Point) dist() -> float { ... }".
+// Recv is nil for a function, TypeParams is empty unless it is generic and
+// Result is nil if it returns nothing.
+type FuncDecl struct {
+	Doc        *CommentGroup
+	Fn         scan.Token
+	Recv       *Param
+	Name       scan.Token
+	TypeParams []*TypeParam
+	Params     []*Param
+	Result     TypeExpr
+	Body       *Block
+}
+
+// Param is a parameter of a function. A variadic parameter, written
+// "xs: ...int", takes the remaining arguments and has to come last. Default
+// is the value of a parameter that can be left out, or nil; the ones after
+// it must have a default as well.
+type Param struct {
+	Name     scan.Token
+	Variadic bool
+	Type     TypeExpr
+	Default  Expr
+}
+
+type ReturnStmt struct {
+	Return scan.Token
+	Value  Expr
+}
+
+type Block struct {
+	Open  scan.Token
+	Stmts []Stmt
+	Close scan.Token
+}
+
+type ExprStmt struct {
+	Expr Expr
+}
+
+// BadStmt stands in for a statement with a syntax error. From and To are
+// the byte offsets of the text the parser skipped over.
+type BadStmt struct {
+	From int
+	To   int
+}
+
+func (*LetStmt) stmt()    {}
+func (*IfStmt) stmt()     {}
+func (*ForInStmt) stmt()  {}
+func (*WhileStmt) stmt()  {}
+func (*BranchStmt) stmt() {}
+func (*StructDecl) stmt() {}
+func (*FuncDecl) stmt()   {}
+func (*ReturnStmt) stmt() {}
+func (*Block) stmt()      {}
+func (*ExprStmt) stmt()   {}
+func (*BadStmt) stmt()    {}
+
+func (*StructDecl) decl() {}
+func (*FuncDecl) decl()   {}
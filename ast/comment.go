@@ -0,0 +1,36 @@
+package ast
+
+import "strings"
+
+// Text returns the text of the comments without their comment markers. A
+// line comment gives one line and a block comment one line per line of it,
+// with the "*" that may start each of those removed. Blank lines at the
+// start and end are dropped.
+func (group *CommentGroup) Text() string {
+	if group == nil {
+		return ""
+	}
+	lines := make([]string, 0, len(group.List))
+	for _, comment := range group.List {
+		text := comment.Text
+		if strings.HasPrefix(text, "//") {
+			text = strings.TrimLeft(text, "/")
+			lines = append(lines, strings.TrimPrefix(text, " "))
+			continue
+		}
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/"), "*/")
+		text = strings.TrimLeft(text, "*")
+		for line := range strings.Lines(text) {
+			line = strings.TrimSpace(line)
+			line = strings.TrimPrefix(line, "*")
+			lines = append(lines, strings.TrimPrefix(line, " "))
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,124 @@
+package ast
+
+import (
+	"slices"
+	"sort"
+)
+
+// CommentMap holds the comment groups that belong to each node, in source
+// order. Only statements, declarations and struct fields have comments of
+// their own; the other comments belong to the block, struct or program
+// they are written in.
+type CommentMap map[Node][]*CommentGroup
+
+// NewCommentMap tells, for each of comments, the node below root it
+// belongs to. source is the text the tree was parsed from. A comment group
+//
+//   - that starts on the line a node ends on, after it, trails that node;
+//   - that starts on the line after a node and is separated from the next
+//     node by a blank line also belongs to that node;
+//   - and otherwise leads the next node of its block.
+//
+// A group with no node after it in its block belongs to the block.
+func NewCommentMap(source string, root Node, comments []*CommentGroup) CommentMap {
+	cmap := make(CommentMap)
+	if len(comments) == 0 {
+		return cmap
+	}
+	starts := []int{0}
+	for i, c := range source {
+		if c == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	line := func(offset int) int {
+		return sort.Search(len(starts), func(i int) bool { return starts[i] > offset })
+	}
+
+	// The nodes that can have comments, in preorder and so by start, and
+	// the nodes that hold them.
+	var nodes, scopes []Node
+	Inspect(root, func(node Node) bool {
+		switch node.(type) {
+		case *Program, *Block, *StructDecl:
+			scopes = append(scopes, node)
+		}
+		switch node.(type) {
+		case *Block, *Program, nil:
+		case Stmt, *Field:
+			nodes = append(nodes, node)
+		}
+		return true
+	})
+	if len(scopes) == 0 || scopes[0] != root {
+		scopes = append([]Node{root}, scopes...)
+	}
+	// byEnd holds the nodes by end, the outer one first where several end
+	// at the same offset.
+	byEnd := slices.Clone(nodes)
+	slices.SortStableFunc(byEnd, func(a, b Node) int { return a.End() - b.End() })
+
+	within := func(node, scope Node) bool {
+		return node != nil && scope.Start() <= node.Start() && node.End() <= scope.End()
+	}
+	for _, group := range comments {
+		start, end := group.Start(), group.End()
+		scope := root
+		for _, s := range scopes {
+			if s.Start() <= start && end <= s.End() {
+				scope = s
+			}
+		}
+		var prev, next Node
+		if i := sort.Search(len(byEnd), func(i int) bool { return byEnd[i].End() > start }); i > 0 {
+			i--
+			for i > 0 && byEnd[i-1].End() == byEnd[i].End() {
+				i--
+			}
+			prev = byEnd[i]
+		}
+		if i := sort.Search(len(nodes), func(i int) bool { return nodes[i].Start() >= end }); i < len(nodes) {
+			next = nodes[i]
+		}
+		if !within(prev, scope) {
+			prev = nil
+		}
+		if !within(next, scope) {
+			next = nil
+		}
+
+		owner := scope
+		switch {
+		case prev != nil && line(prev.End()) == line(start):
+			owner = prev
+		case prev != nil && line(prev.End())+1 == line(start) && next != nil && line(next.Start()) > line(end)+1:
+			owner = prev
+		case next != nil:
+			owner = next
+		}
+		cmap[owner] = append(cmap[owner], group)
+	}
+	return cmap
+}
+
+// Comments returns all comment groups of the map in source order.
+func (cmap CommentMap) Comments() []*CommentGroup {
+	var comments []*CommentGroup
+	for _, groups := range cmap {
+		comments = append(comments, groups...)
+	}
+	slices.SortFunc(comments, func(a, b *CommentGroup) int { return a.Start() - b.Start() })
+	return comments
+}
+
+// Filter returns the part of the map for node and the nodes below it.
+func (cmap CommentMap) Filter(node Node) CommentMap {
+	filtered := make(CommentMap)
+	Inspect(node, func(node Node) bool {
+		if groups, ok := cmap[node]; ok {
+			filtered[node] = groups
+		}
+		return true
+	})
+	return filtered
+}
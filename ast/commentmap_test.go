@@ -0,0 +1,78 @@
+package ast_test
+
+import (
+	"lol/ast"
+	"lol/parse"
+	"lol/scan"
+	"testing"
+)
+
+const commentSource = `// leads a
+let a = 1 // trails a
+
+let b = 2
+// after b, before the blank line
+
+{
+	// leads c
+	let c = 3
+	// ends the block
+}
+// ends the program
+`
+
+func TestCommentMap(t *testing.T) {
+	options := scan.DefaultOptions()
+	options.KeepComments = true
+	scanner := scan.NewScannerWithOptions(commentSource, options)
+	tokens, _ := scanner.Scan()
+	parser := parse.NewParser(tokens)
+	program, errors := parser.ParseProgram()
+	if len(errors) > 0 {
+		t.Fatalf("errors: %v", errors)
+	}
+	if len(program.Comments) != 6 {
+		t.Fatalf("program has %d comment groups, want 6", len(program.Comments))
+	}
+
+	a, b := program.Stmts[0], program.Stmts[1]
+	block := program.Stmts[2].(*ast.Block)
+	c := block.Stmts[0]
+	owners := []ast.Node{a, a, b, c, block, program}
+	cmap := ast.NewCommentMap(commentSource, program, program.Comments)
+	for i, group := range program.Comments {
+		owner := owners[i]
+		found := false
+		for _, g := range cmap[owner] {
+			found = found || g == group
+		}
+		if !found {
+			t.Errorf("comment %q does not belong to %s", group.Text(), ast.Sexpr(owner))
+		}
+	}
+	if len(cmap) != 5 {
+		t.Errorf("%d nodes have comments, want 5", len(cmap))
+	}
+
+	comments := cmap.Comments()
+	if len(comments) != len(program.Comments) {
+		t.Fatalf("Comments() returns %d groups, want %d", len(comments), len(program.Comments))
+	}
+	for i := range comments {
+		if comments[i] != program.Comments[i] {
+			t.Errorf("Comments()[%d] = %q, want %q", i, comments[i].Text(), program.Comments[i].Text())
+		}
+	}
+
+	filtered := cmap.Filter(block)
+	if len(filtered) != 2 || len(filtered[c]) != 1 || len(filtered[block]) != 1 {
+		t.Errorf("Filter(block) = %v, want the comments of c and of the block", filtered)
+	}
+}
+
+func TestCommentMapEmpty(t *testing.T) {
+	program := parseProgram(t, "let a = 1")
+	if cmap := ast.NewCommentMap("let a = 1", program, nil); len(cmap) != 0 {
+		t.Errorf("NewCommentMap without comments = %v, want an empty map", cmap)
+	}
+}
@@ -0,0 +1,239 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// ToJSON encodes node and everything below it as JSON. Every node becomes
+// an object whose "kind" is the name of its Go type, such as "BinaryExpr",
+// followed by its "start" and "end" offsets and then its fields, named like
+// the Go fields in lower camel case:
+//
+//	{"kind":"Ident","start":0,"end":1,"token":{"type":"Identifier",...}}
+//
+// Tokens are encoded as by scan.TokensToJSON, so literal values are in the
+// "value" field of the literal and also in the text of its token. Lists of
+// nodes are arrays, and missing nodes and lists that were never made, such
+// as the type parameters of a struct that is not generic, are null. The
+// objects the resolve package binds names to are left out.
+func ToJSON(node Node) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := encode(&buffer, reflect.ValueOf(node)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// FromJSON decodes a tree encoded by ToJSON. The "start" and "end" of the
+// nodes are not read back, as the nodes compute them from their tokens.
+func FromJSON(data []byte) (Node, error) {
+	value, err := decode(data, nodeType)
+	if err != nil {
+		return nil, err
+	}
+	node, _ := value.Interface().(Node)
+	return node, nil
+}
+
+var nodeType = reflect.TypeFor[Node]()
+
+// kinds holds the type of every node by its kind.
+var kinds = func() map[string]reflect.Type {
+	nodes := []Node{
+		(*CommentGroup)(nil),
+		(*Program)(nil),
+		(*Ident)(nil),
+		(*NumberLit)(nil),
+		(*StringLit)(nil),
+		(*InterpolatedString)(nil),
+		(*CharLit)(nil),
+		(*BoolLit)(nil),
+		(*UnaryExpr)(nil),
+		(*PostfixExpr)(nil),
+		(*BinaryExpr)(nil),
+		(*AssignExpr)(nil),
+		(*CallExpr)(nil),
+		(*FieldExpr)(nil),
+		(*Grouping)(nil),
+		(*BadExpr)(nil),
+		(*ArrayLit)(nil),
+		(*MapLit)(nil),
+		(*KeyValue)(nil),
+		(*IndexExpr)(nil),
+		(*SliceExpr)(nil),
+		(*InstanceExpr)(nil),
+		(*MatchExpr)(nil),
+		(*MatchArm)(nil),
+		(*LiteralPattern)(nil),
+		(*RangePattern)(nil),
+		(*BindingPattern)(nil),
+		(*StructPattern)(nil),
+		(*FieldPattern)(nil),
+		(*TypeName)(nil),
+		(*ArrayType)(nil),
+		(*MapType)(nil),
+		(*GenericType)(nil),
+		(*FuncType)(nil),
+		(*TypeParam)(nil),
+		(*LetStmt)(nil),
+		(*IfStmt)(nil),
+		(*ForInStmt)(nil),
+		(*WhileStmt)(nil),
+		(*BranchStmt)(nil),
+		(*StructDecl)(nil),
+		(*Field)(nil),
+		(*FuncDecl)(nil),
+		(*Param)(nil),
+		(*ReturnStmt)(nil),
+		(*Block)(nil),
+		(*ExprStmt)(nil),
+		(*BadStmt)(nil),
+	}
+	kinds := make(map[string]reflect.Type, len(nodes))
+	for _, node := range nodes {
+		typ := reflect.TypeOf(node)
+		kinds[typ.Elem().Name()] = typ
+	}
+	return kinds
+}()
+
+// isNodes reports whether typ is a node type or a slice of them.
+func isNodes(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	return typ.Implements(nodeType)
+}
+
+// fieldName returns the JSON name of a node field: "Operator" is
+// "operator" and "EOF" is "eof".
+func fieldName(name string) string {
+	if strings.ToUpper(name) == name {
+		return strings.ToLower(name)
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+func encode(buffer *bytes.Buffer, value reflect.Value) error {
+	if !value.IsValid() {
+		buffer.WriteString("null")
+		return nil
+	}
+	if !isNodes(value.Type()) {
+		data, err := json.Marshal(value.Interface())
+		buffer.Write(data)
+		return err
+	}
+	switch value.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if value.IsNil() {
+			buffer.WriteString("null")
+			return nil
+		}
+		if value.Kind() == reflect.Interface {
+			return encode(buffer, value.Elem())
+		}
+		return encodeNode(buffer, value)
+	default:
+		if value.IsNil() {
+			buffer.WriteString("null")
+			return nil
+		}
+		buffer.WriteByte('[')
+		for i := range value.Len() {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := encode(buffer, value.Index(i)); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+		return nil
+	}
+}
+
+func encodeNode(buffer *bytes.Buffer, value reflect.Value) error {
+	node := value.Interface().(Node)
+	fmt.Fprintf(buffer, `{"kind":%q,"start":%d,"end":%d`, value.Type().Elem().Name(), node.Start(), node.End())
+	fields := value.Elem()
+	for i := range fields.NumField() {
+		field := fields.Type().Field(i)
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+		fmt.Fprintf(buffer, `,%q:`, fieldName(field.Name))
+		if err := encode(buffer, fields.Field(i)); err != nil {
+			return err
+		}
+	}
+	buffer.WriteByte('}')
+	return nil
+}
+
+// decode decodes data as a value of type typ.
+func decode(data []byte, typ reflect.Type) (reflect.Value, error) {
+	if !isNodes(typ) {
+		value := reflect.New(typ)
+		err := json.Unmarshal(data, value.Interface())
+		return value.Elem(), err
+	}
+	if typ.Kind() == reflect.Slice {
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil || elems == nil {
+			return reflect.Zero(typ), err
+		}
+		value := reflect.MakeSlice(typ, len(elems), len(elems))
+		for i, elem := range elems {
+			decoded, err := decode(elem, typ.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			value.Index(i).Set(decoded)
+		}
+		return value, nil
+	}
+	return decodeNode(data, typ)
+}
+
+func decodeNode(data []byte, typ reflect.Type) (reflect.Value, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return reflect.Value{}, err
+	}
+	if fields == nil {
+		return reflect.Zero(typ), nil
+	}
+	var kind string
+	if err := json.Unmarshal(fields["kind"], &kind); err != nil {
+		return reflect.Value{}, fmt.Errorf("node without a kind: %w", err)
+	}
+	nodeType, ok := kinds[kind]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown node kind %q", kind)
+	}
+	if !nodeType.AssignableTo(typ) {
+		return reflect.Value{}, fmt.Errorf("%s cannot be used as %s", kind, typ)
+	}
+	node := reflect.New(nodeType.Elem())
+	for i := range node.Elem().NumField() {
+		field := nodeType.Elem().Field(i)
+		raw, ok := fields[fieldName(field.Name)]
+		if !ok || field.Tag.Get("json") == "-" {
+			continue
+		}
+		value, err := decode(raw, field.Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s.%s: %w", kind, field.Name, err)
+		}
+		node.Elem().Field(i).Set(value)
+	}
+	return node, nil
+}
@@ -0,0 +1,74 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"lol/ast"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	sources := []string{
+		"let x: int = -1 + 2 * y",
+		"let s = \"a ${b} c\"\nprint(s, 'x', true, 1.5)",
+		"fn f<T: Eq>(xs: [T], n: int = 0, rest: ...string) -> [string: T] { return xs[n:] }",
+		"struct P<T> { x: T, y: int = 2 }\nfn (p: P) m() { p.y += 1; p.y++ }",
+		"let v = match x { 1..3 | 5 => a, P { y: z, w } => z, else => 0 }",
+		"while a { if b { break } else { continue } }\nfor k in {\"a\": 1} { print(k) }",
+	}
+	for _, source := range sources {
+		program := parseProgram(t, source)
+		data, err := ast.ToJSON(program)
+		if err != nil {
+			t.Fatalf("ToJSON(%q): %v", source, err)
+		}
+		if !json.Valid(data) {
+			t.Fatalf("ToJSON(%q) = %s, which is not valid JSON", source, data)
+		}
+		node, err := ast.FromJSON(data)
+		if err != nil {
+			t.Fatalf("FromJSON(%q): %v", source, err)
+		}
+		if !reflect.DeepEqual(node, program) {
+			t.Errorf("FromJSON(ToJSON(%q)) = %s, want %s", source, ast.Sexpr(node), ast.Sexpr(program))
+		}
+	}
+}
+
+func TestJSONShape(t *testing.T) {
+	program := parseProgram(t, "x + 1")
+	expr := program.Stmts[0].(*ast.ExprStmt).Expr
+	data, err := ast.ToJSON(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["kind"] != "BinaryExpr" || got["start"] != 0.0 || got["end"] != 5.0 {
+		t.Errorf("ToJSON = %s, want a BinaryExpr spanning [0, 5)", data)
+	}
+	left, _ := got["left"].(map[string]any)
+	token, _ := left["token"].(map[string]any)
+	if left["kind"] != "Ident" || token["type"] != "Identifier" || token["text"] != "x" {
+		t.Errorf("left = %v, want the Ident x", got["left"])
+	}
+	right, _ := got["right"].(map[string]any)
+	if right["kind"] != "NumberLit" || right["value"] != 1.0 {
+		t.Errorf("right = %v, want the NumberLit 1", got["right"])
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"kind":"Nonsense"}`,
+		`{"kind":"BinaryExpr","left":{"kind":"LetStmt"}}`,
+		`[1, 2]`,
+		`{`,
+	} {
+		if _, err := ast.FromJSON([]byte(data)); err == nil {
+			t.Errorf("FromJSON(%s) succeeded, want an error", data)
+		}
+	}
+}
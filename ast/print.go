@@ -0,0 +1,304 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Sexpr renders node as an S-expression on a single line, such as
+// "(let x int (+ 1 (* 2 y)))". Every node becomes a list headed by its
+// keyword or operator; names, types and literals are written as they are,
+// with strings and characters quoted.
+func Sexpr(node Node) string {
+	var builder strings.Builder
+	sexprOf(node).flat(&builder)
+	return builder.String()
+}
+
+// Print writes node to w as an S-expression like Sexpr's, but breaks a list
+// that does not fit on one line into one element per line, indented by its
+// depth. The output ends with a newline and is stable, so it can be kept
+// as the expected result of a parse.
+func Print(w io.Writer, node Node) error {
+	var builder strings.Builder
+	sexprOf(node).indented(&builder, 0)
+	builder.WriteByte('\n')
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// lineWidth is the length up to which Print keeps a list on one line.
+const lineWidth = 80
+
+// sexpr is either an atom or, if list is not nil, a list of sexprs.
+type sexpr struct {
+	atom string
+	list []sexpr
+}
+
+func atom(text string) sexpr {
+	return sexpr{atom: text}
+}
+
+func list(head string, elems ...sexpr) sexpr {
+	return sexpr{list: append([]sexpr{atom(head)}, elems...)}
+}
+
+func sexprOf(node Node) sexpr {
+	switch node := node.(type) {
+	case nil:
+		return atom("nil")
+	case *Program:
+		return list("program", stmts(node.Stmts)...)
+	case *Ident:
+		return atom(node.Token.Text)
+	case *NumberLit:
+		return atom(node.Token.Text)
+	case *StringLit:
+		return atom(strconv.Quote(node.Value))
+	case *InterpolatedString:
+		parts := make([]sexpr, len(node.Parts))
+		for i, part := range node.Parts {
+			parts[i] = sexprOf(part)
+		}
+		return list("interpolate", parts...)
+	case *CharLit:
+		return atom(strconv.QuoteRune(node.Value))
+	case *BoolLit:
+		return atom(strconv.FormatBool(node.Value))
+	case *UnaryExpr:
+		return list(node.Operator.Text, sexprOf(node.Operand))
+	case *PostfixExpr:
+		return list("postfix", atom(node.Operator.Text), sexprOf(node.Operand))
+	case *BinaryExpr:
+		return list(node.Operator.Text, sexprOf(node.Left), sexprOf(node.Right))
+	case *AssignExpr:
+		return list(node.Operator.Text, sexprOf(node.Target), sexprOf(node.Value))
+	case *CallExpr:
+		args := []sexpr{sexprOf(node.Callee)}
+		for _, arg := range node.Args {
+			args = append(args, sexprOf(arg))
+		}
+		return list("call", args...)
+	case *FieldExpr:
+		return list(".", sexprOf(node.Object), atom(node.Name.Text))
+	case *Grouping:
+		return list("group", sexprOf(node.Expr))
+	case *BadExpr:
+		return list("bad-expr")
+	case *ArrayLit:
+		elems := make([]sexpr, len(node.Elems))
+		for i, elem := range node.Elems {
+			elems[i] = sexprOf(elem)
+		}
+		return list("array", elems...)
+	case *MapLit:
+		entries := make([]sexpr, len(node.Entries))
+		for i, entry := range node.Entries {
+			entries[i] = sexprOf(entry)
+		}
+		return list("map", entries...)
+	case *KeyValue:
+		return sexpr{list: []sexpr{sexprOf(node.Key), sexprOf(node.Value)}}
+	case *IndexExpr:
+		return list("index", sexprOf(node.Object), sexprOf(node.Index))
+	case *SliceExpr:
+		return list("slice", sexprOf(node.Object), sexprOf(node.Low), sexprOf(node.High))
+	case *InstanceExpr:
+		return list("instance", append([]sexpr{sexprOf(node.Object)}, types(node.TypeArgs)...)...)
+	case *MatchExpr:
+		elems := []sexpr{sexprOf(node.Value)}
+		for _, arm := range node.Arms {
+			elems = append(elems, sexprOf(arm))
+		}
+		return list("match", elems...)
+	case *MatchArm:
+		if len(node.Patterns) == 0 {
+			return list("else", sexprOf(node.Body))
+		}
+		patterns := make([]sexpr, len(node.Patterns))
+		for i, pattern := range node.Patterns {
+			patterns[i] = sexprOf(pattern)
+		}
+		return list("arm", list("patterns", patterns...), sexprOf(node.Body))
+	case *LiteralPattern:
+		return sexprOf(node.Value)
+	case *RangePattern:
+		return list(node.Operator.Text, sexprOf(node.Low), sexprOf(node.High))
+	case *BindingPattern:
+		return atom(node.Name.Text)
+	case *StructPattern:
+		elems := []sexpr{atom(node.Name.Text)}
+		for _, field := range node.Fields {
+			elems = append(elems, sexprOf(field))
+		}
+		return list("struct-pattern", elems...)
+	case *FieldPattern:
+		if node.Pattern == nil {
+			return atom(node.Name.Text)
+		}
+		return sexpr{list: []sexpr{atom(node.Name.Text), sexprOf(node.Pattern)}}
+	case *TypeName:
+		return atom(node.Name.Text)
+	case *ArrayType:
+		return list("array-of", sexprOf(node.Elem))
+	case *MapType:
+		return list("map-of", sexprOf(node.Key), sexprOf(node.Value))
+	case *GenericType:
+		return list("generic", append([]sexpr{atom(node.Name.Text)}, types(node.Args)...)...)
+	case *FuncType:
+		elems := []sexpr{list("params", types(node.Params)...)}
+		if node.Result != nil {
+			elems = append(elems, sexprOf(node.Result))
+		}
+		return list("fn-type", elems...)
+	case *TypeParam:
+		if node.Constraint == nil {
+			return atom(node.Name.Text)
+		}
+		return sexpr{list: []sexpr{atom(node.Name.Text), sexprOf(node.Constraint)}}
+	case *LetStmt:
+		elems := []sexpr{atom(node.Name.Text)}
+		if node.Type != nil {
+			elems = append(elems, sexprOf(node.Type))
+		}
+		return list("let", append(elems, sexprOf(node.Value))...)
+	case *IfStmt:
+		elems := []sexpr{sexprOf(node.Condition), sexprOf(node.Then)}
+		if node.Else != nil {
+			elems = append(elems, sexprOf(node.Else))
+		}
+		return list("if", elems...)
+	case *ForInStmt:
+		return list("for", atom(node.Var.Text), sexprOf(node.Iterable), sexprOf(node.Body))
+	case *WhileStmt:
+		return list("while", sexprOf(node.Condition), sexprOf(node.Body))
+	case *BranchStmt:
+		return list(node.Keyword.Text)
+	case *StructDecl:
+		elems := []sexpr{atom(node.Name.Text)}
+		if len(node.TypeParams) > 0 {
+			elems = append(elems, typeParams(node.TypeParams))
+		}
+		for _, field := range node.Fields {
+			elems = append(elems, sexprOf(field))
+		}
+		return list("struct", elems...)
+	case *Field:
+		elems := []sexpr{atom(node.Name.Text), sexprOf(node.Type)}
+		if node.Default != nil {
+			elems = append(elems, sexprOf(node.Default))
+		}
+		return sexpr{list: elems}
+	case *FuncDecl:
+		elems := []sexpr{atom(node.Name.Text)}
+		if node.Recv != nil {
+			elems = append(elems, list("recv", sexprOf(node.Recv)))
+		}
+		if len(node.TypeParams) > 0 {
+			elems = append(elems, typeParams(node.TypeParams))
+		}
+		params := make([]sexpr, len(node.Params))
+		for i, param := range node.Params {
+			params[i] = sexprOf(param)
+		}
+		elems = append(elems, list("params", params...))
+		if node.Result != nil {
+			elems = append(elems, sexprOf(node.Result))
+		}
+		return list("fn", append(elems, sexprOf(node.Body))...)
+	case *Param:
+		elems := []sexpr{atom(node.Name.Text)}
+		if node.Variadic {
+			elems = append(elems, atom("..."))
+		}
+		elems = append(elems, sexprOf(node.Type))
+		if node.Default != nil {
+			elems = append(elems, sexprOf(node.Default))
+		}
+		return sexpr{list: elems}
+	case *CommentGroup:
+		return list("comments", atom(strconv.Quote(node.Text())))
+	case *ReturnStmt:
+		if node.Value == nil {
+			return list("return")
+		}
+		return list("return", sexprOf(node.Value))
+	case *Block:
+		return list("block", stmts(node.Stmts)...)
+	case *ExprStmt:
+		return sexprOf(node.Expr)
+	case *BadStmt:
+		return list("bad-stmt")
+	default:
+		panic(fmt.Sprintf("ast: unexpected node %T", node))
+	}
+}
+
+func stmts(stmts []Stmt) []sexpr {
+	elems := make([]sexpr, len(stmts))
+	for i, stmt := range stmts {
+		elems[i] = sexprOf(stmt)
+	}
+	return elems
+}
+
+func types(types []TypeExpr) []sexpr {
+	elems := make([]sexpr, len(types))
+	for i, typ := range types {
+		elems[i] = sexprOf(typ)
+	}
+	return elems
+}
+
+func typeParams(params []*TypeParam) sexpr {
+	elems := make([]sexpr, len(params))
+	for i, param := range params {
+		elems[i] = sexprOf(param)
+	}
+	return list("type-params", elems...)
+}
+
+func (s sexpr) flat(builder *strings.Builder) {
+	if s.list == nil {
+		builder.WriteString(s.atom)
+		return
+	}
+	builder.WriteByte('(')
+	for i, elem := range s.list {
+		if i > 0 {
+			builder.WriteByte(' ')
+		}
+		elem.flat(builder)
+	}
+	builder.WriteByte(')')
+}
+
+// indented writes s on one line if it fits, and otherwise puts the head of
+// the list and the atoms that directly follow it on the first line and
+// every other element on a line of its own.
+func (s sexpr) indented(builder *strings.Builder, depth int) {
+	var line strings.Builder
+	s.flat(&line)
+	if s.list == nil || 2*depth+line.Len() <= lineWidth {
+		builder.WriteString(line.String())
+		return
+	}
+	builder.WriteByte('(')
+	inline := true
+	for i, elem := range s.list {
+		inline = inline && elem.list == nil
+		switch {
+		case i == 0:
+		case inline:
+			builder.WriteByte(' ')
+		default:
+			builder.WriteByte('\n')
+			builder.WriteString(strings.Repeat("  ", depth+1))
+		}
+		elem.indented(builder, depth+1)
+	}
+	builder.WriteByte(')')
+}
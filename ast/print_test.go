@@ -0,0 +1,65 @@
+package ast_test
+
+import (
+	"lol/ast"
+	"lol/parse"
+	"lol/scan"
+	"strings"
+	"testing"
+)
+
+func parseProgram(t *testing.T, source string) *ast.Program {
+	t.Helper()
+	scanner := scan.NewScanner(source)
+	tokens, scanErrors := scanner.Scan()
+	if len(scanErrors) > 0 {
+		t.Fatalf("scan %q: %v", source, scanErrors)
+	}
+	parser := parse.NewParser(tokens)
+	program, parseErrors := parser.ParseProgram()
+	if len(parseErrors) > 0 {
+		t.Fatalf("parse %q: %v", source, parseErrors)
+	}
+	return program
+}
+
+func TestSexpr(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"let x: int = 1 + 2 * y", "(program (let x int (+ 1 (* 2 y))))"},
+		{`print("a b", '\'', true)`, `(program (call print "a b" '\'' true))`},
+		{"let s = \"n = ${n + 1}!\"", `(program (let s (interpolate "n = " (+ n 1) "!")))`},
+		{"fn f<T>(xs: [T], n: int = 0) -> [string: T] { return xs[n:] }",
+			"(program (fn f (type-params T) (params (xs (array-of T)) (n int 0)) (map-of string T) (block (return (slice xs n nil)))))"},
+		{"struct P { x: int }\nfn (p: P) get() -> int { return p.x }",
+			"(program (struct P (x int)) (fn get (recv (p P)) (params) int (block (return (. p x)))))"},
+		{"let v = match x { 1..3 | 5 => a, P { y: z } => z, else => 0 }",
+			"(program (let v (match x (arm (patterns (.. 1 3) 5) a) (arm (patterns (struct-pattern P (y z))) z) (else 0))))"},
+	}
+	for _, test := range tests {
+		if got := ast.Sexpr(parseProgram(t, test.source)); got != test.want {
+			t.Errorf("Sexpr(%q) =\n%s\nwant\n%s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	program := parseProgram(t, "let a = 1\n"+
+		"fn distance(x: double, y: double) -> double { return (x * x + y * y) ** 0.5 }")
+	var builder strings.Builder
+	if err := ast.Print(&builder, program); err != nil {
+		t.Fatal(err)
+	}
+	want := `(program
+  (let a 1)
+  (fn distance
+    (params (x double) (y double))
+    double
+    (block (return (** (group (+ (* x x) (* y y))) 0.5)))))
+`
+	if got := builder.String(); got != want {
+		t.Errorf("Print =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package ast
+
+import "lol/scan"
+
+// Scope holds the names declared in a block, function, struct or program.
+// Outer is the scope around it, or nil for the outermost.
+type Scope struct {
+	Outer   *Scope
+	Objects map[string]*Object
+}
+
+func NewScope(outer *Scope) *Scope {
+	return &Scope{Outer: outer, Objects: make(map[string]*Object)}
+}
+
+// Lookup returns the object declared as name in the scope itself, or nil.
+// The scopes around it are not searched.
+func (scope *Scope) Lookup(name string) *Object {
+	return scope.Objects[name]
+}
+
+// Insert declares object in the scope. If its name is declared there
+// already, the scope is left unchanged and the earlier object returned.
+func (scope *Scope) Insert(object *Object) (alt *Object) {
+	if alt = scope.Objects[object.Name.Text]; alt == nil {
+		scope.Objects[object.Name.Text] = object
+	}
+	return alt
+}
+
+// ObjKind tells what an Object names.
+type ObjKind int
+
+const (
+	Bad ObjKind = iota
+	Var         // variables and parameters
+	Fun         // functions
+	Typ         // structs, type parameters and built-in types
+)
+
+var objKindNames = [...]string{
+	Bad: "bad",
+	Var: "variable",
+	Fun: "function",
+	Typ: "type",
+}
+
+func (kind ObjKind) String() string {
+	return objKindNames[kind]
+}
+
+// Object is a declared name. Decl is the node declaring it: a LetStmt,
+// ForInStmt, Param, BindingPattern or FieldPattern for a variable, a
+// FuncDecl, or a StructDecl or TypeParam for a type. It is nil for the
+// built-in names, whose Name has only its text.
+type Object struct {
+	Kind ObjKind
+	Name scan.Token
+	Decl Node
+}
@@ -0,0 +1,192 @@
+package ast
+
+func (group *CommentGroup) Start() int { return group.List[0].StartOffset }
+func (group *CommentGroup) End() int   { return group.List[len(group.List)-1].EndOffset }
+
+func (program *Program) Start() int {
+	if len(program.Stmts) > 0 {
+		return program.Stmts[0].Start()
+	}
+	return program.EOF.StartOffset
+}
+
+func (program *Program) End() int { return program.EOF.EndOffset }
+
+func (ident *Ident) Start() int { return ident.Token.StartOffset }
+func (ident *Ident) End() int   { return ident.Token.EndOffset }
+
+func (lit *NumberLit) Start() int { return lit.Token.StartOffset }
+func (lit *NumberLit) End() int   { return lit.Token.EndOffset }
+
+func (lit *StringLit) Start() int { return lit.Token.StartOffset }
+func (lit *StringLit) End() int   { return lit.Token.EndOffset }
+
+func (str *InterpolatedString) Start() int { return str.Parts[0].Start() }
+func (str *InterpolatedString) End() int   { return str.Parts[len(str.Parts)-1].End() }
+
+func (lit *CharLit) Start() int { return lit.Token.StartOffset }
+func (lit *CharLit) End() int   { return lit.Token.EndOffset }
+
+func (lit *BoolLit) Start() int { return lit.Token.StartOffset }
+func (lit *BoolLit) End() int   { return lit.Token.EndOffset }
+
+func (expr *UnaryExpr) Start() int { return expr.Operator.StartOffset }
+func (expr *UnaryExpr) End() int   { return expr.Operand.End() }
+
+func (expr *PostfixExpr) Start() int { return expr.Operand.Start() }
+func (expr *PostfixExpr) End() int   { return expr.Operator.EndOffset }
+
+func (expr *BinaryExpr) Start() int { return expr.Left.Start() }
+func (expr *BinaryExpr) End() int   { return expr.Right.End() }
+
+func (expr *AssignExpr) Start() int { return expr.Target.Start() }
+func (expr *AssignExpr) End() int   { return expr.Value.End() }
+
+func (expr *CallExpr) Start() int { return expr.Callee.Start() }
+func (expr *CallExpr) End() int   { return expr.Close.EndOffset }
+
+func (expr *FieldExpr) Start() int { return expr.Object.Start() }
+func (expr *FieldExpr) End() int   { return expr.Name.EndOffset }
+
+func (expr *Grouping) Start() int { return expr.Open.StartOffset }
+func (expr *Grouping) End() int   { return expr.Close.EndOffset }
+
+func (expr *BadExpr) Start() int { return expr.From }
+func (expr *BadExpr) End() int   { return expr.To }
+
+func (lit *ArrayLit) Start() int { return lit.Open.StartOffset }
+func (lit *ArrayLit) End() int   { return lit.Close.EndOffset }
+
+func (lit *MapLit) Start() int { return lit.Open.StartOffset }
+func (lit *MapLit) End() int   { return lit.Close.EndOffset }
+
+func (entry *KeyValue) Start() int { return entry.Key.Start() }
+func (entry *KeyValue) End() int   { return entry.Value.End() }
+
+func (expr *IndexExpr) Start() int { return expr.Object.Start() }
+func (expr *IndexExpr) End() int   { return expr.Close.EndOffset }
+
+func (expr *SliceExpr) Start() int { return expr.Object.Start() }
+func (expr *SliceExpr) End() int   { return expr.Close.EndOffset }
+
+func (expr *InstanceExpr) Start() int { return expr.Object.Start() }
+func (expr *InstanceExpr) End() int   { return expr.Close.EndOffset }
+
+func (expr *MatchExpr) Start() int { return expr.Match.StartOffset }
+func (expr *MatchExpr) End() int   { return expr.Close.EndOffset }
+
+func (arm *MatchArm) Start() int {
+	if len(arm.Patterns) > 0 {
+		return arm.Patterns[0].Start()
+	}
+	return arm.Else.StartOffset
+}
+
+func (arm *MatchArm) End() int { return arm.Body.End() }
+
+func (pattern *LiteralPattern) Start() int { return pattern.Value.Start() }
+func (pattern *LiteralPattern) End() int   { return pattern.Value.End() }
+
+func (pattern *RangePattern) Start() int { return pattern.Low.Start() }
+func (pattern *RangePattern) End() int   { return pattern.High.End() }
+
+func (pattern *BindingPattern) Start() int { return pattern.Name.StartOffset }
+func (pattern *BindingPattern) End() int   { return pattern.Name.EndOffset }
+
+func (pattern *StructPattern) Start() int { return pattern.Name.StartOffset }
+func (pattern *StructPattern) End() int   { return pattern.Close.EndOffset }
+
+func (pattern *FieldPattern) Start() int { return pattern.Name.StartOffset }
+func (pattern *FieldPattern) End() int {
+	if pattern.Pattern != nil {
+		return pattern.Pattern.End()
+	}
+	return pattern.Name.EndOffset
+}
+
+func (name *TypeName) Start() int { return name.Name.StartOffset }
+func (name *TypeName) End() int   { return name.Name.EndOffset }
+
+func (typ *ArrayType) Start() int { return typ.Open.StartOffset }
+func (typ *ArrayType) End() int   { return typ.Close.EndOffset }
+
+func (typ *MapType) Start() int { return typ.Open.StartOffset }
+func (typ *MapType) End() int   { return typ.Close.EndOffset }
+
+func (typ *GenericType) Start() int { return typ.Name.StartOffset }
+func (typ *GenericType) End() int   { return typ.Close.EndOffset }
+
+func (typ *FuncType) Start() int { return typ.Fn.StartOffset }
+func (typ *FuncType) End() int {
+	if typ.Result != nil {
+		return typ.Result.End()
+	}
+	return typ.Close.EndOffset
+}
+
+func (param *TypeParam) Start() int { return param.Name.StartOffset }
+func (param *TypeParam) End() int {
+	if param.Constraint != nil {
+		return param.Constraint.End()
+	}
+	return param.Name.EndOffset
+}
+
+func (stmt *LetStmt) Start() int { return stmt.Let.StartOffset }
+func (stmt *LetStmt) End() int   { return stmt.Value.End() }
+
+func (stmt *IfStmt) Start() int { return stmt.If.StartOffset }
+func (stmt *IfStmt) End() int {
+	if stmt.Else != nil {
+		return stmt.Else.End()
+	}
+	return stmt.Then.End()
+}
+
+func (stmt *ForInStmt) Start() int { return stmt.For.StartOffset }
+func (stmt *ForInStmt) End() int   { return stmt.Body.End() }
+
+func (stmt *WhileStmt) Start() int { return stmt.While.StartOffset }
+func (stmt *WhileStmt) End() int   { return stmt.Body.End() }
+
+func (stmt *BranchStmt) Start() int { return stmt.Keyword.StartOffset }
+func (stmt *BranchStmt) End() int   { return stmt.Keyword.EndOffset }
+
+func (decl *StructDecl) Start() int { return decl.Struct.StartOffset }
+func (decl *StructDecl) End() int   { return decl.Close.EndOffset }
+
+func (field *Field) Start() int { return field.Name.StartOffset }
+func (field *Field) End() int {
+	if field.Default != nil {
+		return field.Default.End()
+	}
+	return field.Type.End()
+}
+
+func (decl *FuncDecl) Start() int { return decl.Fn.StartOffset }
+func (decl *FuncDecl) End() int   { return decl.Body.End() }
+
+func (param *Param) Start() int { return param.Name.StartOffset }
+func (param *Param) End() int {
+	if param.Default != nil {
+		return param.Default.End()
+	}
+	return param.Type.End()
+}
+
+func (stmt *ReturnStmt) Start() int { return stmt.Return.StartOffset }
+func (stmt *ReturnStmt) End() int {
+	if stmt.Value != nil {
+		return stmt.Value.End()
+	}
+	return stmt.Return.EndOffset
+}
+
+func (block *Block) Start() int { return block.Open.StartOffset }
+func (block *Block) End() int   { return block.Close.EndOffset }
+
+func (stmt *ExprStmt) Start() int { return stmt.Expr.Start() }
+func (stmt *ExprStmt) End() int   { return stmt.Expr.End() }
+
+func (stmt *BadStmt) Start() int { return stmt.From }
+func (stmt *BadStmt) End() int   { return stmt.To }
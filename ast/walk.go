@@ -0,0 +1,308 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Visitor's Visit method is called by Walk for every node. If it returns
+// a non-nil visitor w, Walk visits the children of the node with w and then
+// calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree below node depth-first, starting with
+// v.Visit(node). Children are visited in source order.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	children(node, func(_ string, _ int, child Node) {
+		Walk(v, child)
+	})
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree below node depth-first, calling f for every
+// node and skipping the children of those for which f returns false. After
+// the children of a node, f is called with nil.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Cursor describes the node Apply is at: its parent and the field of the
+// parent holding it.
+type Cursor struct {
+	node   Node
+	parent Node
+	name   string
+	index  int
+}
+
+func (cursor *Cursor) Node() Node {
+	return cursor.node
+}
+
+// Parent returns the node whose field holds the current node, or nil for
+// the root.
+func (cursor *Cursor) Parent() Node {
+	return cursor.parent
+}
+
+// Name returns the name of the parent's field holding the current node,
+// such as "Left", or "" for the root.
+func (cursor *Cursor) Name() string {
+	return cursor.name
+}
+
+// Index returns the position of the current node in the parent's field if
+// the field is a list, and -1 otherwise.
+func (cursor *Cursor) Index() int {
+	return cursor.index
+}
+
+// Replace puts node in the place of the current node. It panics if the
+// parent's field cannot hold node, such as a statement where an expression
+// belongs.
+func (cursor *Cursor) Replace(node Node) {
+	if cursor.parent == nil {
+		cursor.node = node
+		return
+	}
+	field := reflect.ValueOf(cursor.parent).Elem().FieldByName(cursor.name)
+	if cursor.index >= 0 {
+		field = field.Index(cursor.index)
+	}
+	value := reflect.ValueOf(node)
+	if node == nil {
+		value = reflect.Zero(field.Type())
+	} else if !value.Type().AssignableTo(field.Type()) {
+		panic(fmt.Sprintf("ast: cannot replace %T.%s with %T", cursor.parent, cursor.name, node))
+	}
+	field.Set(value)
+	cursor.node = node
+}
+
+// ApplyFunc is called by Apply for every node. See Apply for what its
+// result means.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses the tree below root depth-first like Walk, calling pre
+// before and post after the children of every node, and returns the root,
+// which may have been replaced. Either function may be nil.
+//
+// If pre returns false, the children of the node are skipped and post is
+// not called for it. If post returns false, the traversal stops. A node
+// replaced in pre has the children of its replacement visited instead.
+func Apply(root Node, pre, post ApplyFunc) Node {
+	applier := &applier{pre: pre, post: post}
+	cursor := &Cursor{node: root, index: -1}
+	applier.apply(cursor)
+	return cursor.node
+}
+
+type applier struct {
+	pre, post ApplyFunc
+	stopped   bool
+}
+
+func (applier *applier) apply(cursor *Cursor) {
+	if applier.pre != nil && !applier.pre(cursor) {
+		return
+	}
+	if cursor.node != nil {
+		children(cursor.node, func(name string, index int, child Node) {
+			if !applier.stopped {
+				applier.apply(&Cursor{node: child, parent: cursor.node, name: name, index: index})
+			}
+		})
+	}
+	if !applier.stopped && applier.post != nil && !applier.post(cursor) {
+		applier.stopped = true
+	}
+}
+
+// children calls f for each child of node that is present, in source order,
+// with the name of the field holding it and its index if the field is a
+// list, or -1.
+func children(node Node, f func(name string, index int, child Node)) {
+	switch node := node.(type) {
+	case *Program:
+		for i, stmt := range node.Stmts {
+			f("Stmts", i, stmt)
+		}
+	case *InterpolatedString:
+		for i, part := range node.Parts {
+			f("Parts", i, part)
+		}
+	case *UnaryExpr:
+		f("Operand", -1, node.Operand)
+	case *PostfixExpr:
+		f("Operand", -1, node.Operand)
+	case *BinaryExpr:
+		f("Left", -1, node.Left)
+		f("Right", -1, node.Right)
+	case *AssignExpr:
+		f("Target", -1, node.Target)
+		f("Value", -1, node.Value)
+	case *CallExpr:
+		f("Callee", -1, node.Callee)
+		for i, arg := range node.Args {
+			f("Args", i, arg)
+		}
+	case *FieldExpr:
+		f("Object", -1, node.Object)
+	case *Grouping:
+		f("Expr", -1, node.Expr)
+	case *ArrayLit:
+		for i, elem := range node.Elems {
+			f("Elems", i, elem)
+		}
+	case *MapLit:
+		for i, entry := range node.Entries {
+			f("Entries", i, entry)
+		}
+	case *KeyValue:
+		f("Key", -1, node.Key)
+		f("Value", -1, node.Value)
+	case *IndexExpr:
+		f("Object", -1, node.Object)
+		f("Index", -1, node.Index)
+	case *SliceExpr:
+		f("Object", -1, node.Object)
+		if node.Low != nil {
+			f("Low", -1, node.Low)
+		}
+		if node.High != nil {
+			f("High", -1, node.High)
+		}
+	case *InstanceExpr:
+		f("Object", -1, node.Object)
+		for i, arg := range node.TypeArgs {
+			f("TypeArgs", i, arg)
+		}
+	case *MatchExpr:
+		f("Value", -1, node.Value)
+		for i, arm := range node.Arms {
+			f("Arms", i, arm)
+		}
+	case *MatchArm:
+		for i, pattern := range node.Patterns {
+			f("Patterns", i, pattern)
+		}
+		f("Body", -1, node.Body)
+	case *LiteralPattern:
+		f("Value", -1, node.Value)
+	case *RangePattern:
+		f("Low", -1, node.Low)
+		f("High", -1, node.High)
+	case *StructPattern:
+		for i, field := range node.Fields {
+			f("Fields", i, field)
+		}
+	case *FieldPattern:
+		if node.Pattern != nil {
+			f("Pattern", -1, node.Pattern)
+		}
+	case *ArrayType:
+		f("Elem", -1, node.Elem)
+	case *MapType:
+		f("Key", -1, node.Key)
+		f("Value", -1, node.Value)
+	case *GenericType:
+		for i, arg := range node.Args {
+			f("Args", i, arg)
+		}
+	case *FuncType:
+		for i, param := range node.Params {
+			f("Params", i, param)
+		}
+		if node.Result != nil {
+			f("Result", -1, node.Result)
+		}
+	case *TypeParam:
+		if node.Constraint != nil {
+			f("Constraint", -1, node.Constraint)
+		}
+	case *LetStmt:
+		if node.Type != nil {
+			f("Type", -1, node.Type)
+		}
+		f("Value", -1, node.Value)
+	case *IfStmt:
+		f("Condition", -1, node.Condition)
+		f("Then", -1, node.Then)
+		if node.Else != nil {
+			f("Else", -1, node.Else)
+		}
+	case *ForInStmt:
+		f("Iterable", -1, node.Iterable)
+		f("Body", -1, node.Body)
+	case *WhileStmt:
+		f("Condition", -1, node.Condition)
+		f("Body", -1, node.Body)
+	case *StructDecl:
+		if node.Doc != nil {
+			f("Doc", -1, node.Doc)
+		}
+		for i, param := range node.TypeParams {
+			f("TypeParams", i, param)
+		}
+		for i, field := range node.Fields {
+			f("Fields", i, field)
+		}
+	case *Field:
+		if node.Doc != nil {
+			f("Doc", -1, node.Doc)
+		}
+		f("Type", -1, node.Type)
+		if node.Default != nil {
+			f("Default", -1, node.Default)
+		}
+	case *FuncDecl:
+		if node.Doc != nil {
+			f("Doc", -1, node.Doc)
+		}
+		if node.Recv != nil {
+			f("Recv", -1, node.Recv)
+		}
+		for i, param := range node.TypeParams {
+			f("TypeParams", i, param)
+		}
+		for i, param := range node.Params {
+			f("Params", i, param)
+		}
+		if node.Result != nil {
+			f("Result", -1, node.Result)
+		}
+		f("Body", -1, node.Body)
+	case *Param:
+		f("Type", -1, node.Type)
+		if node.Default != nil {
+			f("Default", -1, node.Default)
+		}
+	case *ReturnStmt:
+		if node.Value != nil {
+			f("Value", -1, node.Value)
+		}
+	case *Block:
+		for i, stmt := range node.Stmts {
+			f("Stmts", i, stmt)
+		}
+	case *ExprStmt:
+		f("Expr", -1, node.Expr)
+	case *CommentGroup, *Ident, *NumberLit, *StringLit, *CharLit, *BoolLit, *BadExpr, *BindingPattern, *TypeName, *BranchStmt, *BadStmt:
+	default:
+		panic(fmt.Sprintf("ast: unexpected node %T", node))
+	}
+}
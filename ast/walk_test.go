@@ -0,0 +1,175 @@
+package ast_test
+
+import (
+	"fmt"
+	"lol/ast"
+	"lol/scan"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// walkSource uses every kind of node.
+const walkSource = `/// A point.
+struct P<T: Eq> { x: T, y: int = 2 }
+fn (p: P) m(a: int, rest: ...string) -> [string: int] {
+	let s = "a ${p.x} b"
+	p.y += -a; p.y++
+	while true { if a < 1 { break } else { continue } }
+	for k in {"a": [1, 2][0:1]} { print(k, 'c', 1.5, false) }
+	return match (a) { 1..3 | 5 => {}, P { x: z, y } => {}, else => {} }
+}
+let q = List<int>(1)
+`
+
+// nodes returns every node below node in depth-first order, found through
+// the fields of each node rather than through Walk.
+func nodes(node ast.Node) []ast.Node {
+	list := []ast.Node{node}
+	fields := reflect.ValueOf(node).Elem()
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		if fields.Type().Field(i).Name == "Doc" {
+			continue
+		}
+		values := []reflect.Value{field}
+		if field.Kind() == reflect.Slice {
+			values = values[:0]
+			for j := range field.Len() {
+				values = append(values, field.Index(j))
+			}
+		}
+		for _, value := range values {
+			if value.Kind() != reflect.Interface && value.Kind() != reflect.Pointer || value.IsNil() {
+				continue
+			}
+			if child, ok := value.Interface().(ast.Node); ok {
+				list = append(list, nodes(child)...)
+			}
+		}
+	}
+	return list
+}
+
+func TestWalk(t *testing.T) {
+	program := parseProgram(t, walkSource)
+	var visited []ast.Node
+	ast.Inspect(program, func(node ast.Node) bool {
+		if node != nil {
+			visited = append(visited, node)
+		}
+		return true
+	})
+	want := nodes(program)
+	if len(visited) != len(want) {
+		t.Fatalf("Inspect visited %d nodes, want %d", len(visited), len(want))
+	}
+	for i := range want {
+		if visited[i] != want[i] {
+			t.Fatalf("node %d is %T, want %T", i, visited[i], want[i])
+		}
+	}
+	// Children are visited in source order.
+	for i := 1; i < len(visited); i++ {
+		if visited[i].Start() < visited[i-1].Start() {
+			t.Errorf("%T at %d visited after %T at %d", visited[i], visited[i].Start(), visited[i-1], visited[i-1].Start())
+		}
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	program := parseProgram(t, "let a = f(b)\nfn g() { let c = d }")
+	var names []string
+	ends := 0
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case nil:
+			ends++
+		case *ast.Ident:
+			names = append(names, node.Token.Text)
+		case *ast.FuncDecl:
+			return false
+		}
+		return true
+	})
+	if want := []string{"f", "b"}; !slices.Equal(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+	// Every node whose children were visited is closed with nil: the
+	// program, the let, the call and its two identifiers.
+	if ends != 5 {
+		t.Errorf("f(nil) called %d times, want 5", ends)
+	}
+}
+
+func TestApply(t *testing.T) {
+	program := parseProgram(t, "let a = (1 + 2) * x\nprint(3 + 4, a)")
+	// Fold additions of two numbers, bottom up.
+	ast.Apply(program, nil, func(cursor *ast.Cursor) bool {
+		binary, ok := cursor.Node().(*ast.BinaryExpr)
+		if !ok || binary.Operator.Type != scan.Plus {
+			return true
+		}
+		left, ok1 := binary.Left.(*ast.NumberLit)
+		right, ok2 := binary.Right.(*ast.NumberLit)
+		if ok1 && ok2 {
+			sum := left.Value + right.Value
+			token := left.Token
+			token.Text = fmt.Sprint(sum)
+			cursor.Replace(&ast.NumberLit{Token: token, Value: sum})
+		}
+		return true
+	})
+	if got, want := ast.Sexpr(program), "(program (let a (* (group 3) x)) (call print 7 a))"; got != want {
+		t.Errorf("folded = %s, want %s", got, want)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	program := parseProgram(t, "print(a, b)")
+	var got []string
+	ast.Apply(program, func(cursor *ast.Cursor) bool {
+		if ident, ok := cursor.Node().(*ast.Ident); ok {
+			got = append(got, fmt.Sprintf("%s %T.%s[%d]", ident.Token.Text, cursor.Parent(), cursor.Name(), cursor.Index()))
+		}
+		return true
+	}, nil)
+	want := []string{"print *ast.CallExpr.Callee[-1]", "a *ast.CallExpr.Args[0]", "b *ast.CallExpr.Args[1]"}
+	if !slices.Equal(got, want) {
+		t.Errorf("cursors = %q, want %q", got, want)
+	}
+
+	// Returning false from post stops the traversal.
+	count := 0
+	ast.Apply(program, nil, func(cursor *ast.Cursor) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Errorf("post called %d times after returning false, want 1", count)
+	}
+
+	// The root itself can be replaced.
+	root := ast.Apply(program, func(cursor *ast.Cursor) bool {
+		cursor.Replace(&ast.Program{})
+		return false
+	}, nil)
+	if root == ast.Node(program) {
+		t.Errorf("Apply returned the old root")
+	}
+}
+
+func TestCursorReplaceMismatch(t *testing.T) {
+	program := parseProgram(t, "print(a)")
+	defer func() {
+		if recover() == nil {
+			t.Errorf("replacing an expression with a statement did not panic")
+		}
+	}()
+	ast.Apply(program, func(cursor *ast.Cursor) bool {
+		if _, ok := cursor.Node().(*ast.Ident); ok {
+			cursor.Replace(&ast.BranchStmt{})
+		}
+		return true
+	}, nil)
+}
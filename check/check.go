@@ -0,0 +1,432 @@
+// Package check checks the types of a program.
+package check
+
+import (
+	"fmt"
+	"lol/ast"
+	"lol/resolve"
+	"lol/scan"
+	"reflect"
+)
+
+// Error is a type error. Line, Column and Pos locate the first token of
+// the node with the error, and the offsets span the node.
+type Error struct {
+	Message     string
+	Line        int
+	Column      int
+	StartOffset int
+	EndOffset   int
+	Pos         scan.Pos
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s on line %d", e.Message, e.Line)
+}
+
+// Info holds the types Check found.
+type Info struct {
+	// Types holds the type of every expression.
+	Types map[ast.Expr]Type
+	// Defs holds the type of what each declaration declares, by the node
+	// that is the Decl of its object: the type of a variable or function,
+	// or the type a StructDecl or TypeParam stands for.
+	Defs map[ast.Node]Type
+	// Warnings holds the problems that do not make the program wrong, such
+	// as statements that can never run.
+	Warnings []error
+}
+
+// Check resolves the names of program and checks its types. The errors are
+// those of resolve.Resolve followed by the type errors.
+//
+// A variable declared without a type has the type of its initial value,
+// which then holds for all of its uses: "let x = 3 * 2.5" declares a
+// double.
+func Check(program *ast.Program) (*Info, []error) {
+	_, errors := resolve.Resolve(program)
+	checker := &checker{
+		info: &Info{
+			Types: make(map[ast.Expr]Type),
+			Defs:  make(map[ast.Node]Type),
+		},
+		errors: errors,
+	}
+	checker.stmts(program.Stmts)
+	checker.flow(program.Stmts)
+	return checker.info, checker.errors
+}
+
+type checker struct {
+	info *Info
+	// result is the result type of the function whose body is being
+	// checked, or nil outside of functions.
+	result Type
+	errors []error
+}
+
+// stmts checks the statements of a block. Like resolve.Resolve, it checks
+// the bodies of functions after the rest of the block, once the types of
+// all of the block's variables are known.
+func (checker *checker) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		if decl, ok := stmt.(*ast.FuncDecl); ok && decl.Recv != nil {
+			checker.method(decl)
+		}
+	}
+	var bodies []*ast.FuncDecl
+	for _, stmt := range stmts {
+		if decl, ok := stmt.(*ast.FuncDecl); ok {
+			bodies = append(bodies, decl)
+			continue
+		}
+		checker.stmt(stmt)
+	}
+	for _, decl := range bodies {
+		checker.funcDecl(decl)
+	}
+}
+
+func (checker *checker) stmt(stmt ast.Stmt) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStmt:
+		typ := checker.expr(stmt.Value)
+		if stmt.Type != nil {
+			value := typ
+			typ = checker.typeOf(stmt.Type)
+			checker.assign(stmt.Value, value, typ, "in declaration of "+stmt.Name.Text)
+		} else {
+			typ = checker.infer(stmt, typ)
+		}
+		checker.info.Defs[stmt] = typ
+	case *ast.IfStmt:
+		checker.condition(stmt.Condition)
+		checker.stmts(stmt.Then.Stmts)
+		if stmt.Else != nil {
+			checker.stmt(stmt.Else)
+		}
+	case *ast.ForInStmt:
+		var elem Type
+		switch iterable := checker.expr(stmt.Iterable).(type) {
+		case *Array:
+			elem = iterable.Elem
+		case *Map:
+			elem = iterable.Key
+		default:
+			elem = Invalid
+			if iterable == String {
+				elem = Char
+			} else if !unchecked(iterable) {
+				checker.errorf(stmt.Iterable, "cannot iterate over %s", iterable)
+			}
+		}
+		checker.info.Defs[stmt] = elem
+		checker.stmts(stmt.Body.Stmts)
+	case *ast.WhileStmt:
+		checker.condition(stmt.Condition)
+		checker.stmts(stmt.Body.Stmts)
+	case *ast.StructDecl:
+		s := checker.structType(stmt)
+		for i, field := range stmt.Fields {
+			if field.Default != nil {
+				checker.assign(field.Default, checker.expr(field.Default), s.Fields[i].Type, "as default of field "+field.Name.Text)
+			}
+		}
+	case *ast.ReturnStmt:
+		checker.returnStmt(stmt)
+	case *ast.Block:
+		checker.stmts(stmt.Stmts)
+	case *ast.ExprStmt:
+		checker.expr(stmt.Expr)
+	}
+}
+
+// condition checks that expr, the condition of an if or while, is a bool.
+func (checker *checker) condition(expr ast.Expr) {
+	if typ := checker.expr(expr); !assignable(typ, Bool) {
+		checker.errorf(expr, "condition must be bool, found %s%s", typ, checker.inferred(expr))
+	}
+}
+
+// infer returns the type of the variable stmt declares without a type,
+// the type of its value, or reports that the value has none to give.
+func (checker *checker) infer(stmt *ast.LetStmt, value Type) Type {
+	switch {
+	case value == Void:
+		checker.errorf(stmt.Value, "cannot infer the type of %s from a call that returns no value", stmt.Name.Text)
+		return Invalid
+	case empty(stmt.Value):
+		checker.errorf(stmt.Value, "cannot infer the type of %s from an empty literal, declare it as in \"let %s: %s = ...\"", stmt.Name.Text, stmt.Name.Text, example(value))
+		return Invalid
+	}
+	return value
+}
+
+// empty reports whether expr is an array or map literal without elements,
+// or an array literal holding only such literals, whose type has no
+// element type.
+func empty(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Grouping:
+		return empty(expr.Expr)
+	case *ast.MapLit:
+		return len(expr.Entries) == 0
+	case *ast.ArrayLit:
+		for _, elem := range expr.Elems {
+			if !empty(elem) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// example returns typ as it could be declared, with int for the types
+// that are not known.
+func example(typ Type) string {
+	switch typ := typ.(type) {
+	case *Array:
+		return "[" + example(typ.Elem) + "]"
+	case *Map:
+		return "[" + example(typ.Key) + ": " + example(typ.Value) + "]"
+	case Basic:
+		if typ == Invalid {
+			return "int"
+		}
+	}
+	return typ.String()
+}
+
+// inferred returns a note for the errors about the type of expr, if it is
+// a variable whose type was inferred: " (x inferred as int on line 1)".
+func (checker *checker) inferred(expr ast.Expr) string {
+	ident, ok := expr.(*ast.Ident)
+	if !ok || ident.Obj == nil {
+		return ""
+	}
+	let, ok := ident.Obj.Decl.(*ast.LetStmt)
+	if !ok || let.Type != nil || checker.info.Defs[let] == nil || checker.info.Defs[let] == Invalid {
+		return ""
+	}
+	return fmt.Sprintf(" (%s inferred as %s on line %d)", ident.Token.Text, checker.info.Defs[let], let.Name.Line)
+}
+
+func (checker *checker) returnStmt(stmt *ast.ReturnStmt) {
+	if checker.result == nil {
+		if stmt.Value != nil {
+			checker.expr(stmt.Value)
+		}
+		return
+	}
+	switch {
+	case stmt.Value == nil && checker.result != Void:
+		checker.errorf(stmt, "missing return value, expected %s", checker.result)
+	case stmt.Value != nil && checker.result == Void:
+		checker.expr(stmt.Value)
+		checker.errorf(stmt.Value, "function returns no value")
+	case stmt.Value != nil:
+		checker.assign(stmt.Value, checker.expr(stmt.Value), checker.result, "in return")
+	}
+}
+
+// funcDecl checks the default values of the parameters of decl and its
+// body, which has to return on every path unless the function has no
+// result.
+func (checker *checker) funcDecl(decl *ast.FuncDecl) {
+	fn := checker.signature(decl)
+	if decl.Recv != nil {
+		checker.info.Defs[decl.Recv] = checker.typeOf(decl.Recv.Type)
+	}
+	for i, param := range decl.Params {
+		typ := fn.Params[i]
+		if param.Default != nil {
+			checker.assign(param.Default, checker.expr(param.Default), typ, "as default of parameter "+param.Name.Text)
+		}
+		if param.Variadic {
+			typ = &Array{Elem: typ}
+		}
+		checker.info.Defs[param] = typ
+	}
+	outer := checker.result
+	checker.result = fn.Result
+	checker.stmts(decl.Body.Stmts)
+	checker.result = outer
+	if checker.flow(decl.Body.Stmts) != exit && fn.Result != Void {
+		checker.errorAt(decl.Body.Close, "missing return at the end of "+decl.Name.Text)
+	}
+}
+
+// signature returns the type of the function decl declares.
+func (checker *checker) signature(decl *ast.FuncDecl) *Func {
+	if fn, ok := checker.info.Defs[decl]; ok {
+		return fn.(*Func)
+	}
+	fn := &Func{Params: make([]Type, len(decl.Params)), Result: Void}
+	checker.info.Defs[decl] = fn
+	for i, param := range decl.Params {
+		fn.Params[i] = checker.typeOf(param.Type)
+		if param.Default == nil && !param.Variadic {
+			fn.Required++
+		}
+		fn.Variadic = param.Variadic
+	}
+	if decl.Result != nil {
+		fn.Result = checker.typeOf(decl.Result)
+	}
+	return fn
+}
+
+// method adds the method decl declares to the type of its receiver.
+func (checker *checker) method(decl *ast.FuncDecl) {
+	typ := checker.typeOf(decl.Recv.Type)
+	recv, ok := typ.(*Struct)
+	if !ok {
+		if !unchecked(typ) {
+			checker.errorf(decl.Recv.Type, "cannot declare methods on %s", typ)
+		}
+		return
+	}
+	if _, ok := recv.Methods[decl.Name.Text]; ok {
+		// Reported by resolve.
+		return
+	}
+	if recv.field(decl.Name.Text) != nil {
+		checker.errorAt(decl.Name, fmt.Sprintf("%s has a field named %s", recv, decl.Name.Text))
+		return
+	}
+	recv.Methods[decl.Name.Text] = checker.signature(decl)
+}
+
+// typeOf returns the type typ stands for.
+func (checker *checker) typeOf(typ ast.TypeExpr) Type {
+	switch typ := typ.(type) {
+	case *ast.TypeName:
+		switch typ.Name.Type {
+		case scan.Int:
+			return Int
+		case scan.Float:
+			return Float
+		case scan.Double:
+			return Double
+		case scan.Bool:
+			return Bool
+		}
+		return checker.named(typ.Obj)
+	case *ast.GenericType:
+		for _, arg := range typ.Args {
+			checker.typeOf(arg)
+		}
+		return checker.named(typ.Obj)
+	case *ast.ArrayType:
+		return &Array{Elem: checker.typeOf(typ.Elem)}
+	case *ast.MapType:
+		return &Map{Key: checker.typeOf(typ.Key), Value: checker.typeOf(typ.Value)}
+	case *ast.FuncType:
+		fn := &Func{Params: make([]Type, len(typ.Params)), Required: len(typ.Params), Result: Void}
+		for i, param := range typ.Params {
+			fn.Params[i] = checker.typeOf(param)
+		}
+		if typ.Result != nil {
+			fn.Result = checker.typeOf(typ.Result)
+		}
+		return fn
+	}
+	return Invalid
+}
+
+// named returns the type object, a struct, type parameter or built-in type,
+// stands for.
+func (checker *checker) named(object *ast.Object) Type {
+	if object == nil {
+		return Invalid
+	}
+	switch decl := object.Decl.(type) {
+	case *ast.StructDecl:
+		return checker.structType(decl)
+	case *ast.TypeParam:
+		if _, ok := checker.info.Defs[decl]; !ok {
+			checker.info.Defs[decl] = &TypeParam{Decl: decl}
+		}
+		return checker.info.Defs[decl]
+	case nil:
+		switch object.Name.Text {
+		case "string":
+			return String
+		case "char":
+			return Char
+		}
+	}
+	return Invalid
+}
+
+// structType returns the type decl declares.
+func (checker *checker) structType(decl *ast.StructDecl) *Struct {
+	if s, ok := checker.info.Defs[decl]; ok {
+		return s.(*Struct)
+	}
+	// Recorded before its fields, which can refer to it.
+	s := &Struct{Decl: decl, Methods: make(map[string]*Func)}
+	checker.info.Defs[decl] = s
+	for _, field := range decl.Fields {
+		s.Fields = append(s.Fields, &Field{Name: field.Name.Text, Type: checker.typeOf(field.Type), HasDefault: field.Default != nil})
+	}
+	return s
+}
+
+// assign reports if a value of type value, from expr, cannot be used as
+// target. context tells where, such as "in return".
+func (checker *checker) assign(expr ast.Expr, value, target Type, context string) {
+	if !assignable(value, target) {
+		checker.errorf(expr, "cannot use %s as %s %s%s", value, target, context, checker.inferred(expr))
+	}
+}
+
+func (checker *checker) errorf(node ast.Node, format string, args ...any) {
+	token := first(node)
+	checker.errors = append(checker.errors, Error{
+		Message:     fmt.Sprintf(format, args...),
+		Line:        token.Line,
+		Column:      token.Column,
+		StartOffset: node.Start(),
+		EndOffset:   node.End(),
+		Pos:         token.Pos,
+	})
+}
+
+func (checker *checker) errorAt(token scan.Token, msg string) {
+	checker.errors = append(checker.errors, Error{
+		Message:     msg,
+		Line:        token.Line,
+		Column:      token.Column,
+		StartOffset: token.StartOffset,
+		EndOffset:   token.EndOffset,
+		Pos:         token.Pos,
+	})
+}
+
+// first returns the first token of node, found among its fields and those
+// of the nodes starting where it does.
+func first(node ast.Node) scan.Token {
+	fields := reflect.ValueOf(node).Elem()
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		if field.Kind() == reflect.Slice && field.Len() > 0 {
+			field = field.Index(0)
+		}
+		if !field.CanInterface() || (field.Kind() == reflect.Interface || field.Kind() == reflect.Pointer) && field.IsNil() {
+			continue
+		}
+		switch value := field.Interface().(type) {
+		case scan.Token:
+			if value.StartOffset == node.Start() && value.EndOffset > value.StartOffset {
+				return value
+			}
+		case ast.Node:
+			if value.Start() == node.Start() {
+				return first(value)
+			}
+		}
+	}
+	return scan.Token{StartOffset: node.Start(), EndOffset: node.End()}
+}
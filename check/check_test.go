@@ -0,0 +1,208 @@
+package check
+
+import (
+	"lol/ast"
+	"lol/parse"
+	"lol/scan"
+	"slices"
+	"testing"
+)
+
+func check(t *testing.T, source string) (*ast.Program, *Info, []error) {
+	t.Helper()
+	scanner := scan.NewScanner(source)
+	tokens, scanErrors := scanner.Scan()
+	if len(scanErrors) > 0 {
+		t.Fatalf("scan %q: %v", source, scanErrors)
+	}
+	parser := parse.NewParser(tokens)
+	program, parseErrors := parser.ParseProgram()
+	if len(parseErrors) > 0 {
+		t.Fatalf("parse %q: %v", source, parseErrors)
+	}
+	info, errors := Check(program)
+	return program, info, errors
+}
+
+func messages(errors []error) []string {
+	list := make([]string, len(errors))
+	for i, err := range errors {
+		list[i] = err.Error()
+	}
+	return list
+}
+
+// operands declares a variable of each basic type for TestBinary.
+const operands = `let i: int = 1
+let f: float = 1
+let d: double = 1
+let s: string = "s"
+let c: char = 'c'
+let b: bool = true
+fn g(x: int) -> string { return s }
+`
+
+func TestBinary(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"i + i", "int"},
+		{"i + f", "float"},
+		{"f * i", "float"},
+		{"i - d", "double"},
+		{"d / f", "double"},
+		{"i % i", "int"},
+		{"i ** f", "float"},
+		{"s + s", "string"},
+		{"i | i", "int"},
+		{"i & i", "int"},
+		{"i ^ i", "int"},
+		{"b && b", "bool"},
+		{"b || b", "bool"},
+		{"i == d", "bool"},
+		{"s != s", "bool"},
+		{"b == b", "bool"},
+		{"i < f", "bool"},
+		{"s >= s", "bool"},
+		{"c < c", "bool"},
+		{"i ?? d", "double"},
+		{"s ?: s", "string"},
+		{"i |> g", "string"},
+		{"s + i", "operator + cannot be applied to string and int"},
+		{"s - s", "operator - cannot be applied to string and string"},
+		{"f | i", "operator | cannot be applied to float and int"},
+		{"i && b", "operator && cannot be applied to int and bool"},
+		{"i == s", "operator == cannot be applied to int and string"},
+		{"b < b", "operator < cannot be applied to bool and bool"},
+		{"s ?? i", "operator ?? cannot be applied to string and int"},
+		{"s |> g", "operator |> cannot be applied to string and fn(int) -> string"},
+		{"i |> i", "operator |> cannot be applied to int and int"},
+	}
+	for _, test := range tests {
+		program, info, errors := check(t, operands+"let x = "+test.expr)
+		got := info.Defs[program.Stmts[len(program.Stmts)-1]].String()
+		if len(errors) > 0 {
+			got = errors[0].(Error).Message
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestCheckErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"declaration", "let a: int = 1\nlet b: string = a", []string{"cannot use int as string in declaration of b on line 2"}},
+		{"widening declaration", "let a: double = 1", nil},
+		{"condition", "if 1 { }", []string{"condition must be bool, found int on line 1"}},
+		{"not enough arguments", "fn add(a: int, b: int = 1) -> int { return a + b }\nprint(add())", []string{"not enough arguments in call to add, expected 1 to 2, found 0 on line 2"}},
+		{"too many arguments", "fn add(a: int, b: int = 1) -> int { return a + b }\nprint(add(1, 2, 3))", []string{"too many arguments in call to add, expected 1 to 2, found 3 on line 2"}},
+		{"argument type", "fn add(a: int) -> int { return a }\nprint(add(\"x\"))", []string{"cannot use string as int in argument 1 to add on line 2"}},
+		{"return type", "fn f() -> float { return 1.5 }", []string{"cannot use double as float in return on line 1"}},
+		{"return value from void", "fn f() { return 1 }", []string{"function returns no value on line 1"}},
+		{"missing return value", "fn f() -> int { return }", []string{"missing return value, expected int on line 1"}},
+		{"array element", "let a = [1, \"x\"]", []string{"array element has type string, expected int on line 1"}},
+		{"index", "let a = [1]\nprint(a[\"x\"])", []string{"index must be int, found string on line 2"}},
+		{"iterate", "for x in 3 { }", []string{"cannot iterate over int on line 1"}},
+		{"field", "struct P { x: int }\nfn f(p: P) -> int { return p.z }", []string{"P has no field or method z on line 2"}},
+		{"assign to function", "fn f() {}\nf = 1", []string{"cannot assign to function f on line 2"}},
+		{"void value", "fn f() {}\nlet a: int = f()", []string{"cannot use void as int in declaration of a on line 2"}},
+		{"resolve errors first", "let a: string = b", []string{"b is not declared on line 1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, errors := check(t, test.source)
+			if got := messages(errors); !slices.Equal(got, test.want) {
+				t.Errorf("Check(%q) errors = %q, want %q", test.source, got, test.want)
+			}
+		})
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		source                 string
+		line, column           int
+		startOffset, endOffset int
+	}{
+		// The operand expression of a declaration.
+		{"let a: int = 1\nlet b: string = a + 2", 2, 17, 31, 36},
+		// A whole binary expression starts at its left operand.
+		{"let s: string = \"s\"\nprint(  s - 1)", 2, 9, 28, 33},
+		// The condition of an if statement.
+		{"if 1 + 2 { }", 1, 4, 3, 8},
+		// A return statement without a value, after a tab.
+		{"fn f() -> int {\n\treturn\n}", 2, 2, 17, 23},
+	}
+	for _, test := range tests {
+		_, _, errors := check(t, test.source)
+		if len(errors) != 1 {
+			t.Errorf("Check(%q) errors = %q, want one", test.source, messages(errors))
+			continue
+		}
+		err := errors[0].(Error)
+		if err.Line != test.line || err.Column != test.column || err.StartOffset != test.startOffset || err.EndOffset != test.endOffset {
+			t.Errorf("Check(%q) error at %d:%d [%d, %d), want %d:%d [%d, %d)", test.source,
+				err.Line, err.Column, err.StartOffset, err.EndOffset,
+				test.line, test.column, test.startOffset, test.endOffset)
+		}
+	}
+}
+
+func TestInference(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"inferred", "let x = 1.5\nlet i: double = x", nil},
+		{"inferred note", "let x = 1.5\nlet i: int = x", []string{"cannot use double as int in declaration of i (x inferred as double on line 1) on line 2"}},
+		{"notes in assignment", "let x = \"s\"\nlet i = 1\ni = x", []string{"cannot use string as int in assignment (i inferred as int on line 2) (x inferred as string on line 1) on line 3"}},
+		{"note in condition", "let n = 1\nif n { }", []string{"condition must be bool, found int (n inferred as int on line 1) on line 2"}},
+		{"no note for declared type", "let x: double = 1\nlet i: int = x", []string{"cannot use double as int in declaration of i on line 2"}},
+		{"empty array", "let a = []", []string{`cannot infer the type of a from an empty literal, declare it as in "let a: [int] = ..." on line 1`}},
+		{"empty nested array", "let a = [[], []]", []string{`cannot infer the type of a from an empty literal, declare it as in "let a: [[int]] = ..." on line 1`}},
+		{"empty map", "let m = {}", []string{`cannot infer the type of m from an empty literal, declare it as in "let m: [int: int] = ..." on line 1`}},
+		{"declared empty literal", "let a: [string] = []\nlet m: [string: int] = {}", nil},
+		{"void call", "fn f() {}\nlet a = f()", []string{"cannot infer the type of a from a call that returns no value on line 2"}},
+		{"no note after failed inference", "let a = []\nlet b: int = a", []string{`cannot infer the type of a from an empty literal, declare it as in "let a: [int] = ..." on line 1`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, errors := check(t, test.source)
+			if got := messages(errors); !slices.Equal(got, test.want) {
+				t.Errorf("Check(%q) errors = %q, want %q", test.source, got, test.want)
+			}
+		})
+	}
+}
+
+func TestInferredTypes(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"let x = 1", "int"},
+		{"let x = 1.5", "double"},
+		{"let x = 1 + 2.5", "double"},
+		{"let x = [1, 2]", "[int]"},
+		{`let x = {"a": [1.5]}`, "[string: [double]]"},
+		{"fn f() -> char { return 'c' }\nlet x = f()", "char"},
+		{"let x = [1][0:1]", "[int]"},
+	}
+	for _, test := range tests {
+		program, info, errors := check(t, test.source)
+		if len(errors) > 0 {
+			t.Errorf("Check(%q) errors = %q", test.source, messages(errors))
+			continue
+		}
+		if got := info.Defs[program.Stmts[len(program.Stmts)-1]].String(); got != test.want {
+			t.Errorf("Check(%q) infers %s, want %s", test.source, got, test.want)
+		}
+	}
+}
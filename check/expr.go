@@ -0,0 +1,456 @@
+package check
+
+import (
+	"fmt"
+	"lol/ast"
+	"lol/scan"
+)
+
+// builtins holds the types of the built-in functions. The argument of len
+// is checked by call.
+var builtins = map[string]*Func{
+	"print": {Params: []Type{Invalid}, Variadic: true, Result: Void},
+	"len":   {Params: []Type{Invalid}, Required: 1, Result: Int},
+}
+
+// compound holds the operator each compound assignment applies.
+var compound = map[scan.Type]scan.Type{
+	scan.PlusAssign:  scan.Plus,
+	scan.MinusAssign: scan.Minus,
+	scan.StarAssign:  scan.Star,
+	scan.SlashAssign: scan.Slash,
+}
+
+// expr checks expr and returns its type, which it records in the Types of
+// the Info.
+func (checker *checker) expr(expr ast.Expr) Type {
+	typ := checker.exprType(expr)
+	checker.info.Types[expr] = typ
+	return typ
+}
+
+func (checker *checker) exprType(expr ast.Expr) Type {
+	switch expr := expr.(type) {
+	case *ast.NumberLit:
+		switch expr.Token.Kind {
+		case scan.FloatNumber:
+			return Float
+		case scan.DoubleNumber:
+			return Double
+		}
+		return Int
+	case *ast.StringLit:
+		return String
+	case *ast.InterpolatedString:
+		for _, part := range expr.Parts {
+			checker.expr(part)
+		}
+		return String
+	case *ast.CharLit:
+		return Char
+	case *ast.BoolLit:
+		return Bool
+	case *ast.Ident:
+		return checker.ident(expr)
+	case *ast.Grouping:
+		return checker.expr(expr.Expr)
+	case *ast.UnaryExpr:
+		return checker.unary(expr)
+	case *ast.PostfixExpr:
+		typ := checker.expr(expr.Operand)
+		if !checker.target(expr.Operand) {
+			return Invalid
+		}
+		if !numeric(typ) && !unchecked(typ) {
+			checker.errorf(expr, "operator %s cannot be applied to %s", expr.Operator.Text, typ)
+			return Invalid
+		}
+		return typ
+	case *ast.BinaryExpr:
+		return checker.binary(expr, expr.Operator, checker.expr(expr.Left), checker.expr(expr.Right))
+	case *ast.AssignExpr:
+		target := checker.expr(expr.Target)
+		value := checker.expr(expr.Value)
+		if !checker.target(expr.Target) {
+			return Invalid
+		}
+		if op, ok := compound[expr.Operator.Type]; ok {
+			operator := expr.Operator
+			operator.Type = op
+			value = checker.binary(expr, operator, target, value)
+		}
+		checker.assign(expr.Value, value, target, "in assignment"+checker.inferred(expr.Target))
+		return target
+	case *ast.CallExpr:
+		return checker.call(expr)
+	case *ast.FieldExpr:
+		return checker.field(expr)
+	case *ast.ArrayLit:
+		elem := Type(Invalid)
+		for _, value := range expr.Elems {
+			elem = checker.element(value, elem, "array element")
+		}
+		return &Array{Elem: elem}
+	case *ast.MapLit:
+		key, value := Type(Invalid), Type(Invalid)
+		for _, entry := range expr.Entries {
+			key = checker.element(entry.Key, key, "map key")
+			value = checker.element(entry.Value, value, "map value")
+		}
+		return &Map{Key: key, Value: value}
+	case *ast.IndexExpr:
+		return checker.index(expr)
+	case *ast.SliceExpr:
+		typ := checker.expr(expr.Object)
+		for _, bound := range []ast.Expr{expr.Low, expr.High} {
+			if bound != nil {
+				checker.intIndex(bound)
+			}
+		}
+		if _, ok := typ.(*Array); !ok && typ != String && !unchecked(typ) {
+			checker.errorf(expr.Object, "cannot slice %s", typ)
+			return Invalid
+		}
+		return typ
+	case *ast.InstanceExpr:
+		for _, arg := range expr.TypeArgs {
+			checker.typeOf(arg)
+		}
+		return checker.expr(expr.Object)
+	case *ast.MatchExpr:
+		return checker.match(expr)
+	}
+	return Invalid
+}
+
+func (checker *checker) ident(ident *ast.Ident) Type {
+	object := ident.Obj
+	if object == nil {
+		return Invalid
+	}
+	switch object.Kind {
+	case ast.Typ:
+		checker.errorf(ident, "%s is a type, not a value", ident.Token.Text)
+		return Invalid
+	case ast.Fun:
+		if decl, ok := object.Decl.(*ast.FuncDecl); ok {
+			return checker.signature(decl)
+		}
+		return builtins[object.Name.Text]
+	}
+	if typ, ok := checker.info.Defs[object.Decl]; ok {
+		return typ
+	}
+	return Invalid
+}
+
+func (checker *checker) unary(expr *ast.UnaryExpr) Type {
+	typ := checker.expr(expr.Operand)
+	switch {
+	case unchecked(typ):
+		return Invalid
+	case expr.Operator.Type == scan.Minus && numeric(typ):
+		return typ
+	case expr.Operator.Type == scan.Bang && typ == Bool:
+		return Bool
+	}
+	checker.errorf(expr, "operator %s cannot be applied to %s", expr.Operator.Text, typ)
+	return Invalid
+}
+
+// binary returns the type of applying operator to values of types left
+// and right, reporting expr if the operator does not apply to them.
+func (checker *checker) binary(expr ast.Expr, operator scan.Token, left, right Type) Type {
+	switch operator.Type {
+	case scan.Plus, scan.Minus, scan.Star, scan.Slash, scan.Percent, scan.StarStar:
+		switch {
+		case numeric(left) && numeric(right):
+			return wider(left, right)
+		case operator.Type == scan.Plus && left == String && right == String:
+			return String
+		case unchecked(left) || unchecked(right):
+			return Invalid
+		}
+	case scan.Pipe, scan.Caret, scan.Ampersand:
+		if unchecked(left) || unchecked(right) || left == Int && right == Int {
+			return Int
+		}
+	case scan.And, scan.Or:
+		if assignable(left, Bool) && assignable(right, Bool) {
+			return Bool
+		}
+	case scan.Equals, scan.NotEquals:
+		if _, ok := join(left, right); ok && left != Void {
+			return Bool
+		}
+	case scan.LeftAngle, scan.RightAngle, scan.LesserEquals, scan.GreaterEquals:
+		if typ, ok := join(left, right); ok && (unchecked(typ) || numeric(typ) || typ == String || typ == Char) {
+			return Bool
+		}
+	case scan.QuestionQuestion, scan.Elvis:
+		if typ, ok := join(left, right); ok {
+			return typ
+		}
+	case scan.PipeForward:
+		// "x |> f" calls f with x.
+		if unchecked(right) {
+			return Invalid
+		}
+		if fn, ok := right.(*Func); ok && len(fn.Params) > 0 && fn.Required <= 1 && assignable(left, fn.Params[0]) {
+			return fn.Result
+		}
+	default:
+		// An operator added to the parser, which has no types.
+		return Invalid
+	}
+	var note string
+	switch expr := expr.(type) {
+	case *ast.BinaryExpr:
+		note = checker.inferred(expr.Left) + checker.inferred(expr.Right)
+	case *ast.AssignExpr:
+		note = checker.inferred(expr.Target) + checker.inferred(expr.Value)
+	}
+	checker.errorf(expr, "operator %s cannot be applied to %s and %s%s", operator.Text, left, right, note)
+	return Invalid
+}
+
+// target reports expr, the target of an assignment, and returns false if
+// it is not a variable, field or element.
+func (checker *checker) target(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if expr.Obj != nil && expr.Obj.Kind != ast.Var {
+			checker.errorf(expr, "cannot assign to %s %s", expr.Obj.Kind, expr.Token.Text)
+			return false
+		}
+	case *ast.FieldExpr:
+		if s, ok := checker.info.Types[expr.Object].(*Struct); ok && s.field(expr.Name.Text) == nil {
+			checker.errorf(expr, "cannot assign to method %s", expr.Name.Text)
+			return false
+		}
+	case *ast.IndexExpr:
+		if checker.info.Types[expr.Object] == String {
+			checker.errorf(expr, "cannot assign to a character of a string")
+			return false
+		}
+	}
+	return true
+}
+
+func (checker *checker) call(call *ast.CallExpr) Type {
+	if ident, ok := call.Callee.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Kind == ast.Typ {
+		return checker.construct(call, ident)
+	}
+	callee := checker.expr(call.Callee)
+	fn, ok := callee.(*Func)
+	if !ok {
+		if !unchecked(callee) {
+			checker.errorf(call.Callee, "cannot call a value of type %s", callee)
+		}
+		for _, arg := range call.Args {
+			checker.expr(arg)
+		}
+		return Invalid
+	}
+	checker.args(call, name(call.Callee), fn)
+	if ident, ok := call.Callee.(*ast.Ident); ok && fn == builtins["len"] && len(call.Args) == 1 {
+		switch typ := checker.info.Types[call.Args[0]].(type) {
+		case *Array, *Map:
+		default:
+			if typ != String && !unchecked(typ) {
+				checker.errorf(call.Args[0], "%s cannot be applied to %s", ident.Token.Text, typ)
+			}
+		}
+	}
+	return fn.Result
+}
+
+// construct checks a call of a struct type, which makes a struct from
+// the values of its fields in order. Fields with a default value can be
+// left out at the end.
+func (checker *checker) construct(call *ast.CallExpr, ident *ast.Ident) Type {
+	s, ok := checker.named(ident.Obj).(*Struct)
+	if !ok {
+		checker.errorf(ident, "cannot call type %s", ident.Token.Text)
+		for _, arg := range call.Args {
+			checker.expr(arg)
+		}
+		return Invalid
+	}
+	checker.info.Types[ident] = s
+	fn := &Func{Params: make([]Type, len(s.Fields)), Result: s}
+	for i, field := range s.Fields {
+		fn.Params[i] = field.Type
+		if !field.HasDefault {
+			fn.Required = i + 1
+		}
+	}
+	checker.args(call, s.String(), fn)
+	return s
+}
+
+// args checks the arguments of call against the parameters of fn, which
+// is called name in the errors.
+func (checker *checker) args(call *ast.CallExpr, name string, fn *Func) {
+	args := make([]Type, len(call.Args))
+	for i, arg := range call.Args {
+		args[i] = checker.expr(arg)
+	}
+	expected := fmt.Sprint(fn.Required)
+	switch {
+	case fn.Variadic:
+		expected = "at least " + expected
+	case fn.Required < len(fn.Params):
+		expected = fmt.Sprintf("%d to %d", fn.Required, len(fn.Params))
+	}
+	switch {
+	case len(args) < fn.Required:
+		checker.errorf(call, "not enough arguments in call to %s, expected %s, found %d", name, expected, len(args))
+	case len(args) > len(fn.Params) && !fn.Variadic:
+		checker.errorf(call, "too many arguments in call to %s, expected %s, found %d", name, expected, len(args))
+	}
+	for i, arg := range args {
+		var param Type
+		switch {
+		case fn.Variadic && i >= len(fn.Params)-1:
+			param = fn.Params[len(fn.Params)-1]
+		case i < len(fn.Params):
+			param = fn.Params[i]
+		default:
+			return
+		}
+		checker.assign(call.Args[i], arg, param, fmt.Sprintf("in argument %d to %s", i+1, name))
+	}
+}
+
+// name returns the name of the function callee, for errors.
+func name(callee ast.Expr) string {
+	switch callee := callee.(type) {
+	case *ast.Ident:
+		return callee.Token.Text
+	case *ast.FieldExpr:
+		return name(callee.Object) + "." + callee.Name.Text
+	case *ast.InstanceExpr:
+		return name(callee.Object)
+	}
+	return "function"
+}
+
+func (checker *checker) field(expr *ast.FieldExpr) Type {
+	typ := checker.expr(expr.Object)
+	s, ok := typ.(*Struct)
+	if !ok {
+		if !unchecked(typ) {
+			checker.errorAt(expr.Name, fmt.Sprintf("%s has no field or method %s", typ, expr.Name.Text))
+		}
+		return Invalid
+	}
+	if field := s.field(expr.Name.Text); field != nil {
+		return field.Type
+	}
+	if method, ok := s.Methods[expr.Name.Text]; ok {
+		return method
+	}
+	checker.errorAt(expr.Name, fmt.Sprintf("%s has no field or method %s", s, expr.Name.Text))
+	return Invalid
+}
+
+func (checker *checker) index(expr *ast.IndexExpr) Type {
+	switch typ := checker.expr(expr.Object).(type) {
+	case *Array:
+		checker.intIndex(expr.Index)
+		return typ.Elem
+	case *Map:
+		checker.assign(expr.Index, checker.expr(expr.Index), typ.Key, "as map key")
+		return typ.Value
+	default:
+		checker.intIndex(expr.Index)
+		if typ == String {
+			return Char
+		}
+		if !unchecked(typ) {
+			checker.errorf(expr.Object, "cannot index %s", typ)
+		}
+		return Invalid
+	}
+}
+
+// intIndex checks that expr, an index or slice bound, is an int.
+func (checker *checker) intIndex(expr ast.Expr) {
+	if typ := checker.expr(expr); !assignable(typ, Int) {
+		checker.errorf(expr, "index must be int, found %s", typ)
+	}
+}
+
+// element checks expr, an element of an array or map literal described by
+// what, against typ, the type of the elements before it, and returns the
+// type of the elements up to expr.
+func (checker *checker) element(expr ast.Expr, typ Type, what string) Type {
+	elem := checker.expr(expr)
+	joined, ok := join(typ, elem)
+	if !ok {
+		checker.errorf(expr, "%s has type %s, expected %s", what, elem, typ)
+		return typ
+	}
+	return joined
+}
+
+func (checker *checker) match(expr *ast.MatchExpr) Type {
+	value := checker.expr(expr.Value)
+	result := Type(Invalid)
+	for _, arm := range expr.Arms {
+		for _, pattern := range arm.Patterns {
+			checker.pattern(pattern, value)
+		}
+		result = checker.element(arm.Body, result, "match arm")
+	}
+	return result
+}
+
+// pattern checks that pattern can match a value of type value and records
+// the types of the variables it binds.
+func (checker *checker) pattern(pattern ast.Pattern, value Type) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		checker.comparable(pattern, checker.expr(pattern.Value), value)
+	case *ast.RangePattern:
+		typ, ok := join(checker.expr(pattern.Low), checker.expr(pattern.High))
+		if ok && !numeric(typ) && typ != Char && !unchecked(typ) {
+			checker.errorf(pattern, "range pattern of %s, expected numbers or characters", typ)
+			return
+		}
+		checker.comparable(pattern, typ, value)
+	case *ast.BindingPattern:
+		checker.info.Defs[pattern] = value
+	case *ast.StructPattern:
+		typ := Type(Invalid)
+		if pattern.Obj != nil {
+			typ = checker.named(pattern.Obj)
+		}
+		checker.comparable(pattern, typ, value)
+		s, ok := typ.(*Struct)
+		for _, field := range pattern.Fields {
+			typ := Type(Invalid)
+			if ok {
+				if f := s.field(field.Name.Text); f != nil {
+					typ = f.Type
+				} else {
+					checker.errorAt(field.Name, fmt.Sprintf("%s has no field %s", s, field.Name.Text))
+				}
+			}
+			if field.Pattern == nil {
+				checker.info.Defs[field] = typ
+			} else {
+				checker.pattern(field.Pattern, typ)
+			}
+		}
+	}
+}
+
+// comparable reports pattern if its type typ cannot match a value of type
+// value.
+func (checker *checker) comparable(pattern ast.Pattern, typ, value Type) {
+	if _, ok := join(typ, value); !ok {
+		checker.errorf(pattern, "%s pattern cannot match a value of type %s", typ, value)
+	}
+}
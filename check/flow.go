@@ -0,0 +1,97 @@
+package check
+
+import (
+	"lol/ast"
+	"lol/scan"
+)
+
+// flow tells where control goes after a statement.
+type flow int
+
+const (
+	// next is for statements that can go on to the statement after them.
+	next flow = iota
+	// jump is for statements that always break out of or continue a loop,
+	// or do that on some paths and return on the others.
+	jump
+	// exit is for statements that always return or loop forever.
+	exit
+)
+
+// flow returns where control goes after stmts, and warns about the first
+// statement that cannot be reached because one before it never goes on.
+// The bodies of functions declared in stmts are left to funcDecl.
+func (checker *checker) flow(stmts []ast.Stmt) flow {
+	result := next
+	for _, stmt := range stmts {
+		if result != next {
+			checker.warnf(stmt, "unreachable code")
+			break
+		}
+		result = checker.stmtFlow(stmt)
+	}
+	return result
+}
+
+func (checker *checker) stmtFlow(stmt ast.Stmt) flow {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStmt:
+		return exit
+	case *ast.BranchStmt:
+		return jump
+	case *ast.Block:
+		return checker.flow(stmt.Stmts)
+	case *ast.IfStmt:
+		then := checker.flow(stmt.Then.Stmts)
+		if stmt.Else == nil {
+			return next
+		}
+		switch otherwise := checker.stmtFlow(stmt.Else); {
+		case then == next || otherwise == next:
+			return next
+		case then == exit && otherwise == exit:
+			return exit
+		}
+		return jump
+	case *ast.WhileStmt:
+		checker.flow(stmt.Body.Stmts)
+		// "while true" only ends with a break.
+		if cond, ok := stmt.Condition.(*ast.BoolLit); ok && cond.Value && !breaks(stmt.Body.Stmts) {
+			return exit
+		}
+	case *ast.ForInStmt:
+		checker.flow(stmt.Body.Stmts)
+	}
+	return next
+}
+
+// breaks reports whether stmts break out of the loop they are the body of.
+func breaks(stmts []ast.Stmt) bool {
+	found := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.BranchStmt:
+				found = found || node.Keyword.Type == scan.Break
+			case *ast.WhileStmt, *ast.ForInStmt, *ast.FuncDecl, ast.Expr:
+				// A break in another loop is for that loop, and expressions
+				// hold no statements.
+				return false
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+func (checker *checker) warnf(node ast.Node, msg string) {
+	token := first(node)
+	checker.info.Warnings = append(checker.info.Warnings, Error{
+		Message:     msg,
+		Line:        token.Line,
+		Column:      token.Column,
+		StartOffset: node.Start(),
+		EndOffset:   node.End(),
+		Pos:         token.Pos,
+	})
+}
@@ -0,0 +1,51 @@
+package check
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFlow(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		errors   []string
+		warnings []string
+	}{
+		{"return", "fn f() -> int { return 1 }", nil, nil},
+		{"missing return", "fn f() -> int { }", []string{"missing return at the end of f on line 1"}, nil},
+		{"void", "fn f() { }", nil, nil},
+		{"if without else", "fn f(b: bool) -> int {\n  if b { return 1 }\n}", []string{"missing return at the end of f on line 3"}, nil},
+		{"if and else", "fn f(b: bool) -> int {\n  if b { return 1 } else { return 2 }\n}", nil, nil},
+		{"else if", "fn f(b: bool) -> int {\n  if b { return 1 } else if !b { return 2 }\n}", []string{"missing return at the end of f on line 3"}, nil},
+		{"else if and else", "fn f(b: bool) -> int {\n  if b { return 1 } else if !b { return 2 } else { return 3 }\n}", nil, nil},
+		{"block", "fn f() -> int {\n  { return 1 }\n}", nil, nil},
+		{"while true", "fn f() -> int {\n  while true { }\n}", nil, nil},
+		{"while true with break", "fn f() -> int {\n  while true { break }\n}", []string{"missing return at the end of f on line 3"}, nil},
+		{"break of an inner loop", "fn f() -> int {\n  while true { while true { break } }\n}", nil, nil},
+		{"while condition", "fn f(b: bool) -> int {\n  while b { return 1 }\n}", []string{"missing return at the end of f on line 3"}, nil},
+		{"for in", "fn f() -> int {\n  for x in [1] { return x }\n}", []string{"missing return at the end of f on line 3"}, nil},
+		{"after return", "fn f() -> int {\n  return 1\n  print(2)\n}", nil, []string{"unreachable code on line 3"}},
+		{"only the first unreachable statement", "fn f() {\n  return\n  print(1)\n  print(2)\n}", nil, []string{"unreachable code on line 3"}},
+		{"after break", "while true {\n  break\n  print(1)\n}", nil, []string{"unreachable code on line 3"}},
+		{"after continue in one branch", "while true {\n  if true { continue } else { break }\n  print(1)\n}", nil, []string{"unreachable code on line 3"}},
+		{"after if and else", "fn f(b: bool) -> int {\n  if b { return 1 } else { return 2 }\n  return 3\n}", nil, []string{"unreachable code on line 3"}},
+		{"after while true", "fn f() {\n  while true { }\n  print(1)\n}", nil, []string{"unreachable code on line 3"}},
+		{"nested function", "fn f() -> int {\n  fn g() { return\n    print(1) }\n  return 1\n}", nil, []string{"unreachable code on line 3"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, info, errors := check(t, test.source)
+			if got := messages(errors); !slices.Equal(got, test.errors) {
+				t.Errorf("Check(%q) errors = %q, want %q", test.source, got, test.errors)
+			}
+			var warnings []string
+			for _, warning := range info.Warnings {
+				warnings = append(warnings, warning.Error())
+			}
+			if !slices.Equal(warnings, test.warnings) {
+				t.Errorf("Check(%q) warnings = %q, want %q", test.source, warnings, test.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package check
+
+import (
+	"lol/ast"
+	"strings"
+)
+
+// Type is the type of a value.
+type Type interface {
+	String() string
+}
+
+// Basic is a built-in type.
+type Basic int
+
+const (
+	// Invalid is the type of expressions with an error. It can be used as
+	// any type, so that one mistake is reported only once.
+	Invalid Basic = iota
+	Int
+	Float
+	Double
+	Bool
+	String
+	Char
+	// Void is the result of functions that return nothing.
+	Void
+)
+
+var basicNames = [...]string{
+	Invalid: "invalid",
+	Int:     "int",
+	Float:   "float",
+	Double:  "double",
+	Bool:    "bool",
+	String:  "string",
+	Char:    "char",
+	Void:    "void",
+}
+
+func (basic Basic) String() string {
+	return basicNames[basic]
+}
+
+// Array is the type "[Elem]".
+type Array struct {
+	Elem Type
+}
+
+func (array *Array) String() string {
+	return "[" + array.Elem.String() + "]"
+}
+
+// Map is the type "[Key: Value]".
+type Map struct {
+	Key   Type
+	Value Type
+}
+
+func (m *Map) String() string {
+	return "[" + m.Key.String() + ": " + m.Value.String() + "]"
+}
+
+// Func is the type of a function. If Variadic is set, the last of Params
+// is the type of each of the extra arguments. Required is the number of
+// parameters without a default value.
+type Func struct {
+	Params   []Type
+	Variadic bool
+	Required int
+	Result   Type
+}
+
+func (fn *Func) String() string {
+	params := make([]string, len(fn.Params))
+	for i, param := range fn.Params {
+		params[i] = param.String()
+		if fn.Variadic && i == len(fn.Params)-1 {
+			params[i] = "..." + params[i]
+		}
+	}
+	text := "fn(" + strings.Join(params, ", ") + ")"
+	if fn.Result != Void {
+		text += " -> " + fn.Result.String()
+	}
+	return text
+}
+
+// Struct is the type declared by Decl. The type arguments of a generic
+// struct are not part of its type.
+type Struct struct {
+	Decl    *ast.StructDecl
+	Fields  []*Field
+	Methods map[string]*Func
+}
+
+// Field is a field of a struct.
+type Field struct {
+	Name       string
+	Type       Type
+	HasDefault bool
+}
+
+func (s *Struct) String() string {
+	return s.Decl.Name.Text
+}
+
+func (s *Struct) field(name string) *Field {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// TypeParam is the type of a type parameter. Type parameters are not
+// checked: a value of one can be used as any type and the other way round.
+type TypeParam struct {
+	Decl *ast.TypeParam
+}
+
+func (param *TypeParam) String() string {
+	return param.Decl.Name.Text
+}
+
+// unchecked reports whether typ takes part in no type errors.
+func unchecked(typ Type) bool {
+	_, ok := typ.(*TypeParam)
+	return ok || typ == Invalid
+}
+
+// identical reports whether a and b are the same type.
+func identical(a, b Type) bool {
+	if unchecked(a) || unchecked(b) {
+		return true
+	}
+	switch a := a.(type) {
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && identical(a.Elem, b.Elem)
+	case *Map:
+		b, ok := b.(*Map)
+		return ok && identical(a.Key, b.Key) && identical(a.Value, b.Value)
+	case *Func:
+		b, ok := b.(*Func)
+		if !ok || a.Variadic != b.Variadic || a.Required != b.Required || len(a.Params) != len(b.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !identical(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return identical(a.Result, b.Result)
+	default:
+		return a == b
+	}
+}
+
+// assignable reports whether a value of type value can be used as target:
+// if they are identical, or value is a number that target is wider than.
+func assignable(value, target Type) bool {
+	if value == Void {
+		return target == Invalid
+	}
+	if identical(value, target) {
+		return true
+	}
+	return numeric(value) && numeric(target) && value.(Basic) <= target.(Basic)
+}
+
+// join returns the type that values of types a and b can both be used as,
+// if there is one: the wider of two numbers, or else their type if they
+// are identical.
+func join(a, b Type) (Type, bool) {
+	switch {
+	case a == Invalid:
+		return b, true
+	case b == Invalid:
+		return a, true
+	case numeric(a) && numeric(b):
+		return wider(a, b), true
+	case identical(a, b):
+		return a, true
+	}
+	return Invalid, false
+}
+
+// numeric reports whether typ is int, float or double.
+func numeric(typ Type) bool {
+	return typ == Int || typ == Float || typ == Double
+}
+
+// wider returns the wider of the numeric types a and b.
+func wider(a, b Type) Type {
+	if a == Invalid || b == Invalid {
+		return Invalid
+	}
+	return max(a.(Basic), b.(Basic))
+}
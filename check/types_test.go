@@ -0,0 +1,114 @@
+package check
+
+import (
+	"lol/ast"
+	"lol/scan"
+	"testing"
+)
+
+func TestAssignable(t *testing.T) {
+	point := &Struct{Decl: &ast.StructDecl{Name: scan.Token{Text: "P"}}}
+	param := &TypeParam{Decl: &ast.TypeParam{Name: scan.Token{Text: "T"}}}
+	tests := []struct {
+		value, target Type
+		want          bool
+	}{
+		{Int, Int, true},
+		{Int, Float, true},
+		{Int, Double, true},
+		{Float, Double, true},
+		{Float, Int, false},
+		{Double, Float, false},
+		{Double, Int, false},
+		{Int, String, false},
+		{Char, String, false},
+		{Bool, Int, false},
+		{Invalid, String, true},
+		{String, Invalid, true},
+		{param, Int, true},
+		{Bool, param, true},
+		{Void, Int, false},
+		{Void, Void, false},
+		{Void, Invalid, true},
+		{point, point, true},
+		{point, &Struct{Decl: &ast.StructDecl{Name: scan.Token{Text: "P"}}}, false},
+		{&Array{Elem: Int}, &Array{Elem: Int}, true},
+		// Only numbers widen, not the elements of arrays.
+		{&Array{Elem: Int}, &Array{Elem: Double}, false},
+		{&Map{Key: String, Value: Int}, &Map{Key: String, Value: Int}, true},
+		{&Map{Key: String, Value: Int}, &Map{Key: Int, Value: Int}, false},
+		{&Func{Params: []Type{Int}, Required: 1, Result: Void}, &Func{Params: []Type{Int}, Required: 1, Result: Void}, true},
+		{&Func{Params: []Type{Int}, Required: 1, Result: Void}, &Func{Params: []Type{Int}, Result: Void}, false},
+		{&Func{Params: []Type{Int}, Variadic: true, Result: Int}, &Func{Params: []Type{Int}, Result: Int}, false},
+		{&Func{Result: Int}, &Func{Result: Double}, false},
+	}
+	for _, test := range tests {
+		if got := assignable(test.value, test.target); got != test.want {
+			t.Errorf("assignable(%s, %s) = %t, want %t", test.value, test.target, got, test.want)
+		}
+	}
+}
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		a, b Type
+		want Type
+		ok   bool
+	}{
+		{Int, Int, Int, true},
+		{Int, Float, Float, true},
+		{Double, Int, Double, true},
+		{Float, Double, Double, true},
+		{String, String, String, true},
+		{Invalid, String, String, true},
+		{Bool, Invalid, Bool, true},
+		{Int, String, Invalid, false},
+		{Char, String, Invalid, false},
+		{&Array{Elem: Int}, &Array{Elem: Int}, &Array{Elem: Int}, true},
+		{&Array{Elem: Int}, &Array{Elem: Float}, Invalid, false},
+	}
+	for _, test := range tests {
+		got, ok := join(test.a, test.b)
+		if ok != test.ok || got.String() != test.want.String() {
+			t.Errorf("join(%s, %s) = %s, %t, want %s, %t", test.a, test.b, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestWider(t *testing.T) {
+	tests := []struct {
+		a, b, want Type
+	}{
+		{Int, Int, Int},
+		{Int, Float, Float},
+		{Float, Int, Float},
+		{Int, Double, Double},
+		{Double, Float, Double},
+		{Invalid, Int, Invalid},
+		{Double, Invalid, Invalid},
+	}
+	for _, test := range tests {
+		if got := wider(test.a, test.b); got != test.want {
+			t.Errorf("wider(%s, %s) = %s, want %s", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	tests := []struct {
+		typ  Type
+		want string
+	}{
+		{Int, "int"},
+		{Invalid, "invalid"},
+		{&Array{Elem: String}, "[string]"},
+		{&Map{Key: String, Value: &Array{Elem: Int}}, "[string: [int]]"},
+		{&Func{Result: Void}, "fn()"},
+		{&Func{Params: []Type{Int, String}, Variadic: true, Result: Bool}, "fn(int, ...string) -> bool"},
+	}
+	for _, test := range tests {
+		if got := test.typ.String(); got != test.want {
+			t.Errorf("String() = %q, want %q", got, test.want)
+		}
+	}
+}
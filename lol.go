@@ -3,11 +3,80 @@ package main
 import (
 	"fmt"
 	"lol/scan"
+	"maps"
+	"os"
+	"slices"
 )
 
 func main() {
-	scanner := scan.NewScanner("/**/let x = oo")
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: lol [stats|repro] <file>")
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "stats":
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: lol stats <file>")
+			os.Exit(2)
+		}
+		err = printStats(os.Args[2])
+	case "repro":
+		if len(os.Args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: lol repro <file>")
+			os.Exit(2)
+		}
+		err = printRepro(os.Args[2])
+	default:
+		err = printTokens(os.Args[1])
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printTokens(filename string) error {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	scanner := scan.NewScanner(string(source))
 	tokens, e := scanner.Scan()
-	fmt.Printf("%v\n", tokens)
-	fmt.Printf("%v\n", e)
+	for _, msg := range e {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	return scan.DumpTokens(os.Stdout, tokens)
+}
+
+func printRepro(filename string) error {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	result := scan.Scan(string(source))
+	fmt.Print(scan.ToReproScript(result.Tokens))
+	for _, e := range result.Errors {
+		fmt.Fprintln(os.Stderr, e)
+	}
+	return nil
+}
+
+func printStats(filename string) error {
+	summary, err := Stats(filename)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("lines:  %d\n", summary.Lines)
+	fmt.Printf("tokens: %d\n", summary.Tokens)
+	fmt.Printf("errors: %d\n", summary.Errors)
+	for _, typ := range slices.Sorted(maps.Keys(summary.Counts)) {
+		fmt.Printf("  %-14s %d\n", typ, summary.Counts[typ])
+	}
+	return nil
 }
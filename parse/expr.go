@@ -0,0 +1,520 @@
+package parse
+
+import (
+	"lol/ast"
+	"lol/scan"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// Precedence orders how tightly operators bind: an operator with a higher
+// precedence takes its operands before one with a lower precedence.
+type Precedence int
+
+// The precedence levels of the built-in operators, loosest first:
+//
+//	Assignment  = += -= *= /=   right-associative
+//	Pipeline    |>
+//	Coalesce    ?? ?:
+//	Or          ||
+//	And         &&
+//	Equality    == !=
+//	Comparison  < > <= >=
+//	Term        + - | ^
+//	Factor      * / % &
+//	Prefix      - !            unary, as in "-x"
+//	Power       **             right-associative
+//
+// Calls, field selections and postfix "++" and "--" bind tighter than any
+// operator. Power binds tighter than Prefix, so "-2 ** 2" is "-(2 ** 2)".
+const (
+	LowestPrecedence Precedence = iota
+	AssignmentPrecedence
+	PipelinePrecedence
+	CoalescePrecedence
+	OrPrecedence
+	AndPrecedence
+	EqualityPrecedence
+	ComparisonPrecedence
+	TermPrecedence
+	FactorPrecedence
+	PrefixPrecedence
+	PowerPrecedence
+)
+
+// Operator describes how a binary operator binds. A right-associative
+// operator groups "a op b op c" as "a op (b op c)".
+type Operator struct {
+	Precedence       Precedence
+	RightAssociative bool
+}
+
+var binaryOperators = map[scan.Type]Operator{
+	scan.Assign:           {AssignmentPrecedence, true},
+	scan.PlusAssign:       {AssignmentPrecedence, true},
+	scan.MinusAssign:      {AssignmentPrecedence, true},
+	scan.StarAssign:       {AssignmentPrecedence, true},
+	scan.SlashAssign:      {AssignmentPrecedence, true},
+	scan.PipeForward:      {PipelinePrecedence, false},
+	scan.QuestionQuestion: {CoalescePrecedence, false},
+	scan.Elvis:            {CoalescePrecedence, false},
+	scan.Or:               {OrPrecedence, false},
+	scan.And:              {AndPrecedence, false},
+	scan.Equals:           {EqualityPrecedence, false},
+	scan.NotEquals:        {EqualityPrecedence, false},
+	scan.LeftAngle:        {ComparisonPrecedence, false},
+	scan.RightAngle:       {ComparisonPrecedence, false},
+	scan.LesserEquals:     {ComparisonPrecedence, false},
+	scan.GreaterEquals:    {ComparisonPrecedence, false},
+	scan.Plus:             {TermPrecedence, false},
+	scan.Minus:            {TermPrecedence, false},
+	scan.Pipe:             {TermPrecedence, false},
+	scan.Caret:            {TermPrecedence, false},
+	scan.Star:             {FactorPrecedence, false},
+	scan.Slash:            {FactorPrecedence, false},
+	scan.Percent:          {FactorPrecedence, false},
+	scan.Ampersand:        {FactorPrecedence, false},
+	scan.StarStar:         {PowerPrecedence, true},
+}
+
+var assignments = map[scan.Type]bool{
+	scan.Assign:      true,
+	scan.PlusAssign:  true,
+	scan.MinusAssign: true,
+	scan.StarAssign:  true,
+	scan.SlashAssign: true,
+}
+
+// BinaryOperators returns the binary operators the parser knows by default.
+// The returned map is a copy and may be modified by the caller.
+func BinaryOperators() map[scan.Type]Operator {
+	return maps.Clone(binaryOperators)
+}
+
+// AddOperator makes tokens of type typ a binary operator that binds like op,
+// producing a BinaryExpr. It only affects this parser and can also change
+// how a built-in operator binds.
+func (parser *Parser) AddOperator(typ scan.Type, op Operator) {
+	parser.operators[typ] = op
+}
+
+func (parser *Parser) expression() ast.Expr {
+	return parser.precedence(LowestPrecedence)
+}
+
+// precedence parses an expression whose binary operators all bind tighter
+// than min. After an operand it keeps taking an operator and the operand to
+// its right, which for a left-associative operator may only contain tighter
+// operators and for a right-associative one may contain the same operator
+// again.
+func (parser *Parser) precedence(min Precedence) ast.Expr {
+	left := parser.prefix()
+	for left != nil {
+		op, ok := parser.operators[parser.peek().Type]
+		if !ok || op.Precedence <= min {
+			break
+		}
+		operator := parser.advance()
+		next := op.Precedence
+		if op.RightAssociative {
+			next--
+		}
+		right := parser.precedence(next)
+		if right == nil {
+			return nil
+		}
+		left = parser.infix(left, operator, right)
+	}
+	return left
+}
+
+// infix joins two operands with a binary operator. An assignment to
+// anything but a name, field or element is reported and becomes a BadExpr.
+func (parser *Parser) infix(left ast.Expr, operator scan.Token, right ast.Expr) ast.Expr {
+	if !assignments[operator.Type] {
+		return &ast.BinaryExpr{Left: left, Operator: operator, Right: right}
+	}
+	switch left.(type) {
+	case *ast.Ident, *ast.FieldExpr, *ast.IndexExpr:
+		return &ast.AssignExpr{Target: left, Operator: operator, Value: right}
+	default:
+		parser.err(operator, "invalid assignment target")
+		return &ast.BadExpr{From: left.Start(), To: right.End()}
+	}
+}
+
+// prefix -> ("!" | "-") prefix | postfix
+func (parser *Parser) prefix() ast.Expr {
+	if !parser.match(scan.Bang, scan.Minus) {
+		return parser.postfix()
+	}
+	operator := parser.previous()
+	operand := parser.precedence(PrefixPrecedence)
+	if operand == nil {
+		return nil
+	}
+	return &ast.UnaryExpr{Operator: operator, Operand: operand}
+}
+
+// postfix -> primary ("(" arguments? ")" | "[" index "]" | "." IDENTIFIER
+//
+//	| "<" types ">" | "++" | "--")*
+//
+// A call, index or increment has to start on the line its operand ends on,
+// so that a statement on the next line starting with '(' or '[' is not
+// taken as one.
+func (parser *Parser) postfix() ast.Expr {
+	expr := parser.primary()
+	for expr != nil {
+		switch {
+		case parser.sameLine() && parser.match(scan.LeftParen):
+			expr = parser.call(expr)
+		case parser.sameLine() && parser.match(scan.LeftBracket):
+			expr = parser.index(expr)
+		case parser.sameLine() && parser.check(scan.LeftAngle):
+			instance := parser.instance(expr)
+			if instance == nil {
+				return expr
+			}
+			expr = instance
+		case parser.match(scan.Dot):
+			if !parser.expect(scan.Identifier, "expected field name") {
+				return nil
+			}
+			expr = &ast.FieldExpr{Object: expr, Name: parser.previous()}
+		case parser.sameLine() && parser.match(scan.PlusPlus, scan.MinusMinus):
+			expr = &ast.PostfixExpr{Operand: expr, Operator: parser.previous()}
+		default:
+			return expr
+		}
+	}
+	return nil
+}
+
+// arguments -> expression ("," expression)* ","?
+func (parser *Parser) call(callee ast.Expr) ast.Expr {
+	call := &ast.CallExpr{Callee: callee, Args: make([]ast.Expr, 0)}
+	for !parser.check(scan.RightParen) {
+		arg := parser.expression()
+		if arg == nil {
+			return nil
+		}
+		call.Args = append(call.Args, arg)
+		if !parser.match(scan.Comma) {
+			break
+		}
+	}
+	if !parser.expect(scan.RightParen, "expected ')'") {
+		return nil
+	}
+	call.Close = parser.previous()
+	return call
+}
+
+// instance parses the type arguments after the name of a generic function,
+// as in "f<int>(x)", if the '<' after it starts them. It does if types, a
+// '>' and a '(' follow, so "a < b > (c)" is a call while "a < b" and
+// "a < b > c" stay comparisons. Otherwise it rewinds to the '<' and returns
+// nil, dropping any errors it ran into.
+func (parser *Parser) instance(object ast.Expr) ast.Expr {
+	switch object.(type) {
+	case *ast.Ident, *ast.FieldExpr:
+	default:
+		return nil
+	}
+	current, errors := parser.current, len(parser.errors)
+	open := parser.advance()
+	args := parser.types(scan.RightAngle, "expected '>'")
+	if len(args) > 0 && parser.sameLine() && parser.check(scan.LeftParen) {
+		return &ast.InstanceExpr{Object: object, Open: open, TypeArgs: args, Close: parser.previous()}
+	}
+	parser.current, parser.errors = current, parser.errors[:errors]
+	return nil
+}
+
+// index -> expression | expression? ":" expression?
+func (parser *Parser) index(object ast.Expr) ast.Expr {
+	open := parser.previous()
+	var low ast.Expr
+	if !parser.check(scan.Colon) {
+		if low = parser.expression(); low == nil {
+			return nil
+		}
+	}
+	if !parser.match(scan.Colon) {
+		if !parser.expect(scan.RightBracket, "expected ']'") {
+			return nil
+		}
+		return &ast.IndexExpr{Object: object, Open: open, Index: low, Close: parser.previous()}
+	}
+	slice := &ast.SliceExpr{Object: object, Open: open, Low: low}
+	if !parser.check(scan.RightBracket) {
+		if slice.High = parser.expression(); slice.High == nil {
+			return nil
+		}
+	}
+	if !parser.expect(scan.RightBracket, "expected ']'") {
+		return nil
+	}
+	slice.Close = parser.previous()
+	return slice
+}
+
+// primary -> NUMBER | STRING | RAW_STRING | CHAR | "true" | "false"
+//
+//	| IDENTIFIER | interpolation | "(" expression ")" | array | map | match
+func (parser *Parser) primary() ast.Expr {
+	switch {
+	case parser.match(scan.Number):
+		token := parser.previous()
+		value, err := numberValue(token)
+		if err != nil {
+			parser.err(token, "invalid number")
+			return nil
+		}
+		return &ast.NumberLit{Token: token, Value: value}
+	case parser.match(scan.String, scan.RawString):
+		return &ast.StringLit{Token: parser.previous(), Value: parser.previous().Text}
+	case parser.match(scan.Char):
+		return &ast.CharLit{Token: parser.previous(), Value: []rune(parser.previous().Text)[0]}
+	case parser.match(scan.True, scan.False):
+		return &ast.BoolLit{Token: parser.previous(), Value: parser.previous().Type == scan.True}
+	case parser.match(scan.Identifier):
+		return &ast.Ident{Token: parser.previous()}
+	case parser.match(scan.StringStart):
+		return parser.interpolation()
+	case parser.match(scan.LeftBracket):
+		return parser.array()
+	case parser.match(scan.LeftCurly):
+		return parser.mapLit()
+	case parser.match(scan.Match):
+		return parser.matchExpr()
+	case parser.match(scan.LeftParen):
+		open := parser.previous()
+		expr := parser.expression()
+		if expr == nil {
+			return nil
+		}
+		if !parser.match(scan.RightParen) {
+			parser.err(parser.peek(), "expected ')'")
+			return nil
+		}
+		return &ast.Grouping{Open: open, Expr: expr, Close: parser.previous()}
+	default:
+		// The token is left for the statement to resynchronize on.
+		parser.err(parser.peek(), "expected expression")
+		offset := parser.peek().StartOffset
+		return &ast.BadExpr{From: offset, To: offset}
+	}
+}
+
+// array -> "[" (expression ("," expression)* ","?)? "]"
+func (parser *Parser) array() ast.Expr {
+	array := &ast.ArrayLit{Open: parser.previous(), Elems: make([]ast.Expr, 0)}
+	for !parser.check(scan.RightBracket) {
+		elem := parser.expression()
+		if elem == nil {
+			return nil
+		}
+		array.Elems = append(array.Elems, elem)
+		if !parser.match(scan.Comma) {
+			break
+		}
+	}
+	if !parser.expect(scan.RightBracket, "expected ']'") {
+		return nil
+	}
+	array.Close = parser.previous()
+	return array
+}
+
+// map   -> "{" (entry ("," entry)* ","?)? "}"
+// entry -> expression ":" expression
+//
+// A '{' that starts a statement opens a block, not a map.
+func (parser *Parser) mapLit() ast.Expr {
+	lit := &ast.MapLit{Open: parser.previous(), Entries: make([]*ast.KeyValue, 0)}
+	for !parser.check(scan.RightCurly) {
+		entry := &ast.KeyValue{}
+		if entry.Key = parser.expression(); entry.Key == nil {
+			return nil
+		}
+		if !parser.expect(scan.Colon, "expected ':'") {
+			return nil
+		}
+		if entry.Value = parser.expression(); entry.Value == nil {
+			return nil
+		}
+		lit.Entries = append(lit.Entries, entry)
+		if !parser.match(scan.Comma) {
+			break
+		}
+	}
+	if !parser.expect(scan.RightCurly, "expected '}'") {
+		return nil
+	}
+	lit.Close = parser.previous()
+	return lit
+}
+
+// match -> "match" expression "{" (arm ("," arm)* ","?)? "}"
+// arm   -> (pattern ("|" pattern)* | "else") "=>" expression
+//
+// The else arm is required and has to come last.
+func (parser *Parser) matchExpr() ast.Expr {
+	expr := &ast.MatchExpr{Match: parser.previous(), Arms: make([]*ast.MatchArm, 0)}
+	if expr.Value = parser.expression(); expr.Value == nil {
+		return nil
+	}
+	if !parser.expect(scan.LeftCurly, "expected '{'") {
+		return nil
+	}
+	for !parser.check(scan.RightCurly) {
+		if n := len(expr.Arms); n > 0 && len(expr.Arms[n-1].Patterns) == 0 {
+			parser.err(parser.peek(), "else arm must come last")
+		}
+		arm := &ast.MatchArm{}
+		if parser.match(scan.Else) {
+			arm.Else = parser.previous()
+		} else if arm.Patterns = parser.patterns(); arm.Patterns == nil {
+			return nil
+		}
+		if !parser.expect(scan.FatArrow, "expected '=>'") {
+			return nil
+		}
+		if arm.Body = parser.expression(); arm.Body == nil {
+			return nil
+		}
+		expr.Arms = append(expr.Arms, arm)
+		if !parser.match(scan.Comma) {
+			break
+		}
+	}
+	if !parser.expect(scan.RightCurly, "expected '}'") {
+		return nil
+	}
+	expr.Close = parser.previous()
+	// An else arm that is not last has been reported already.
+	if !slices.ContainsFunc(expr.Arms, func(arm *ast.MatchArm) bool { return len(arm.Patterns) == 0 }) {
+		parser.err(expr.Close, "expected else arm")
+	}
+	return expr
+}
+
+// patterns -> pattern ("|" pattern)*
+func (parser *Parser) patterns() []ast.Pattern {
+	patterns := make([]ast.Pattern, 0)
+	for {
+		pattern := parser.pattern()
+		if pattern == nil {
+			return nil
+		}
+		patterns = append(patterns, pattern)
+		if !parser.match(scan.Pipe) {
+			return patterns
+		}
+	}
+}
+
+// pattern      -> literal (".." literal)? | IDENTIFIER
+//
+//	| IDENTIFIER "{" (fieldPattern ("," fieldPattern)* ","?)? "}"
+//
+// fieldPattern -> IDENTIFIER (":" pattern)?
+func (parser *Parser) pattern() ast.Pattern {
+	if parser.match(scan.Identifier) {
+		name := parser.previous()
+		if !parser.match(scan.LeftCurly) {
+			return &ast.BindingPattern{Name: name}
+		}
+		pattern := &ast.StructPattern{Name: name, Open: parser.previous(), Fields: make([]*ast.FieldPattern, 0)}
+		for !parser.check(scan.RightCurly) {
+			if !parser.expect(scan.Identifier, "expected field name") {
+				return nil
+			}
+			field := &ast.FieldPattern{Name: parser.previous()}
+			if parser.match(scan.Colon) {
+				if field.Pattern = parser.pattern(); field.Pattern == nil {
+					return nil
+				}
+			}
+			pattern.Fields = append(pattern.Fields, field)
+			if !parser.match(scan.Comma) {
+				break
+			}
+		}
+		if !parser.expect(scan.RightCurly, "expected '}'") {
+			return nil
+		}
+		pattern.Close = parser.previous()
+		return pattern
+	}
+	low := parser.literal()
+	if low == nil {
+		return nil
+	}
+	if !parser.match(scan.DotDot) {
+		return &ast.LiteralPattern{Value: low}
+	}
+	pattern := &ast.RangePattern{Low: low, Operator: parser.previous()}
+	if pattern.High = parser.literal(); pattern.High == nil {
+		return nil
+	}
+	return pattern
+}
+
+// literal -> "-"? NUMBER | STRING | RAW_STRING | CHAR | "true" | "false"
+func (parser *Parser) literal() ast.Expr {
+	if parser.match(scan.Minus) {
+		operator := parser.previous()
+		if !parser.check(scan.Number) {
+			parser.err(parser.peek(), "expected number")
+			return nil
+		}
+		operand := parser.primary()
+		if operand == nil {
+			return nil
+		}
+		return &ast.UnaryExpr{Operator: operator, Operand: operand}
+	}
+	switch parser.peek().Type {
+	case scan.Number, scan.String, scan.RawString, scan.Char, scan.True, scan.False:
+		return parser.primary()
+	default:
+		parser.err(parser.peek(), "expected pattern")
+		return nil
+	}
+}
+
+// interpolation -> STRING_START expression (STRING_MID expression)* STRING_END
+func (parser *Parser) interpolation() ast.Expr {
+	segment := parser.previous()
+	str := &ast.InterpolatedString{Parts: []ast.Expr{&ast.StringLit{Token: segment, Value: segment.Text}}}
+	for {
+		expr := parser.expression()
+		if expr == nil {
+			return nil
+		}
+		str.Parts = append(str.Parts, expr)
+		if !parser.match(scan.StringMid, scan.StringEnd) {
+			parser.err(parser.peek(), "expected '}'")
+			return nil
+		}
+		segment = parser.previous()
+		str.Parts = append(str.Parts, &ast.StringLit{Token: segment, Value: segment.Text})
+		if segment.Type == scan.StringEnd {
+			return str
+		}
+	}
+}
+
+// numberValue converts the text of a Number token in the base the scanner
+// recorded for it.
+func numberValue(token scan.Token) (float64, error) {
+	if token.Base == 0 || token.Base == 10 {
+		return strconv.ParseFloat(token.Text, 64)
+	}
+	value, err := strconv.ParseUint(token.Text[2:], token.Base, 64)
+	return float64(value), err
+}
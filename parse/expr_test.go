@@ -0,0 +1,231 @@
+package parse
+
+import (
+	"lol/ast"
+	"lol/scan"
+	"slices"
+	"testing"
+)
+
+func parseExpr(t *testing.T, source string) (ast.Expr, []string) {
+	t.Helper()
+	scanner := scan.NewScanner(source)
+	tokens, scanErrors := scanner.Scan()
+	if len(scanErrors) > 0 {
+		t.Fatalf("scan %q: %v", source, scanErrors)
+	}
+	parser := NewParser(tokens)
+	expr, errors := parser.Parse()
+	return expr, messages(errors)
+}
+
+func messages(errors []error) []string {
+	list := make([]string, len(errors))
+	for i, err := range errors {
+		list[i] = err.Error()
+	}
+	return list
+}
+
+func TestParseArithmetic(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"1", "1"},
+		{"1 + 2 * 3", "(+ 1 (* 2 3))"},
+		{"1 * 2 + 3", "(+ (* 1 2) 3)"},
+		{"1 - 2 - 3", "(- (- 1 2) 3)"},
+		{"8 / 4 / 2", "(/ (/ 8 4) 2)"},
+		{"(1 + 2) * 3", "(* (group (+ 1 2)) 3)"},
+		{"((1))", "(group (group 1))"},
+		{"1 + (2 - 3) / 4", "(+ 1 (/ (group (- 2 3)) 4))"},
+	}
+	for _, test := range tests {
+		expr, errors := parseExpr(t, test.source)
+		if len(errors) > 0 {
+			t.Errorf("parse %q: %q", test.source, errors)
+			continue
+		}
+		if got := ast.Sexpr(expr); got != test.want {
+			t.Errorf("parse %q = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestParseArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"1 +", []string{`expected expression, found end of file on line 1`}},
+		{"(1 + 2", []string{`expected ')', found end of file on line 1`}},
+		{"1\n+\n)", []string{`expected expression, found ")" on line 3`}},
+		{"1 2", []string{`unexpected token, found "2" on line 1`}},
+	}
+	for _, test := range tests {
+		if _, errors := parseExpr(t, test.source); !slices.Equal(errors, test.want) {
+			t.Errorf("parse %q errors = %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"a = b += c", "(= a (+= b c))"},
+		{"a = b |> f", "(= a (|> b f))"},
+		{"a |> f |> g", "(|> (|> a f) g)"},
+		{"a ?? b || c", "(?? a (|| b c))"},
+		{"a || b && c", "(|| a (&& b c))"},
+		{"a && b == c", "(&& a (== b c))"},
+		{"a == b < c", "(== a (< b c))"},
+		{"a < b + c", "(< a (+ b c))"},
+		{"a + b * c", "(+ a (* b c))"},
+		{"a | b & c", "(| a (& b c))"},
+		{"a - b - c", "(- (- a b) c)"},
+		{"a ** b ** c", "(** a (** b c))"},
+		{"-a * b", "(* (- a) b)"},
+		{"-2 ** 2", "(- (** 2 2))"},
+		{"!a && b", "(&& (! a) b)"},
+		{"!!a", "(! (! a))"},
+		{"-(a + b) * c", "(* (- (group (+ a b))) c)"},
+		{"f(a)[0].b + 1", "(+ (. (index (call f a) 0) b) 1)"},
+	}
+	for _, test := range tests {
+		expr, errors := parseExpr(t, test.source)
+		if len(errors) > 0 {
+			t.Errorf("parse %q: %q", test.source, errors)
+			continue
+		}
+		if got := ast.Sexpr(expr); got != test.want {
+			t.Errorf("parse %q = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestAddOperator(t *testing.T) {
+	scanner := scan.NewScanner("a + b ?? c")
+	tokens, _ := scanner.Scan()
+	parser := NewParser(tokens)
+	// Make "??" bind tighter than "+", and right-associative.
+	parser.AddOperator(scan.QuestionQuestion, Operator{Precedence: FactorPrecedence, RightAssociative: true})
+	expr, errors := parser.Parse()
+	if len(errors) > 0 {
+		t.Fatalf("errors: %q", messages(errors))
+	}
+	if got, want := ast.Sexpr(expr), "(+ a (?? b c))"; got != want {
+		t.Errorf("parse = %s, want %s", got, want)
+	}
+	if BinaryOperators()[scan.QuestionQuestion].Precedence != CoalescePrecedence {
+		t.Errorf("AddOperator changed the default operators")
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	expr, errors := parseExpr(t, "1 + a = 2")
+	if want := []string{`invalid assignment target, found "=" on line 1`}; !slices.Equal(errors, want) {
+		t.Errorf("errors = %q, want %q", errors, want)
+	}
+	if _, ok := expr.(*ast.BadExpr); !ok {
+		t.Errorf("parse = %s, want a BadExpr", ast.Sexpr(expr))
+	}
+}
+
+func TestCollections(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"[]", "(array)"},
+		{"[1, 2, 3]", "(array 1 2 3)"},
+		{"[1, [2],]", "(array 1 (array 2))"},
+		{"{}", "(map)"},
+		{`{"a": 1, "b": 2,}`, `(map ("a" 1) ("b" 2))`},
+		{"a[0]", "(index a 0)"},
+		{"a[i + 1][j]", "(index (index a (+ i 1)) j)"},
+		{"a[1:2]", "(slice a 1 2)"},
+		{"a[:2]", "(slice a nil 2)"},
+		{"a[1:]", "(slice a 1 nil)"},
+		{"a[:]", "(slice a nil nil)"},
+		{"[1, 2][0]", "(index (array 1 2) 0)"},
+	}
+	for _, test := range tests {
+		expr, errors := parseExpr(t, test.source)
+		if len(errors) > 0 {
+			t.Errorf("parse %q: %q", test.source, errors)
+			continue
+		}
+		if got := ast.Sexpr(expr); got != test.want {
+			t.Errorf("parse %q = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestCollectionErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"[1, 2", []string{`expected ']', found end of file on line 1`}},
+		{"[1 2]", []string{`expected ']', found "2" on line 1`}},
+		{`{"a" 1}`, []string{`expected ':', found "1" on line 1`}},
+		{`{"a": 1`, []string{`expected '}', found end of file on line 1`}},
+		{"a[1", []string{`expected ']', found end of file on line 1`}},
+		{"a[1:2", []string{`expected ']', found end of file on line 1`}},
+		{"a[]", []string{`expected expression, found "]" on line 1`}},
+	}
+	for _, test := range tests {
+		if _, errors := parseExpr(t, test.source); !slices.Equal(errors, test.want) {
+			t.Errorf("parse %q errors = %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"match x { else => 0 }", "(match x (else 0))"},
+		{"match x { 1 => a, 2 | 3 => b, else => c }", "(match x (arm (patterns 1) a) (arm (patterns 2 3) b) (else c))"},
+		{"match x { 1..9 => a, -5..-1 => b, else => c }", "(match x (arm (patterns (.. 1 9)) a) (arm (patterns (.. (- 5) (- 1))) b) (else c))"},
+		{`match c { 'a'..'z' => 1, "s" => 2, true => 3, else => 4 }`, `(match c (arm (patterns (.. 'a' 'z')) 1) (arm (patterns "s") 2) (arm (patterns true) 3) (else 4))`},
+		{"match x { n => n, else => 0, }", "(match x (arm (patterns n) n) (else 0))"},
+		{"match p { P { x, y: 0 } => x, P {} => 0, else => 1 }", "(match p (arm (patterns (struct-pattern P x (y 0))) x) (arm (patterns (struct-pattern P)) 0) (else 1))"},
+		{"match p { P { q: Q { z } } => z, else => 0 }", "(match p (arm (patterns (struct-pattern P (q (struct-pattern Q z)))) z) (else 0))"},
+	}
+	for _, test := range tests {
+		expr, errors := parseExpr(t, test.source)
+		if len(errors) > 0 {
+			t.Errorf("parse %q: %q", test.source, errors)
+			continue
+		}
+		if got := ast.Sexpr(expr); got != test.want {
+			t.Errorf("parse %q = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"match x { 1 => a }", []string{`expected else arm, found "}" on line 1`}},
+		{"match x { }", []string{`expected else arm, found "}" on line 1`}},
+		{"match x { else => 0, 1 => a }", []string{`else arm must come last, found "1" on line 1`}},
+		{"match x { 1 a }", []string{`expected '=>', found "a" on line 1`}},
+		{"match x 1", []string{`expected '{', found "1" on line 1`}},
+		{"match x { P { 1 } => a, else => 0 }", []string{`expected field name, found "1" on line 1`}},
+		{"match x { - a => 1, else => 0 }", []string{`expected number, found "a" on line 1`}},
+		{"match x { 1 => a, else => 0", []string{`expected '}', found end of file on line 1`}},
+	}
+	for _, test := range tests {
+		if _, errors := parseExpr(t, test.source); !slices.Equal(errors, test.want) {
+			t.Errorf("parse %q errors = %q, want %q", test.source, errors, test.want)
+		}
+	}
+}
@@ -0,0 +1,704 @@
+package parse
+
+import (
+	"fmt"
+	"lol/ast"
+	"lol/scan"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+type Parser struct {
+	tokens    []scan.Token
+	current   int
+	errors    []error
+	operators map[scan.Type]Operator
+	// docs holds the doc comments written before a token, by its index.
+	docs map[int]*ast.CommentGroup
+	// comments holds every comment group, in source order.
+	comments []*ast.CommentGroup
+	// loops counts the loops around the statement being parsed, within the
+	// innermost function.
+	loops int
+}
+
+// Error is a syntax error. Line, Column, the offsets and Pos locate the
+// token the parser found where it expected something else.
+type Error struct {
+	Message     string
+	Line        int
+	Column      int
+	StartOffset int
+	EndOffset   int
+	Pos         scan.Pos
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s on line %d", e.Message, e.Line)
+}
+
+// NewParser returns a parser for tokens. If the scanner kept or attached
+// comments, doc comments end up in the declarations they precede and all
+// comments, grouped, in the Comments of the program.
+func NewParser(tokens []scan.Token) Parser {
+	meaningful := make([]scan.Token, 0, len(tokens))
+	docs := make(map[int]*ast.CommentGroup)
+	var comments []*ast.CommentGroup
+	var doc []scan.Token
+	// grouped is whether the last comment may be followed by another of
+	// its group, which it can't once a meaningful token comes between.
+	grouped := false
+	comment := func(token scan.Token) {
+		if token.Type != scan.Comment && token.Type != scan.DocComment {
+			return
+		}
+		if last := len(comments) - 1; grouped && token.Line <= endLine(comments[last])+1 {
+			comments[last].List = append(comments[last].List, token)
+		} else {
+			comments = append(comments, &ast.CommentGroup{List: []scan.Token{token}})
+		}
+		grouped = true
+		if token.Type == scan.DocComment {
+			doc = append(doc, token)
+		}
+	}
+	for _, token := range tokens {
+		for _, trivia := range token.LeadingTrivia {
+			comment(trivia)
+		}
+		if token.IsTrivia() {
+			comment(token)
+		} else {
+			if len(doc) > 0 {
+				docs[len(meaningful)] = &ast.CommentGroup{List: doc}
+				doc = nil
+			}
+			meaningful = append(meaningful, token)
+			grouped = false
+		}
+		for _, trivia := range token.TrailingTrivia {
+			comment(trivia)
+		}
+	}
+	if len(meaningful) == 0 || meaningful[len(meaningful)-1].Type != scan.EOF {
+		meaningful = append(meaningful, scan.Token{Type: scan.EOF})
+	}
+
+	return Parser{
+		tokens:    meaningful,
+		current:   0,
+		errors:    make([]error, 0),
+		operators: maps.Clone(binaryOperators),
+		docs:      docs,
+		comments:  comments,
+	}
+}
+
+// endLine returns the line the last comment of group ends on.
+func endLine(group *ast.CommentGroup) int {
+	last := group.List[len(group.List)-1]
+	return last.Line + strings.Count(last.Text, "\n")
+}
+
+// Parse parses a single expression spanning all of the tokens. Where the
+// expression has a syntax error it holds a BadExpr; it is nil if tokens
+// are left over after it.
+func (parser *Parser) Parse() (ast.Expr, []error) {
+	expr := parser.expression()
+	if !parser.end() {
+		parser.err(parser.peek(), "unexpected token")
+		expr = nil
+	}
+	return expr, parser.errors
+}
+
+// ParseProgram parses the tokens as a sequence of statements. A statement
+// with a syntax error is replaced by a BadStmt and parsing carries on with
+// the next one, so the errors cover the whole program and not only its
+// first mistake.
+//
+// program -> (statement ";"*)* EOF
+func (parser *Parser) ParseProgram() (*ast.Program, []error) {
+	program := &ast.Program{Stmts: make([]ast.Stmt, 0)}
+	for {
+		program.Stmts = append(program.Stmts, parser.statements()...)
+		if parser.end() {
+			break
+		}
+		// statements stops at a '}' that closes no block.
+		start := parser.current
+		parser.err(parser.advance(), "unexpected token")
+		program.Stmts = append(program.Stmts, parser.badStmt(start))
+	}
+	program.EOF = parser.tokens[len(parser.tokens)-1]
+	program.Comments = parser.comments
+	return program, parser.errors
+}
+
+// statements parses statements up to a '}' or the end of the tokens. After
+// a statement with a syntax error it skips to the next statement boundary,
+// using a BadStmt for the statement if nothing of it could be parsed.
+func (parser *Parser) statements() []ast.Stmt {
+	stmts := make([]ast.Stmt, 0)
+	for parser.match(scan.SemiColon) {
+	}
+	for !parser.check(scan.RightCurly) && !parser.end() {
+		start, errors := parser.current, len(parser.errors)
+		stmt := parser.statement()
+		if parser.current == start {
+			// Only a BadExpr, for a token that cannot start a statement.
+			stmt = nil
+		}
+		if stmt == nil || len(parser.errors) > errors {
+			parser.synchronize(start)
+		}
+		if stmt == nil {
+			stmt = parser.badStmt(start)
+		}
+		stmts = append(stmts, stmt)
+		for parser.match(scan.SemiColon) {
+		}
+	}
+	return stmts
+}
+
+// synchronize skips the rest of a statement that started at the token with
+// index start: up to a ';' or '}', or to the first token on a later line.
+// A '{' opened in the statement is skipped up to its '}' however many lines
+// that takes. At least one token is skipped, so that a statement the parser
+// cannot start does not stop it.
+func (parser *Parser) synchronize(start int) {
+	depth := 0
+	for _, token := range parser.tokens[start:parser.current] {
+		depth += braceDepth(token.Type)
+	}
+	depth = max(depth, 0)
+	for !parser.end() {
+		if parser.current > start && depth == 0 {
+			if parser.previous().Type == scan.SemiColon || !parser.sameLine() ||
+				parser.check(scan.SemiColon) || parser.check(scan.RightCurly) {
+				return
+			}
+		}
+		closing := parser.check(scan.RightCurly)
+		depth = max(depth+braceDepth(parser.advance().Type), 0)
+		if closing && depth == 0 {
+			return
+		}
+	}
+}
+
+// braceDepth returns how a token of type typ changes the nesting of braces.
+func braceDepth(typ scan.Type) int {
+	switch typ {
+	case scan.LeftCurly:
+		return 1
+	case scan.RightCurly:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// badStmt returns a BadStmt for the tokens from index start to the current
+// one.
+func (parser *Parser) badStmt(start int) *ast.BadStmt {
+	from := parser.tokens[start].StartOffset
+	if parser.current == start {
+		return &ast.BadStmt{From: from, To: from}
+	}
+	return &ast.BadStmt{From: from, To: parser.previous().EndOffset}
+}
+
+// statement -> letStmt | ifStmt | forInStmt | whileStmt | branchStmt
+//
+//	| structDecl | funcDecl | returnStmt | block | expression
+func (parser *Parser) statement() ast.Stmt {
+	switch {
+	case parser.match(scan.Let):
+		return parser.letStmt()
+	case parser.match(scan.If):
+		return parser.ifStmt()
+	case parser.match(scan.For):
+		return parser.forInStmt()
+	case parser.match(scan.While):
+		return parser.whileStmt()
+	case parser.match(scan.Break, scan.Continue):
+		return parser.branchStmt()
+	case parser.match(scan.Struct):
+		return parser.structDecl()
+	case parser.match(scan.Fn):
+		return parser.funcDecl()
+	case parser.match(scan.Return):
+		return parser.returnStmt()
+	case parser.check(scan.LeftCurly):
+		if block := parser.block(); block != nil {
+			return block
+		}
+		return nil
+	default:
+		expr := parser.expression()
+		if expr == nil {
+			return nil
+		}
+		return &ast.ExprStmt{Expr: expr}
+	}
+}
+
+// letStmt -> "let" IDENTIFIER (":" type)? "=" expression
+func (parser *Parser) letStmt() ast.Stmt {
+	stmt := &ast.LetStmt{Let: parser.previous()}
+	if !parser.expect(scan.Identifier, "expected variable name") {
+		return nil
+	}
+	stmt.Name = parser.previous()
+	if parser.match(scan.Colon) {
+		if stmt.Type = parser.typeExpr(); stmt.Type == nil {
+			return nil
+		}
+	}
+	if !parser.expect(scan.Assign, "expected '='") {
+		return nil
+	}
+	if stmt.Value = parser.expression(); stmt.Value == nil {
+		return nil
+	}
+	return stmt
+}
+
+// ifStmt -> "if" expression block ("else" (ifStmt | block))?
+func (parser *Parser) ifStmt() ast.Stmt {
+	stmt := &ast.IfStmt{If: parser.previous()}
+	if stmt.Condition = parser.expression(); stmt.Condition == nil {
+		return nil
+	}
+	if stmt.Then = parser.block(); stmt.Then == nil {
+		return nil
+	}
+	if !parser.match(scan.Else) {
+		return stmt
+	}
+	if parser.match(scan.If) {
+		stmt.Else = parser.ifStmt()
+	} else if block := parser.block(); block != nil {
+		stmt.Else = block
+	}
+	if stmt.Else == nil {
+		return nil
+	}
+	return stmt
+}
+
+// forInStmt -> "for" IDENTIFIER "in" expression block
+func (parser *Parser) forInStmt() ast.Stmt {
+	stmt := &ast.ForInStmt{For: parser.previous()}
+	if !parser.expect(scan.Identifier, "expected loop variable") {
+		return nil
+	}
+	stmt.Var = parser.previous()
+	if !parser.expect(scan.In, "expected 'in'") {
+		return nil
+	}
+	if stmt.Iterable = parser.expression(); stmt.Iterable == nil {
+		return nil
+	}
+	if stmt.Body = parser.loopBody(); stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// whileStmt -> "while" expression block
+func (parser *Parser) whileStmt() ast.Stmt {
+	stmt := &ast.WhileStmt{While: parser.previous()}
+	if stmt.Condition = parser.expression(); stmt.Condition == nil {
+		return nil
+	}
+	if stmt.Body = parser.loopBody(); stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// loopBody parses the block of a loop, in which break and continue can be
+// used.
+func (parser *Parser) loopBody() *ast.Block {
+	parser.loops++
+	defer func() { parser.loops-- }()
+	return parser.block()
+}
+
+// branchStmt -> "break" | "continue"
+func (parser *Parser) branchStmt() ast.Stmt {
+	keyword := parser.previous()
+	if parser.loops == 0 {
+		parser.err(keyword, fmt.Sprintf("%s outside of a loop", keyword.Text))
+	}
+	return &ast.BranchStmt{Keyword: keyword}
+}
+
+// structDecl -> "struct" IDENTIFIER typeParams? "{" (field ("," | ";")?)* "}"
+// field      -> IDENTIFIER ":" type ("=" expression)?
+func (parser *Parser) structDecl() ast.Stmt {
+	decl := &ast.StructDecl{Doc: parser.doc(), Struct: parser.previous(), Fields: make([]*ast.Field, 0)}
+	if !parser.expect(scan.Identifier, "expected struct name") {
+		return nil
+	}
+	decl.Name = parser.previous()
+	if parser.match(scan.LeftAngle) {
+		if decl.TypeParams = parser.typeParams(); decl.TypeParams == nil {
+			return nil
+		}
+	}
+	if !parser.expect(scan.LeftCurly, "expected '{'") {
+		return nil
+	}
+	for !parser.check(scan.RightCurly) && !parser.end() {
+		if !parser.expect(scan.Identifier, "expected field name") {
+			return nil
+		}
+		field := &ast.Field{Doc: parser.doc(), Name: parser.previous()}
+		if !parser.expect(scan.Colon, "expected ':'") {
+			return nil
+		}
+		if field.Type = parser.typeExpr(); field.Type == nil {
+			return nil
+		}
+		if parser.match(scan.Assign) {
+			if field.Default = parser.expression(); field.Default == nil {
+				return nil
+			}
+		}
+		decl.Fields = append(decl.Fields, field)
+		if !parser.match(scan.Comma) {
+			parser.match(scan.SemiColon)
+		}
+	}
+	if !parser.expect(scan.RightCurly, "expected '}'") {
+		return nil
+	}
+	decl.Close = parser.previous()
+	return decl
+}
+
+// funcDecl -> "fn" ("(" param ")")? IDENTIFIER typeParams? "(" params? ")"
+//
+//	("->" type)? block
+func (parser *Parser) funcDecl() ast.Stmt {
+	decl := &ast.FuncDecl{Doc: parser.doc(), Fn: parser.previous()}
+	if parser.match(scan.LeftParen) {
+		if decl.Recv = parser.param(); decl.Recv == nil {
+			return nil
+		}
+		if !parser.expect(scan.RightParen, "expected ')'") {
+			return nil
+		}
+	}
+	if !parser.expect(scan.Identifier, "expected function name") {
+		return nil
+	}
+	decl.Name = parser.previous()
+	if parser.match(scan.LeftAngle) {
+		if decl.TypeParams = parser.typeParams(); decl.TypeParams == nil {
+			return nil
+		}
+	}
+	if !parser.expect(scan.LeftParen, "expected '('") {
+		return nil
+	}
+	if decl.Params = parser.params(); decl.Params == nil {
+		return nil
+	}
+	if parser.match(scan.Arrow) {
+		if decl.Result = parser.typeExpr(); decl.Result == nil {
+			return nil
+		}
+	}
+	// A loop around the function does not let its body break out of it.
+	loops := parser.loops
+	parser.loops = 0
+	decl.Body = parser.block()
+	parser.loops = loops
+	if decl.Body == nil {
+		return nil
+	}
+	return decl
+}
+
+// params -> parameter ("," parameter)* ","? ")"
+// parameter -> IDENTIFIER ":" "..."? type ("=" expression)?
+//
+// Only the last parameter can be variadic, which it cannot be with a
+// default, and a parameter with a default can only be followed by others
+// with one.
+func (parser *Parser) params() []*ast.Param {
+	params := make([]*ast.Param, 0)
+	for !parser.check(scan.RightParen) {
+		if !parser.expect(scan.Identifier, "expected parameter name") {
+			return nil
+		}
+		param := &ast.Param{Name: parser.previous()}
+		if !parser.expect(scan.Colon, "expected ':'") {
+			return nil
+		}
+		param.Variadic = parser.match(scan.Ellipsis)
+		if param.Type = parser.typeExpr(); param.Type == nil {
+			return nil
+		}
+		if parser.match(scan.Assign) {
+			if param.Variadic {
+				parser.err(parser.previous(), "variadic parameter cannot have a default")
+			}
+			if param.Default = parser.expression(); param.Default == nil {
+				return nil
+			}
+		}
+		if len(params) > 0 {
+			switch last := params[len(params)-1]; {
+			case last.Variadic:
+				parser.err(last.Name, "only the last parameter can be variadic")
+			case last.Default != nil && param.Default == nil && !param.Variadic:
+				parser.err(param.Name, "expected default value after a parameter with one")
+			}
+		}
+		params = append(params, param)
+		if !parser.match(scan.Comma) {
+			break
+		}
+	}
+	if !parser.expect(scan.RightParen, "expected ')'") {
+		return nil
+	}
+	return params
+}
+
+// typeParams -> "<" typeParam ("," typeParam)* ","? ">"
+// typeParam  -> IDENTIFIER (":" type)?
+func (parser *Parser) typeParams() []*ast.TypeParam {
+	params := make([]*ast.TypeParam, 0)
+	for {
+		if !parser.expect(scan.Identifier, "expected type parameter") {
+			return nil
+		}
+		param := &ast.TypeParam{Name: parser.previous()}
+		if parser.match(scan.Colon) {
+			if param.Constraint = parser.typeExpr(); param.Constraint == nil {
+				return nil
+			}
+		}
+		params = append(params, param)
+		if !parser.match(scan.Comma) || parser.check(scan.RightAngle) {
+			break
+		}
+	}
+	if !parser.expect(scan.RightAngle, "expected '>'") {
+		return nil
+	}
+	return params
+}
+
+// param -> IDENTIFIER ":" type
+func (parser *Parser) param() *ast.Param {
+	if !parser.expect(scan.Identifier, "expected parameter name") {
+		return nil
+	}
+	param := &ast.Param{Name: parser.previous()}
+	if !parser.expect(scan.Colon, "expected ':'") {
+		return nil
+	}
+	if param.Type = parser.typeExpr(); param.Type == nil {
+		return nil
+	}
+	return param
+}
+
+// returnStmt -> "return" expression?
+//
+// The value has to start on the same line as "return".
+func (parser *Parser) returnStmt() ast.Stmt {
+	stmt := &ast.ReturnStmt{Return: parser.previous()}
+	if parser.check(scan.RightCurly) || parser.check(scan.SemiColon) || parser.end() || !parser.sameLine() {
+		return stmt
+	}
+	if stmt.Value = parser.expression(); stmt.Value == nil {
+		return nil
+	}
+	return stmt
+}
+
+// block -> "{" (statement ";"*)* "}"
+func (parser *Parser) block() *ast.Block {
+	if !parser.expect(scan.LeftCurly, "expected '{'") {
+		return nil
+	}
+	block := &ast.Block{Open: parser.previous()}
+	block.Stmts = parser.statements()
+	if !parser.expect(scan.RightCurly, "expected '}'") {
+		return nil
+	}
+	block.Close = parser.previous()
+	return block
+}
+
+// type -> "int" | "double" | "float" | "bool" | IDENTIFIER ("<" types ">")?
+//
+//	| "[" type "]" | "[" type ":" type "]" | "fn" "(" types? ")" ("->" type)?
+func (parser *Parser) typeExpr() ast.TypeExpr {
+	switch {
+	case parser.match(scan.Int, scan.Double, scan.Float, scan.Bool):
+		return &ast.TypeName{Name: parser.previous()}
+	case parser.match(scan.Identifier):
+		name := parser.previous()
+		if !parser.match(scan.LeftAngle) {
+			return &ast.TypeName{Name: name}
+		}
+		generic := &ast.GenericType{Name: name, Open: parser.previous()}
+		if parser.check(scan.RightAngle) {
+			parser.err(parser.peek(), "expected type")
+			return nil
+		}
+		if generic.Args = parser.types(scan.RightAngle, "expected '>'"); generic.Args == nil {
+			return nil
+		}
+		generic.Close = parser.previous()
+		return generic
+	case parser.match(scan.LeftBracket):
+		return parser.collectionType()
+	case parser.match(scan.Fn):
+		fn := &ast.FuncType{Fn: parser.previous()}
+		if !parser.expect(scan.LeftParen, "expected '('") {
+			return nil
+		}
+		if fn.Params = parser.types(scan.RightParen, "expected ')'"); fn.Params == nil {
+			return nil
+		}
+		fn.Close = parser.previous()
+		if parser.match(scan.Arrow) {
+			if fn.Result = parser.typeExpr(); fn.Result == nil {
+				return nil
+			}
+		}
+		return fn
+	default:
+		parser.err(parser.peek(), "expected type")
+		return nil
+	}
+}
+
+// collectionType parses an array or map type after its '['.
+func (parser *Parser) collectionType() ast.TypeExpr {
+	open := parser.previous()
+	elem := parser.typeExpr()
+	if elem == nil {
+		return nil
+	}
+	if !parser.match(scan.Colon) {
+		if !parser.expect(scan.RightBracket, "expected ']'") {
+			return nil
+		}
+		return &ast.ArrayType{Open: open, Elem: elem, Close: parser.previous()}
+	}
+	value := parser.typeExpr()
+	if value == nil || !parser.expect(scan.RightBracket, "expected ']'") {
+		return nil
+	}
+	return &ast.MapType{Open: open, Key: elem, Value: value, Close: parser.previous()}
+}
+
+// types -> type ("," type)* ","?
+//
+// types parses types up to and including a closing token of type end,
+// reporting msg if it is missing.
+func (parser *Parser) types(end scan.Type, msg string) []ast.TypeExpr {
+	types := make([]ast.TypeExpr, 0)
+	for !parser.check(end) {
+		typ := parser.typeExpr()
+		if typ == nil {
+			return nil
+		}
+		types = append(types, typ)
+		if !parser.match(scan.Comma) {
+			break
+		}
+	}
+	if !parser.expect(end, msg) {
+		return nil
+	}
+	return types
+}
+
+// doc returns the doc comments written right before the previous token,
+// or nil.
+func (parser *Parser) doc() *ast.CommentGroup {
+	return parser.docs[parser.current-1]
+}
+
+// err reports a syntax error at token. Only the first error at a token is
+// kept, as the ones after it tend to follow from it.
+func (parser *Parser) err(token scan.Token, msg string) {
+	if n := len(parser.errors); n > 0 && parser.errors[n-1].(Error).StartOffset == token.StartOffset {
+		return
+	}
+	found := strconv.Quote(token.Text)
+	if token.Type == scan.EOF {
+		found = "end of file"
+	}
+	parser.errors = append(parser.errors, Error{
+		Message:     fmt.Sprintf("%s, found %s", msg, found),
+		Line:        token.Line,
+		Column:      token.Column,
+		StartOffset: token.StartOffset,
+		EndOffset:   token.EndOffset,
+		Pos:         token.Pos,
+	})
+}
+
+// expect consumes a token of type typ, or reports msg and returns false if
+// the next token has another type.
+func (parser *Parser) expect(typ scan.Type, msg string) bool {
+	if parser.match(typ) {
+		return true
+	}
+	parser.err(parser.peek(), msg)
+	return false
+}
+
+func (parser *Parser) match(types ...scan.Type) bool {
+	for _, typ := range types {
+		if parser.peek().Type == typ {
+			parser.advance()
+			return true
+		}
+	}
+	return false
+}
+
+func (parser *Parser) check(typ scan.Type) bool {
+	return parser.peek().Type == typ
+}
+
+// sameLine reports whether the next token starts on the line where the
+// previous one does.
+func (parser *Parser) sameLine() bool {
+	return parser.current > 0 && parser.peek().Line == parser.previous().Line
+}
+
+func (parser *Parser) advance() scan.Token {
+	if !parser.end() {
+		parser.current++
+	}
+	return parser.previous()
+}
+
+func (parser *Parser) previous() scan.Token {
+	return parser.tokens[parser.current-1]
+}
+
+func (parser *Parser) peek() scan.Token {
+	return parser.tokens[parser.current]
+}
+
+func (parser *Parser) end() bool {
+	return parser.peek().Type == scan.EOF
+}
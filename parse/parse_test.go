@@ -0,0 +1,280 @@
+package parse
+
+import (
+	"lol/ast"
+	"lol/scan"
+	"slices"
+	"testing"
+)
+
+func parseProgram(t *testing.T, source string) (*ast.Program, []error) {
+	t.Helper()
+	scanner := scan.NewScanner(source)
+	tokens, scanErrors := scanner.Scan()
+	if len(scanErrors) > 0 {
+		t.Fatalf("scan %q: %v", source, scanErrors)
+	}
+	parser := NewParser(tokens)
+	return parser.ParseProgram()
+}
+
+func TestParseStatements(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"let a = 1", "(program (let a 1))"},
+		{"let a: int = 1 + 2", "(program (let a int (+ 1 2)))"},
+		{"if a < 3 { print(a) }", "(program (if (< a 3) (block (call print a))))"},
+		{"if a { b } else if c { d } else { e }", "(program (if a (block b) (if c (block d) (block e))))"},
+		{"for x in xs { print(x) }", "(program (for x xs (block (call print x))))"},
+		{"while true { break }", "(program (while true (block (break))))"},
+		{"struct P { x: int, y: float = 1.5 }", "(program (struct P (x int) (y float 1.5)))"},
+		{"fn f(a: int) -> int { return a * 2 }", "(program (fn f (params (a int)) int (block (return (* a 2)))))"},
+		{"fn f() { return }", "(program (fn f (params) (block (return))))"},
+		{"a = b = 2", "(program (= a (= b 2)))"},
+		{"let a = 1; let b = 2\nprint(a)", "(program (let a 1) (let b 2) (call print a))"},
+	}
+	for _, test := range tests {
+		program, errors := parseProgram(t, test.source)
+		if len(errors) > 0 {
+			t.Errorf("parse %q: %q", test.source, messages(errors))
+			continue
+		}
+		if got := ast.Sexpr(program); got != test.want {
+			t.Errorf("parse %q = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestParseProgramErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		errors []string
+	}{
+		{"let = 1\nlet b = 2", "(program (bad-stmt) (let b 2))", []string{`expected variable name, found "=" on line 1`}},
+		{"struct { }\nlet d = 1", "(program (bad-stmt) (let d 1))", []string{`expected struct name, found "{" on line 1`}},
+		{"return 1 +\nlet e = 2", "(program (return (+ 1 (bad-expr))) (let e 2))", []string{`expected expression, found "let" on line 2`}},
+		{"if a {\nlet c = 3", "(program (bad-stmt))", []string{`expected '}', found end of file on line 2`}},
+		// Every broken statement is reported, not only the first.
+		{"let = 1\nlet x 2\nlet y = 3", "(program (bad-stmt) (bad-stmt) (let y 3))",
+			[]string{`expected variable name, found "=" on line 1`, `expected '=', found "2" on line 2`}},
+	}
+	for _, test := range tests {
+		program, errors := parseProgram(t, test.source)
+		if got := ast.Sexpr(program); got != test.want {
+			t.Errorf("parse %q = %s, want %s", test.source, got, test.want)
+		}
+		if got := messages(errors); !slices.Equal(got, test.errors) {
+			t.Errorf("parse %q errors = %q, want %q", test.source, got, test.errors)
+		}
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	source := "let a = 1\nlet b = (a +\n  )"
+	_, errors := parseProgram(t, source)
+	if len(errors) != 1 {
+		t.Fatalf("errors = %q, want one", messages(errors))
+	}
+	err := errors[0].(Error)
+	if err.Line != 3 || err.Column != 3 || err.StartOffset != 25 || err.EndOffset != 26 {
+		t.Errorf("error at %d:%d [%d, %d), want 3:3 [25, 26)", err.Line, err.Column, err.StartOffset, err.EndOffset)
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		errors []string
+	}{
+		// A ';' ends the broken statement.
+		{"let a = ); let b = 1", "(program (let a (bad-expr)) (let b 1))", []string{`expected expression, found ")" on line 1`}},
+		// So does the end of the line.
+		{"let a = (1 1\nlet b = 2", "(program (bad-stmt) (let b 2))", []string{`expected ')', found "1" on line 1`}},
+		// A '}' ends a broken statement in a block, and the block goes on.
+		{"if a { let = }\nlet b = 2", "(program (if a (block (bad-stmt))) (let b 2))", []string{`expected variable name, found "=" on line 1`}},
+		// Braces opened in a broken statement are skipped as a whole.
+		{"let = fn {\n let c = 1\n}\nlet b = 2", "(program (bad-stmt) (let b 2))", []string{`expected variable name, found "=" on line 1`}},
+		// A stray '}' is reported and skipped.
+		{"let a = 1 }\nlet b = 2", "(program (let a 1) (bad-stmt) (let b 2))", []string{`unexpected token, found "}" on line 1`}},
+		{") let a = 1\nlet b = 2", "(program (bad-stmt) (let b 2))", []string{`expected expression, found ")" on line 1`}},
+		// All errors are reported in one pass.
+		{"let a = )\nlet b = ]\nlet c = 3\nlet = 4", "(program (let a (bad-expr)) (let b (bad-expr)) (let c 3) (bad-stmt))", []string{
+			`expected expression, found ")" on line 1`,
+			`expected expression, found "]" on line 2`,
+			`expected variable name, found "=" on line 4`,
+		}},
+	}
+	for _, test := range tests {
+		program, errors := parseProgram(t, test.source)
+		if got := ast.Sexpr(program); got != test.want {
+			t.Errorf("parse %q = %s, want %s", test.source, got, test.want)
+		}
+		if got := messages(errors); !slices.Equal(got, test.errors) {
+			t.Errorf("parse %q errors = %q, want %q", test.source, got, test.errors)
+		}
+	}
+}
+
+func FuzzParseProgram(f *testing.F) {
+	for _, seed := range []string{
+		"let a = 1 + 2 * 3",
+		"fn f<T>(a: int = 1, b: ...T) -> [T] { return [a] }",
+		"struct P { x: int = 1 }\nfn (p: P) m() {}",
+		"match x { 1..2 | 3 => a, P { x: y } => y, else => 0 }",
+		"while true { if a { break } else { continue } }",
+		"let = ) } { ( [ fn struct match",
+		"let m = {\"a\": [1, 2][0:1]}",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		scanner := scan.NewScanner(source)
+		tokens, _ := scanner.Scan()
+		parser := NewParser(tokens)
+		program, _ := parser.ParseProgram()
+		// Every node must lie within the source.
+		ast.Inspect(program, func(node ast.Node) bool {
+			if node == nil {
+				return false
+			}
+			if node.Start() < 0 || node.Start() > node.End() || node.End() > len(source) {
+				t.Errorf("%T spans [%d, %d) in a source of %d bytes", node, node.Start(), node.End(), len(source))
+			}
+			return true
+		})
+	})
+}
+
+type programTest struct {
+	source string
+	want   string
+	errors []string
+}
+
+// testPrograms parses each source and compares the tree, as an
+// S-expression, and the errors with the expected ones.
+func testPrograms(t *testing.T, tests []programTest) {
+	t.Helper()
+	for _, test := range tests {
+		program, errors := parseProgram(t, test.source)
+		if got := ast.Sexpr(program); got != test.want {
+			t.Errorf("parse %q =\n%s\nwant\n%s", test.source, got, test.want)
+		}
+		if got := messages(errors); !slices.Equal(got, test.errors) {
+			t.Errorf("parse %q errors = %q, want %q", test.source, got, test.errors)
+		}
+	}
+}
+
+func TestStructs(t *testing.T) {
+	testPrograms(t, []programTest{
+		{"struct Point { x: int; y: int }", "(program (struct Point (x int) (y int)))", nil},
+		{"struct Point {\n  x: int\n  y: float = 1.5,\n}", "(program (struct Point (x int) (y float 1.5)))", nil},
+		{"struct Empty {}", "(program (struct Empty))", nil},
+		{"fn (p: Point) dist() -> float { return p.x }", "(program (fn dist (recv (p Point)) (params) float (block (return (. p x)))))", nil},
+		{"struct P { x }", "(program (bad-stmt))", []string{`expected ':', found "}" on line 1`}},
+		{"struct P { x: int = }", "(program (struct P (x int (bad-expr))))", []string{`expected expression, found "}" on line 1`}},
+		{"fn (p: P m() {}", "(program (bad-stmt))", []string{`expected ')', found "m" on line 1`}},
+	})
+}
+
+func TestDocComments(t *testing.T) {
+	source := `/// A point in the plane.
+/// Both coordinates are integers.
+struct Point {
+	/// The horizontal coordinate.
+	x: int
+	y: int // not a doc comment
+}
+
+// Not a doc comment either.
+fn f() {}
+
+/** Distance from the origin. */
+fn (p: Point) norm() -> int { return p.x }
+`
+	options := scan.DefaultOptions()
+	options.KeepComments = true
+	scanner := scan.NewScannerWithOptions(source, options)
+	tokens, _ := scanner.Scan()
+	parser := NewParser(tokens)
+	program, errors := parser.ParseProgram()
+	if len(errors) > 0 {
+		t.Fatalf("errors: %q", messages(errors))
+	}
+	point := program.Stmts[0].(*ast.StructDecl)
+	docs := []struct {
+		group *ast.CommentGroup
+		want  string
+	}{
+		{point.Doc, "A point in the plane.\nBoth coordinates are integers."},
+		{point.Fields[0].Doc, "The horizontal coordinate."},
+		{point.Fields[1].Doc, ""},
+		{program.Stmts[1].(*ast.FuncDecl).Doc, ""},
+		{program.Stmts[2].(*ast.FuncDecl).Doc, "Distance from the origin."},
+	}
+	for i, doc := range docs {
+		if got := doc.group.Text(); got != doc.want {
+			t.Errorf("doc %d = %q, want %q", i, got, doc.want)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	testPrograms(t, []programTest{
+		{"fn add(a: int, b: int) -> int { return a + b }\nprint(add(1, 2))",
+			"(program (fn add (params (a int) (b int)) int (block (return (+ a b)))) (call print (call add 1 2)))", nil},
+		{"fn f() {}", "(program (fn f (params) (block)))", nil},
+		{"fn f(a: int,) {}\nf(1,)", "(program (fn f (params (a int)) (block)) (call f 1))", nil},
+		{"fn f(a: int, b: int = 2, c: int = a) {}", "(program (fn f (params (a int) (b int 2) (c int a)) (block)))", nil},
+		{"fn f(a: int, rest: ...string) {}", "(program (fn f (params (a int) (rest ... string)) (block)))", nil},
+		{"fn f(a: int = 1, rest: ...int) {}", "(program (fn f (params (a int 1) (rest ... int)) (block)))", nil},
+		{"fn f(rest: ...int, a: int) {}", "(program (fn f (params (rest ... int) (a int)) (block)))",
+			[]string{`only the last parameter can be variadic, found "rest" on line 1`}},
+		{"fn f(rest: ...int = 1) {}", "(program (fn f (params (rest ... int 1)) (block)))",
+			[]string{`variadic parameter cannot have a default, found "=" on line 1`}},
+		{"fn f(a: int = 1, b: int) {}", "(program (fn f (params (a int 1) (b int)) (block)))",
+			[]string{`expected default value after a parameter with one, found "b" on line 1`}},
+		{"fn f(a) {}", "(program (bad-stmt))", []string{`expected ':', found ")" on line 1`}},
+		{"fn (a: int) {}", "(program (bad-stmt))", []string{`expected function name, found "{" on line 1`}},
+		{"let g: fn(int, string) -> bool = f", "(program (let g (fn-type (params int string) bool) f))", nil},
+	})
+}
+
+func TestGenerics(t *testing.T) {
+	testPrograms(t, []programTest{
+		{"struct List<T> { items: [T] }", "(program (struct List (type-params T) (items (array-of T))))", nil},
+		{"struct Pair<K: Ord, V,> { key: K, value: V }", "(program (struct Pair (type-params (K Ord) V) (key K) (value V)))", nil},
+		{"fn first<T>(list: List<T>) -> T { return list.items[0] }",
+			"(program (fn first (type-params T) (params (list (generic List T))) T (block (return (index (. list items) 0)))))", nil},
+		{"let m: Map<string, [int]> = x", "(program (let m (generic Map string (array-of int)) x))", nil},
+		{"print(a < b)", "(program (call print (< a b)))", nil},
+		{"print(a < b > c)", "(program (call print (> (< a b) c)))", nil},
+		{"print(a < b > (c))", "(program (call print (call (instance a b) c)))", nil},
+		{"print(List<int>(1))", "(program (call print (call (instance List int) 1)))", nil},
+		{"print(p.make<int, string>(1))", "(program (call print (call (instance (. p make) int string) 1)))", nil},
+		{"fn f<>() {}", "(program (bad-stmt))", []string{`expected type parameter, found ">" on line 1`}},
+		{"struct S<T { }", "(program (bad-stmt))", []string{`expected '>', found "{" on line 1`}},
+		{"fn f<T: >() {}", "(program (bad-stmt))", []string{`expected type, found ">" on line 1`}},
+	})
+}
+
+func TestLoops(t *testing.T) {
+	testPrograms(t, []programTest{
+		{"while i < 3 { i = i + 1 }", "(program (while (< i 3) (block (= i (+ i 1)))))", nil},
+		{"while true { if done { break }\ncontinue }", "(program (while true (block (if done (block (break))) (continue))))", nil},
+		{"for x in xs { while x > 0 { break }\ncontinue }", "(program (for x xs (block (while (> x 0) (block (break))) (continue))))", nil},
+		{"break", "(program (break))", []string{`break outside of a loop, found "break" on line 1`}},
+		{"if a { continue }", "(program (if a (block (continue))))", []string{`continue outside of a loop, found "continue" on line 1`}},
+		// A function body is not part of the loop around its declaration.
+		{"while true { fn f() { break } }", "(program (while true (block (fn f (params) (block (break))))))", []string{`break outside of a loop, found "break" on line 1`}},
+		{"while true { fn f() {}\nbreak }", "(program (while true (block (fn f (params) (block)) (break))))", nil},
+		// The braces are taken as an empty map for the condition.
+		{"while { }", "(program (bad-stmt))", []string{`expected '{', found end of file on line 1`}},
+		{"while true", "(program (bad-stmt))", []string{`expected '{', found end of file on line 1`}},
+	})
+}
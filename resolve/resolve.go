@@ -0,0 +1,377 @@
+// Package resolve binds the names used in a program to their declarations.
+package resolve
+
+import (
+	"fmt"
+	"lol/ast"
+	"lol/scan"
+)
+
+// Error is a name that is not declared, declared twice or used before its
+// declaration. Line, Column, the offsets and Pos locate the name.
+type Error struct {
+	Message     string
+	Line        int
+	Column      int
+	StartOffset int
+	EndOffset   int
+	Pos         scan.Pos
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s on line %d", e.Message, e.Line)
+}
+
+// Universe is the scope of the built-in names, around the scope of every
+// program.
+var Universe = func() *ast.Scope {
+	scope := ast.NewScope(nil)
+	for _, name := range []string{"string", "char"} {
+		scope.Insert(&ast.Object{Kind: ast.Typ, Name: scan.Token{Text: name}})
+	}
+	for _, name := range []string{"print", "len"} {
+		scope.Insert(&ast.Object{Kind: ast.Fun, Name: scan.Token{Text: name}})
+	}
+	return scope
+}()
+
+// Resolve builds the scopes of program and sets the Obj of every Ident,
+// TypeName, GenericType and StructPattern in it to the object it names, or
+// leaves it nil if the name cannot be resolved. It returns the scope of the
+// program, whose Outer is Universe.
+//
+// Functions and structs can be used anywhere in the block declaring them,
+// variables only after their declaration. The body of a function is
+// resolved after the rest of its block, so it can use all of the block's
+// variables.
+func Resolve(program *ast.Program) (*ast.Scope, []error) {
+	resolver := &resolver{
+		scope:   ast.NewScope(Universe),
+		later:   make(map[*ast.Scope]map[string]scan.Token),
+		methods: make(map[*ast.Object]map[string]bool),
+		errors:  make([]error, 0),
+	}
+	scope := resolver.scope
+	resolver.stmts(program.Stmts)
+	return scope, resolver.errors
+}
+
+type resolver struct {
+	scope *ast.Scope
+	// later holds the variables of the blocks being resolved that are
+	// declared further on, by scope and name.
+	later map[*ast.Scope]map[string]scan.Token
+	// methods holds the methods of each struct by name.
+	methods map[*ast.Object]map[string]bool
+	errors  []error
+}
+
+// stmts resolves the statements of a block in the current scope.
+func (resolver *resolver) stmts(stmts []ast.Stmt) {
+	later := make(map[string]scan.Token)
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.FuncDecl:
+			if stmt.Recv == nil {
+				resolver.declare(ast.Fun, stmt.Name, stmt)
+			}
+		case *ast.StructDecl:
+			resolver.declare(ast.Typ, stmt.Name, stmt)
+		case *ast.LetStmt:
+			if _, ok := later[stmt.Name.Text]; !ok {
+				later[stmt.Name.Text] = stmt.Name
+			}
+		}
+	}
+	resolver.later[resolver.scope] = later
+
+	var bodies []func()
+	for _, stmt := range stmts {
+		if decl, ok := stmt.(*ast.FuncDecl); ok {
+			bodies = append(bodies, resolver.funcDecl(decl))
+			continue
+		}
+		resolver.stmt(stmt)
+	}
+	delete(resolver.later, resolver.scope)
+	for _, body := range bodies {
+		body()
+	}
+}
+
+func (resolver *resolver) stmt(stmt ast.Stmt) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStmt:
+		if stmt.Type != nil {
+			resolver.typeExpr(stmt.Type)
+		}
+		resolver.expr(stmt.Value)
+		resolver.declare(ast.Var, stmt.Name, stmt)
+	case *ast.IfStmt:
+		resolver.expr(stmt.Condition)
+		resolver.block(stmt.Then)
+		if stmt.Else != nil {
+			resolver.stmt(stmt.Else)
+		}
+	case *ast.ForInStmt:
+		resolver.expr(stmt.Iterable)
+		resolver.open()
+		resolver.declare(ast.Var, stmt.Var, stmt)
+		resolver.block(stmt.Body)
+		resolver.close()
+	case *ast.WhileStmt:
+		resolver.expr(stmt.Condition)
+		resolver.block(stmt.Body)
+	case *ast.StructDecl:
+		resolver.open()
+		resolver.typeParams(stmt.TypeParams)
+		fields := make(map[string]bool)
+		for _, field := range stmt.Fields {
+			resolver.typeExpr(field.Type)
+			if field.Default != nil {
+				resolver.expr(field.Default)
+			}
+			if fields[field.Name.Text] {
+				resolver.err(field.Name, fmt.Sprintf("field %s is already declared", field.Name.Text))
+			}
+			fields[field.Name.Text] = true
+		}
+		resolver.close()
+	case *ast.ReturnStmt:
+		if stmt.Value != nil {
+			resolver.expr(stmt.Value)
+		}
+	case *ast.Block:
+		resolver.block(stmt)
+	case *ast.ExprStmt:
+		resolver.expr(stmt.Expr)
+	}
+}
+
+func (resolver *resolver) block(block *ast.Block) {
+	resolver.open()
+	resolver.stmts(block.Stmts)
+	resolver.close()
+}
+
+// funcDecl resolves the signature of decl and returns a function that
+// resolves its body. The parameters are in the same scope as the body's
+// variables.
+func (resolver *resolver) funcDecl(decl *ast.FuncDecl) func() {
+	resolver.open()
+	resolver.typeParams(decl.TypeParams)
+	if decl.Recv != nil {
+		resolver.param(decl.Recv)
+		resolver.method(decl)
+	}
+	for _, param := range decl.Params {
+		resolver.param(param)
+	}
+	if decl.Result != nil {
+		resolver.typeExpr(decl.Result)
+	}
+	scope := resolver.scope
+	resolver.close()
+	return func() {
+		outer := resolver.scope
+		resolver.scope = scope
+		resolver.stmts(decl.Body.Stmts)
+		resolver.scope = outer
+	}
+}
+
+// param declares param after resolving its type and default value, which
+// can use the parameters before it.
+func (resolver *resolver) param(param *ast.Param) {
+	resolver.typeExpr(param.Type)
+	if param.Default != nil {
+		resolver.expr(param.Default)
+	}
+	resolver.declare(ast.Var, param.Name, param)
+}
+
+// method reports decl if its receiver's type has a method of that name
+// already.
+func (resolver *resolver) method(decl *ast.FuncDecl) {
+	var recv *ast.Object
+	switch typ := decl.Recv.Type.(type) {
+	case *ast.TypeName:
+		recv = typ.Obj
+	case *ast.GenericType:
+		recv = typ.Obj
+	}
+	if recv == nil {
+		return
+	}
+	methods := resolver.methods[recv]
+	if methods == nil {
+		methods = make(map[string]bool)
+		resolver.methods[recv] = methods
+	}
+	if methods[decl.Name.Text] {
+		resolver.err(decl.Name, fmt.Sprintf("method %s.%s is already declared", recv.Name.Text, decl.Name.Text))
+	}
+	methods[decl.Name.Text] = true
+}
+
+// typeParams declares params before resolving their constraints, so that
+// a constraint can use any of them.
+func (resolver *resolver) typeParams(params []*ast.TypeParam) {
+	for _, param := range params {
+		resolver.declare(ast.Typ, param.Name, param)
+	}
+	for _, param := range params {
+		if param.Constraint != nil {
+			resolver.typeExpr(param.Constraint)
+		}
+	}
+}
+
+func (resolver *resolver) typeExpr(typ ast.TypeExpr) {
+	switch typ := typ.(type) {
+	case *ast.TypeName:
+		// The built-in type keywords need no resolving.
+		if typ.Name.Type == scan.Identifier {
+			typ.Obj = resolver.typeName(typ.Name)
+		}
+	case *ast.GenericType:
+		typ.Obj = resolver.typeName(typ.Name)
+		for _, arg := range typ.Args {
+			resolver.typeExpr(arg)
+		}
+	case *ast.ArrayType:
+		resolver.typeExpr(typ.Elem)
+	case *ast.MapType:
+		resolver.typeExpr(typ.Key)
+		resolver.typeExpr(typ.Value)
+	case *ast.FuncType:
+		for _, param := range typ.Params {
+			resolver.typeExpr(param)
+		}
+		if typ.Result != nil {
+			resolver.typeExpr(typ.Result)
+		}
+	}
+}
+
+// typeName returns the type name refers to, or nil if it names no type.
+func (resolver *resolver) typeName(name scan.Token) *ast.Object {
+	object := resolver.lookup(name)
+	if object != nil && object.Kind != ast.Typ {
+		resolver.err(name, fmt.Sprintf("%s is a %s, not a type", name.Text, object.Kind))
+		return nil
+	}
+	return object
+}
+
+func (resolver *resolver) expr(expr ast.Expr) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Ident:
+			node.Obj = resolver.lookup(node.Token)
+		case ast.TypeExpr:
+			resolver.typeExpr(node)
+			return false
+		case *ast.MatchArm:
+			resolver.arm(node)
+			return false
+		}
+		return true
+	})
+}
+
+// arm resolves a match arm in a scope of its own, holding the names its
+// patterns bind. The alternatives of an arm can bind the same names.
+func (resolver *resolver) arm(arm *ast.MatchArm) {
+	resolver.open()
+	for _, pattern := range arm.Patterns {
+		resolver.pattern(pattern, make(map[string]bool))
+	}
+	resolver.expr(arm.Body)
+	resolver.close()
+}
+
+// pattern declares the names pattern binds. bound holds the names bound by
+// the alternative it is part of.
+func (resolver *resolver) pattern(pattern ast.Pattern, bound map[string]bool) {
+	switch pattern := pattern.(type) {
+	case *ast.BindingPattern:
+		resolver.bind(pattern.Name, pattern, bound)
+	case *ast.StructPattern:
+		object := resolver.typeName(pattern.Name)
+		if object != nil {
+			if _, ok := object.Decl.(*ast.StructDecl); ok {
+				pattern.Obj = object
+			} else {
+				resolver.err(pattern.Name, fmt.Sprintf("%s is not a struct", pattern.Name.Text))
+			}
+		}
+		for _, field := range pattern.Fields {
+			if field.Pattern == nil {
+				resolver.bind(field.Name, field, bound)
+			} else {
+				resolver.pattern(field.Pattern, bound)
+			}
+		}
+	}
+}
+
+func (resolver *resolver) bind(name scan.Token, decl ast.Node, bound map[string]bool) {
+	if bound[name.Text] {
+		resolver.err(name, fmt.Sprintf("%s is bound twice in the pattern", name.Text))
+		return
+	}
+	bound[name.Text] = true
+	if resolver.scope.Lookup(name.Text) == nil {
+		resolver.scope.Insert(&ast.Object{Kind: ast.Var, Name: name, Decl: decl})
+	}
+}
+
+// declare declares name in the current scope, reporting it if the scope
+// has a declaration of it already.
+func (resolver *resolver) declare(kind ast.ObjKind, name scan.Token, decl ast.Node) *ast.Object {
+	object := &ast.Object{Kind: kind, Name: name, Decl: decl}
+	if resolver.scope.Insert(object) != nil {
+		resolver.err(name, fmt.Sprintf("%s is already declared in this scope", name.Text))
+	}
+	later := resolver.later[resolver.scope]
+	if token, ok := later[name.Text]; ok && token.StartOffset == name.StartOffset {
+		delete(later, name.Text)
+	}
+	return object
+}
+
+// lookup returns the object name refers to in the current scope or those
+// around it, or reports it and returns nil.
+func (resolver *resolver) lookup(name scan.Token) *ast.Object {
+	for scope := resolver.scope; scope != nil; scope = scope.Outer {
+		if object := scope.Lookup(name.Text); object != nil {
+			return object
+		}
+		if _, ok := resolver.later[scope][name.Text]; ok {
+			resolver.err(name, fmt.Sprintf("%s is used before it is declared", name.Text))
+			return nil
+		}
+	}
+	resolver.err(name, fmt.Sprintf("%s is not declared", name.Text))
+	return nil
+}
+
+func (resolver *resolver) open() {
+	resolver.scope = ast.NewScope(resolver.scope)
+}
+
+func (resolver *resolver) close() {
+	resolver.scope = resolver.scope.Outer
+}
+
+func (resolver *resolver) err(token scan.Token, msg string) {
+	resolver.errors = append(resolver.errors, Error{
+		Message:     msg,
+		Line:        token.Line,
+		Column:      token.Column,
+		StartOffset: token.StartOffset,
+		EndOffset:   token.EndOffset,
+		Pos:         token.Pos,
+	})
+}
@@ -0,0 +1,114 @@
+package resolve
+
+import (
+	"lol/ast"
+	"lol/parse"
+	"lol/scan"
+	"slices"
+	"testing"
+)
+
+func resolve(t *testing.T, source string) (*ast.Program, *ast.Scope, []string) {
+	t.Helper()
+	scanner := scan.NewScanner(source)
+	tokens, scanErrors := scanner.Scan()
+	if len(scanErrors) > 0 {
+		t.Fatalf("scan %q: %v", source, scanErrors)
+	}
+	parser := parse.NewParser(tokens)
+	program, parseErrors := parser.ParseProgram()
+	if len(parseErrors) > 0 {
+		t.Fatalf("parse %q: %v", source, parseErrors)
+	}
+	scope, errors := Resolve(program)
+	messages := make([]string, len(errors))
+	for i, err := range errors {
+		messages[i] = err.Error()
+	}
+	return program, scope, messages
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"declared", "let a = 1\nprint(a)", nil},
+		{"undeclared", "let a = 1\nprint(c)", []string{"c is not declared on line 2"}},
+		{"use before declaration", "let x = y\nlet y = 1", []string{"y is used before it is declared on line 1"}},
+		{"use of outer before inner declaration", "let a = 1\n{ print(a); let a = 2 }", []string{"a is used before it is declared on line 2"}},
+		{"own initializer", "let a = a", []string{"a is used before it is declared on line 1"}},
+		{"duplicate variable", "let x = 1\nlet x = 2", []string{"x is already declared in this scope on line 2"}},
+		{"duplicate function", "fn f() {}\nfn f() {}", []string{"f is already declared in this scope on line 2"}},
+		{"duplicate parameter", "fn f(a: int, a: int) {}", []string{"a is already declared in this scope on line 1"}},
+		{"shadowing in inner block", "let x = 1\n{ let x = 2 }", nil},
+		{"duplicate field", "struct P { x: int, x: int }", []string{"field x is already declared on line 1"}},
+		{"duplicate method", "struct P { x: int }\nfn (p: P) m() {}\nfn (p: P) m() {}", []string{"method P.m is already declared on line 3"}},
+		{"methods of different structs", "struct P {}\nstruct Q {}\nfn (p: P) m() {}\nfn (q: Q) m() {}", nil},
+		{"function used before declaration", "print(f())\nfn f() -> int { return 1 }", nil},
+		{"struct used before declaration", "fn f(p: P) {}\nstruct P { x: int }", nil},
+		{"deferred body", "fn f() -> int { return g() + z }\nfn g() -> int { return 1 }\nlet z = 3", nil},
+		{"deferred nested body", "fn f() {\n  fn g() -> int { return n }\n  let n = 1\n}", nil},
+		{"recursion", "fn f(n: int) -> int { return f(n - 1) }", nil},
+		{"block scope", "{ let a = 1 }\nprint(a)", []string{"a is not declared on line 2"}},
+		{"loop variable", "for x in [1] { print(x) }\nprint(x)", []string{"x is not declared on line 2"}},
+		{"parameter default", "fn f(a: int, b: int = a) {}", nil},
+		{"unknown type", "let a: Q = 1", []string{"Q is not declared on line 1"}},
+		{"function as type", "fn f() {}\nlet a: f = 1", []string{"f is a function, not a type on line 2"}},
+		{"type parameter", "struct List<T> { items: [T] }\nfn first<T>(list: List<T>) -> T { return list.items[0] }", nil},
+		{"match binding", "let v = 1\nprint(match v { 0 => 0, n => n, else => v })", nil},
+		{"match binding scope", "let v = 1\nprint(match v { n => n, else => v })\nprint(n)", []string{"n is not declared on line 3"}},
+		{"struct pattern", "struct P { x: int, y: int }\nfn f(v: P) -> int { return match v { P { x, y: b } => x + b, else => 0 } }", nil},
+		{"pattern binds twice", "struct P { x: int, y: int }\nfn f(v: P) -> int { return match v { P { x: a, y: a } => a, else => 0 } }", []string{"a is bound twice in the pattern on line 2"}},
+		{"pattern of function", "fn f() {}\nprint(match 1 { f { x } => x, else => 0 })", []string{"f is a function, not a type on line 2"}},
+		{"pattern of type parameter", "fn f<T>(v: T) -> int { return match v { T { x } => x, else => 0 } }", []string{"T is not a struct on line 1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, errors := resolve(t, test.source)
+			if !slices.Equal(errors, test.want) {
+				t.Errorf("Resolve(%q) errors = %q, want %q", test.source, errors, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveObjects(t *testing.T) {
+	program, scope, errors := resolve(t, "let a = 1\nfn f(b: int) -> int { return a + b }\nprint(f(a))")
+	if len(errors) > 0 {
+		t.Fatalf("errors: %q", errors)
+	}
+	if scope.Outer != Universe {
+		t.Errorf("Outer of the program scope is not Universe")
+	}
+	for name, kind := range map[string]ast.ObjKind{"a": ast.Var, "f": ast.Fun} {
+		if object := scope.Lookup(name); object == nil || object.Kind != kind {
+			t.Errorf("Lookup(%q) = %v, want a %s", name, object, kind)
+		}
+	}
+	if scope.Lookup("b") != nil {
+		t.Errorf("parameter b is declared in the program scope")
+	}
+
+	let := program.Stmts[0].(*ast.LetStmt)
+	param := program.Stmts[1].(*ast.FuncDecl).Params[0]
+	var idents []*ast.Ident
+	for _, stmt := range program.Stmts[1:] {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Ident); ok && ident.Token.Text != "print" && ident.Token.Text != "f" {
+				idents = append(idents, ident)
+			}
+			return true
+		})
+	}
+	want := []ast.Node{let, param, let}
+	if len(idents) != len(want) {
+		t.Fatalf("found %d identifiers, want %d", len(idents), len(want))
+	}
+	for i, ident := range idents {
+		if ident.Obj == nil || ident.Obj.Decl != want[i] {
+			t.Errorf("%s on line %d resolves to %v, want the %T", ident.Token.Text, ident.Token.Line, ident.Obj, want[i])
+		}
+	}
+}
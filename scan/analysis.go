@@ -0,0 +1,170 @@
+package scan
+
+import "fmt"
+
+// StatementCount counts the top-level statements in tokens. A statement ends
+// at a SemiColon or Newline outside of any brackets; empty statements from
+// repeated or trailing separators are not counted.
+func StatementCount(tokens []Token) int {
+	count := 0
+	depth := 0
+	inStatement := false
+
+	for _, token := range tokens {
+		switch token.Type {
+		case LeftParen, LeftBracket, LeftCurly:
+			depth++
+		case RightParen, RightBracket, RightCurly:
+			if depth > 0 {
+				depth--
+			}
+		case SemiColon, Newline, EOF:
+			if depth == 0 || token.Type == EOF {
+				if inStatement {
+					count++
+				}
+				inStatement = false
+				continue
+			}
+		}
+
+		if !token.IsTrivia() {
+			inStatement = true
+		}
+	}
+
+	if inStatement {
+		count++
+	}
+	return count
+}
+
+var closers = map[Type]Type{
+	LeftParen:   RightParen,
+	LeftBracket: RightBracket,
+	LeftCurly:   RightCurly,
+}
+
+func isBinaryOperator(typ Type) bool {
+	switch typ {
+	case Plus, Minus, Star, StarStar, Slash, Percent, Equals, NotEquals,
+		LeftAngle, RightAngle, GreaterEquals, LesserEquals,
+		Ampersand, Caret, And, Or,
+		PlusAssign, MinusAssign, StarAssign, SlashAssign:
+		return true
+	default:
+		return false
+	}
+}
+
+// errorAt reports an error that points at token.
+func errorAt(code ErrorCode, token Token, msg string) Error {
+	return Error{
+		Code:        code,
+		Message:     msg,
+		Line:        token.Line,
+		Column:      token.Column,
+		StartOffset: token.StartOffset,
+		EndOffset:   token.EndOffset,
+		Pos:         token.Pos,
+	}
+}
+
+// Validate performs cheap structural checks on a token stream: brackets must
+// be balanced, a binary operator may not directly follow another one (except
+// for a unary '-'), and the stream may not end with a binary operator.
+func Validate(tokens []Token) []Error {
+	errors := make([]Error, 0)
+	open := make([]Token, 0)
+	var previous *Token
+
+	for i := range tokens {
+		token := tokens[i]
+		if token.IsTrivia() {
+			continue
+		}
+
+		switch token.Type {
+		case LeftParen, LeftBracket, LeftCurly:
+			open = append(open, token)
+		case RightParen, RightBracket, RightCurly:
+			if len(open) == 0 {
+				errors = append(errors, errorAt(ErrUnbalanced, token, fmt.Sprintf("unmatched '%s'", token.Text)))
+			} else if opener := open[len(open)-1]; closers[opener.Type] != token.Type {
+				errors = append(errors, errorAt(ErrUnbalanced, token, fmt.Sprintf("'%s' closed by '%s'", opener.Text, token.Text)))
+				open = open[:len(open)-1]
+			} else {
+				open = open[:len(open)-1]
+			}
+		case EOF:
+			if previous != nil && isBinaryOperator(previous.Type) {
+				errors = append(errors, errorAt(ErrMissingOperand, *previous, fmt.Sprintf("missing operand after '%s'", previous.Text)))
+			}
+		}
+
+		if previous != nil && isBinaryOperator(previous.Type) && isBinaryOperator(token.Type) && token.Type != Minus {
+			errors = append(errors, errorAt(ErrMissingOperand, token, fmt.Sprintf("unexpected '%s' after '%s'", token.Text, previous.Text)))
+		}
+		previous = &tokens[i]
+	}
+
+	for _, opener := range open {
+		errors = append(errors, errorAt(ErrUnbalanced, opener, fmt.Sprintf("unclosed '%s'", opener.Text)))
+	}
+	return errors
+}
+
+// TokenStats counts how many tokens of each type appear in tokens. The EOF
+// token is not counted.
+func TokenStats(tokens []Token) map[Type]int {
+	stats := make(map[Type]int)
+	for _, token := range tokens {
+		if token.Type != EOF {
+			stats[token.Type]++
+		}
+	}
+	return stats
+}
+
+// LineCount returns the number of lines spanned by tokens, taken from the
+// highest line among them.
+func LineCount(tokens []Token) int {
+	count := 0
+	for _, token := range tokens {
+		count = max(count, token.Line)
+	}
+	return count
+}
+
+func isKeyword(typ Type) bool {
+	for _, keyword := range keywords {
+		if keyword == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTokens reports reserved words used where a declaration needs a
+// name: after let, after struct, and as the variable of a for loop.
+func ValidateTokens(tokens []Token) []Error {
+	errors := make([]Error, 0)
+	var previous Token
+
+	for _, token := range tokens {
+		if token.IsTrivia() {
+			continue
+		}
+
+		switch previous.Type {
+		case Let, Struct, For:
+			if isKeyword(token.Type) {
+				errors = append(errors, errorAt(ErrKeywordAsName, token, fmt.Sprintf("cannot use keyword '%s' as a name after '%s'", token.Text, previous.Text)))
+				// It stands for a name, so it declares nothing itself.
+				token.Type = Identifier
+			}
+		}
+		previous = token
+	}
+	return errors
+}
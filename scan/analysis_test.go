@@ -0,0 +1,130 @@
+package scan
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestStatementCount(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int
+	}{
+		{"", 0},
+		{"\n\n", 0},
+		{";;", 0},
+		{"// only a comment\n", 0},
+		{"let a = 1", 1},
+		{"let a = 1\n", 1},
+		{"let a = 1;", 1},
+		{"let a = 1;;\n\n", 1},
+		{"a\nb\nc", 3},
+		{"a; b; c", 3},
+		{"a;\n\nb\n;c;\n", 3},
+		// Separators inside brackets do not end a statement.
+		{"fn f() {\n  a\n  b\n}\nf()", 2},
+		{"let a = [\n1,\n2\n]\nlet m = {\"a\": (1\n+ 2)}", 2},
+		{"print(a) // one\n/* two */ print(b)", 2},
+		// An unclosed bracket keeps the statement open to the end.
+		{"f(\na\nb", 1},
+	}
+	for _, test := range tests {
+		if got := StatementCount(Scan(test.source).Tokens); got != test.want {
+			t.Errorf("StatementCount(%q) = %d, want %d", test.source, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"let a = (1 + 2) * [3][0]", nil},
+		{"a = -1\nb = a * -2", nil},
+		{"", nil},
+		{"1 + + 2", []string{"unexpected '+' after '+' on line 1"}},
+		{"1 + - 2", nil},
+		{"1 * / 2", []string{"unexpected '/' after '*' on line 1"}},
+		{"1 +", []string{"missing operand after '+' on line 1"}},
+		{"1 +\n", []string{"missing operand after '+' on line 1"}},
+		{"1 + // c", []string{"missing operand after '+' on line 1"}},
+		{"f(1", []string{"unclosed '(' on line 1"}},
+		{"f(1))", []string{"unmatched ')' on line 1"}},
+		{"[1)", []string{"'[' closed by ')' on line 1"}},
+		{"{\n(\n}", []string{"'(' closed by '}' on line 3", "unclosed '{' on line 1"}},
+		{"a += 1", nil},
+		{"a += *= 1", []string{"unexpected '*=' after '+=' on line 1"}},
+		{"a -=", []string{"missing operand after '-=' on line 1"}},
+		// Keywords used as names are left to ValidateTokens.
+		{"let for = 3", nil},
+	}
+	for _, test := range tests {
+		if got := messages(Validate(Scan(test.source).Tokens)); !slices.Equal(got, test.want) {
+			t.Errorf("Validate(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestValidatePosition(t *testing.T) {
+	errors := Validate(Scan("a\n  1 + + 2").Tokens)
+	if len(errors) != 1 {
+		t.Fatalf("Validate = %q, want one error", messages(errors))
+	}
+	if err := errors[0]; err.Code != ErrMissingOperand || err.Line != 2 || err.Column != 7 || err.StartOffset != 8 || err.EndOffset != 9 {
+		t.Errorf("error %s at %d:%d [%d, %d), want MissingOperand at 2:7 [8, 9)", err.Code, err.Line, err.Column, err.StartOffset, err.EndOffset)
+	}
+}
+
+func TestValidateTokens(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"let a = 3\nstruct P {}\nfor x in xs {}", nil},
+		{"", nil},
+		{"let for = 3", []string{"cannot use keyword 'for' as a name after 'let' on line 1"}},
+		{"struct if {}", []string{"cannot use keyword 'if' as a name after 'struct' on line 1"}},
+		{"for let in xs {}", []string{"cannot use keyword 'let' as a name after 'for' on line 1"}},
+		{"let /* c */ while = 1", []string{"cannot use keyword 'while' as a name after 'let' on line 1"}},
+		{"let a = 1\nlet true = 2", []string{"cannot use keyword 'true' as a name after 'let' on line 2"}},
+		// Only names being declared are checked.
+		{"if true { return }", nil},
+	}
+	for _, test := range tests {
+		if got := messages(ValidateTokens(Scan(test.source).Tokens)); !slices.Equal(got, test.want) {
+			t.Errorf("ValidateTokens(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestTokenStats(t *testing.T) {
+	tokens := Scan("let a = 1\nlet b = a + a").Tokens
+	want := map[Type]int{Let: 2, Identifier: 4, Assign: 2, Number: 1, Plus: 1, Newline: 1}
+	if got := TokenStats(tokens); !maps.Equal(got, want) {
+		t.Errorf("TokenStats = %v, want %v", got, want)
+	}
+	if got := TokenStats(Scan("").Tokens); len(got) != 0 {
+		t.Errorf("TokenStats of an empty source = %v, want no counts", got)
+	}
+}
+
+func TestLineCount(t *testing.T) {
+	tests := []struct {
+		source string
+		want   int
+	}{
+		{"", 1},
+		{"a", 1},
+		{"a\n", 1},
+		{"a\nb", 2},
+		{"a\n\n\n", 3},
+		{"`a\nb`", 2},
+	}
+	for _, test := range tests {
+		if got := LineCount(Scan(test.source).Tokens); got != test.want {
+			t.Errorf("LineCount(%q) = %d, want %d", test.source, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"strings"
+	"testing"
+)
+
+var benchSource = strings.Repeat(`let value_1 = foo(bar, 1.5e3) + "str ${x} y" // comment
+if a >= b { return a ** 2 } else { x += 0xff }
+`, 5000)
+
+func BenchmarkScan(b *testing.B) {
+	b.SetBytes(int64(len(benchSource)))
+	b.ReportAllocs()
+	for b.Loop() {
+		scanner := NewScanner(benchSource)
+		scanner.Scan()
+	}
+}
+
+func BenchmarkScanReader(b *testing.B) {
+	b.SetBytes(int64(len(benchSource)))
+	b.ReportAllocs()
+	for b.Loop() {
+		scanner := NewScannerFromReader(strings.NewReader(benchSource))
+		scanner.Scan()
+	}
+}
+
+func BenchmarkScanReset(b *testing.B) {
+	b.SetBytes(int64(len(benchSource)))
+	b.ReportAllocs()
+	scanner := NewScanner(benchSource)
+	for b.Loop() {
+		scanner.Reset(benchSource)
+		scanner.Scan()
+	}
+}
+
+// namesSource repeats a few names many times, which interning stores once.
+var namesSource = strings.Repeat("let total = total + count * count\nif total > limit { total = limit }\n", 2000)
+
+func BenchmarkScanRepeatedNames(b *testing.B) {
+	b.SetBytes(int64(len(namesSource)))
+	b.ReportAllocs()
+	for b.Loop() {
+		scanner := NewScannerFromReader(strings.NewReader(namesSource))
+		scanner.Scan()
+	}
+}
+
+// operatorSource is mostly one- and two-character operators, whose text
+// comes from a fixed table instead of a new string per token.
+var operatorSource = strings.Repeat("a+b*(c-d)/e[f]{g}<h>=i!=j&&k||l**m??n?:o|>p->q;\n", 1000)
+
+func BenchmarkScanOperators(b *testing.B) {
+	b.SetBytes(int64(len(operatorSource)))
+	b.ReportAllocs()
+	for b.Loop() {
+		scanner := NewScanner(operatorSource)
+		scanner.Scan()
+	}
+}
@@ -0,0 +1,147 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Error is a problem found while scanning or validating tokens. Line,
+// Column and StartOffset locate where the offending construct starts, and
+// EndOffset where the scanner noticed the problem.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Line    int       `json:"line"`
+	// Column is the 1-based column the error points at, counted like the
+	// Column of a token.
+	Column      int `json:"column"`
+	StartOffset int `json:"startOffset"`
+	EndOffset   int `json:"endOffset"`
+	// Pos is StartOffset as a position in a File, or NoPos.
+	Pos Pos `json:"pos,omitempty"`
+	// Starting is set when the error is about a construct that runs on from
+	// Line, like a string that is never closed, so that Error reads
+	// "unterminated string starting on line 3".
+	Starting bool `json:"starting,omitempty"`
+}
+
+func (e Error) Error() string {
+	if e.Starting {
+		return fmt.Sprintf("%s starting on line %d", e.Message, e.Line)
+	}
+	return fmt.Sprintf("%s on line %d", e.Message, e.Line)
+}
+
+func (e Error) String() string {
+	return e.Error()
+}
+
+// ErrorCode classifies an Error so tools can tell kinds of errors apart
+// without matching on messages.
+type ErrorCode int
+
+const (
+	ErrUnexpected        ErrorCode = iota + 1 // a character that cannot start a token
+	ErrInvalidEncoding                        // source that is not valid UTF-8
+	ErrUnterminated                           // a string, comment or literal cut off by the end of the source
+	ErrLineBreakInString                      // a line break inside a quoted string
+	ErrMalformedNumber                        // bad digits, separators or suffix in a number
+	ErrMalformedChar                          // an empty, overlong or badly escaped character literal
+	ErrLineContinuation                       // a '\' that does not continue a line
+	ErrIndentation                            // a dedent to no enclosing block
+	ErrRead                                   // the reader failed
+	ErrTooManyErrors                          // MaxErrors was reached
+	ErrUnbalanced                             // a bracket without its partner
+	ErrMissingOperand                         // an operator without an operand
+	ErrKeywordAsName                          // a keyword where a name is expected
+)
+
+var errorCodeNames = [...]string{
+	ErrUnexpected:        "Unexpected",
+	ErrInvalidEncoding:   "InvalidEncoding",
+	ErrUnterminated:      "Unterminated",
+	ErrLineBreakInString: "LineBreakInString",
+	ErrMalformedNumber:   "MalformedNumber",
+	ErrMalformedChar:     "MalformedChar",
+	ErrLineContinuation:  "LineContinuation",
+	ErrIndentation:       "Indentation",
+	ErrRead:              "Read",
+	ErrTooManyErrors:     "TooManyErrors",
+	ErrUnbalanced:        "Unbalanced",
+	ErrMissingOperand:    "MissingOperand",
+	ErrKeywordAsName:     "KeywordAsName",
+}
+
+func (code ErrorCode) String() string {
+	if code > 0 && int(code) < len(errorCodeNames) {
+		return errorCodeNames[code]
+	}
+	return fmt.Sprintf("ErrorCode(%d)", int(code))
+}
+
+func (code ErrorCode) MarshalText() ([]byte, error) {
+	return []byte(code.String()), nil
+}
+
+// HasErrors reports whether scanning has produced any errors so far.
+func (scanner *Scanner) HasErrors() bool {
+	return len(scanner.errors) > 0
+}
+
+// Err returns all errors reported so far joined into one, or nil if there
+// were none.
+func (scanner *Scanner) Err() error {
+	return joinErrors(scanner.errors)
+}
+
+func joinErrors(list []Error) error {
+	errs := make([]error, len(list))
+	for i, e := range list {
+		errs[i] = e
+	}
+	return errors.Join(errs...)
+}
+
+// RenderError formats e followed by the offending line of source and a caret
+// under the column e points at. A caret past the end of the line is placed
+// just after its last character.
+func RenderError(e Error, source string) string {
+	text, start := sourceLine(source, e.Line)
+	column := min(max(e.StartOffset-start, 0), len(text))
+
+	// Keep tabs in the padding so the caret lines up however wide they are.
+	var padding strings.Builder
+	for _, c := range text[:column] {
+		if c == '\t' {
+			padding.WriteByte('\t')
+		} else {
+			padding.WriteByte(' ')
+		}
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s^", e.Error(), text, padding.String())
+}
+
+// sourceLine returns the text of the given 1-based line without its line
+// terminator, along with the offset at which it starts.
+func sourceLine(source string, line int) (string, int) {
+	start := 0
+	for current := 1; current < line && start < len(source); current++ {
+		end := strings.IndexAny(source[start:], "\r\n")
+		if end < 0 {
+			return "", len(source)
+		}
+		start += end
+		if source[start] == '\r' && start+1 < len(source) && source[start+1] == '\n' {
+			start++
+		}
+		start++
+	}
+
+	end := strings.IndexAny(source[start:], "\r\n")
+	if end < 0 {
+		end = len(source) - start
+	}
+	return source[start : start+end], start
+}
@@ -0,0 +1,119 @@
+package scan
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestErrorFields(t *testing.T) {
+	tests := []struct {
+		source string
+		want   Error
+		text   string
+	}{
+		{"a @ b", Error{Code: ErrUnexpected, Line: 1, Column: 3, StartOffset: 2, EndOffset: 3},
+			"Unexpected character '@' on line 1"},
+		{"a\n  \"open\nb", Error{Code: ErrUnterminated, Line: 2, Column: 3, StartOffset: 4, EndOffset: 11, Starting: true},
+			"unterminated string starting on line 2"},
+		{"/* a\n\n", Error{Code: ErrUnterminated, Line: 1, Column: 1, StartOffset: 0, EndOffset: 6, Starting: true},
+			"unterminated block comment starting on line 1"},
+		{"x = `raw\n", Error{Code: ErrUnterminated, Line: 1, Column: 5, StartOffset: 4, EndOffset: 9, Starting: true},
+			"unterminated raw string starting on line 1"},
+	}
+	for _, test := range tests {
+		list := Scan(test.source).Errors
+		if len(list) != 1 {
+			t.Errorf("scan %q errors = %q, want one", test.source, messages(list))
+			continue
+		}
+		got := list[0]
+		if got.Error() != test.text {
+			t.Errorf("scan %q error = %q, want %q", test.source, got.Error(), test.text)
+		}
+		got.Message = ""
+		if got != test.want {
+			t.Errorf("scan %q error = %s at %d:%d [%d, %d), starting %t, want %s at %d:%d [%d, %d), starting %t", test.source,
+				got.Code, got.Line, got.Column, got.StartOffset, got.EndOffset, got.Starting,
+				test.want.Code, test.want.Line, test.want.Column, test.want.StartOffset, test.want.EndOffset, test.want.Starting)
+		}
+	}
+}
+
+func TestErr(t *testing.T) {
+	clean := NewScanner("let a = 1")
+	clean.Scan()
+	if clean.HasErrors() {
+		t.Errorf("HasErrors() = true for a clean scan")
+	}
+	if err := clean.Err(); err != nil {
+		t.Errorf("Err() = %v for a clean scan, want nil", err)
+	}
+
+	scanner := NewScanner("a @ b\n1.2.3")
+	_, list := scanner.Scan()
+	if !scanner.HasErrors() {
+		t.Errorf("HasErrors() = false, want true")
+	}
+	err := scanner.Err()
+	if err == nil {
+		t.Fatal("Err() = nil, want the scan errors")
+	}
+	if want := list[0].Error() + "\n" + list[1].Error(); err.Error() != want {
+		t.Errorf("Err() = %q, want %q", err, want)
+	}
+	wrapped := err.(interface{ Unwrap() []error }).Unwrap()
+	if len(wrapped) != len(list) {
+		t.Fatalf("Err() wraps %d errors, want %d", len(wrapped), len(list))
+	}
+	for i, want := range list {
+		var got Error
+		if !errors.As(wrapped[i], &got) || got != want {
+			t.Errorf("Err() wraps %v, want %v", wrapped[i], want)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    Error
+		want   string
+	}{
+		{"first line", "a @ b\nc", Error{Message: "bad", Line: 1, StartOffset: 2},
+			"bad on line 1\na @ b\n  ^"},
+		{"last line without a newline", "a\nb @", Error{Message: "bad", Line: 2, StartOffset: 4},
+			"bad on line 2\nb @\n  ^"},
+		{"crlf", "a\r\nb @\r\nc", Error{Message: "bad", Line: 2, StartOffset: 5},
+			"bad on line 2\nb @\n  ^"},
+		{"lone cr", "a\rb @\rc", Error{Message: "bad", Line: 2, StartOffset: 4},
+			"bad on line 2\nb @\n  ^"},
+		{"past the end of the line", "ab\ncd", Error{Message: "bad", Line: 1, StartOffset: 3},
+			"bad on line 1\nab\n  ^"},
+		{"past the end of the source", "ab", Error{Message: "bad", Line: 1, StartOffset: 10},
+			"bad on line 1\nab\n  ^"},
+		{"tabs", "\tx\t@", Error{Message: "bad", Line: 1, StartOffset: 3},
+			"bad on line 1\n\tx\t@\n\t \t^"},
+		{"empty line", "a\n\nb", Error{Message: "bad", Line: 2, StartOffset: 2},
+			"bad on line 2\n\n^"},
+		{"line after the end", "a\n", Error{Message: "bad", Line: 3, StartOffset: 2},
+			"bad on line 3\n\n^"},
+	}
+	for _, test := range tests {
+		if got := RenderError(test.err, test.source); got != test.want {
+			t.Errorf("%s: RenderError =\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRenderScanError(t *testing.T) {
+	source := "let a = 1\n\tlet b = 1.2.3"
+	errors := Scan(source).Errors
+	if len(errors) != 1 {
+		t.Fatalf("scan errors = %q, want one", messages(errors))
+	}
+	want := "malformed number '1.2.3' on line 2\n\tlet b = 1.2.3\n\t        ^"
+	if got := RenderError(errors[0], source); got != want {
+		t.Errorf("RenderError =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+package scan
+
+import (
+	"os"
+	"runtime"
+	"sync"
+)
+
+// FileResult is the outcome of scanning one file with ScanFiles. Err is set
+// when the file could not be read, in which case there are no tokens.
+type FileResult struct {
+	Path string
+	ScanResult
+	Err error
+}
+
+// ScanFiles reads and scans every file in paths with the default options,
+// running at most concurrency scans at a time; zero or less uses one per
+// CPU. The results are in the order of paths, whatever order the files
+// finish in. All files share one Interner, so a name has the same text and
+// ID in every file.
+func ScanFiles(paths []string, concurrency int) []FileResult {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, len(paths))
+
+	options := DefaultOptions()
+	options.Interner = NewInterner()
+
+	results := make([]FileResult, len(paths))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				results[i] = scanFile(paths[i], options)
+			}
+		}()
+	}
+	for i := range paths {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+func scanFile(path string, options ScannerOptions) FileResult {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return FileResult{Path: path, Err: err}
+	}
+
+	scanner := NewScannerWithOptions(string(source), options)
+	tokens, errors := scanner.Scan()
+	return FileResult{Path: path, ScanResult: ScanResult{Tokens: tokens, Errors: errors}}
+}
@@ -0,0 +1,44 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// FormatTokens renders tokens as an aligned table for debugging, one token
+// per row.
+func FormatTokens(tokens []Token) string {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "INDEX\tLINE\tCOLUMN\tTYPE\tTEXT")
+	for i, token := range tokens {
+		fmt.Fprintf(writer, "%d\t%d\t%d\t%s\t%q\n", i, token.Line, token.Column, token.Type, token.Text)
+	}
+
+	writer.Flush()
+	return builder.String()
+}
+
+// ToReproScript renders tokens one per line as their type name and quoted
+// text, which is compact enough to paste into a bug report.
+func ToReproScript(tokens []Token) string {
+	var builder strings.Builder
+	for _, token := range tokens {
+		fmt.Fprintf(&builder, "%s %q\n", token.Type, token.Text)
+	}
+	return builder.String()
+}
+
+// DumpTokens writes tokens to w one per line as "line:column type text",
+// with the text quoted, for consumption by other tools.
+func DumpTokens(w io.Writer, tokens []Token) error {
+	for _, token := range tokens {
+		if _, err := fmt.Fprintf(w, "%d:%d %s %q\n", token.Line, token.Column, token.Type, token.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
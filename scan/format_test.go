@@ -0,0 +1,74 @@
+package scan
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDumpTokens(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := DumpTokens(&buffer, Scan("let s = \"a b\"\nx").Tokens); err != nil {
+		t.Fatal(err)
+	}
+	want := "1:1 Let \"let\"\n" +
+		"1:5 Identifier \"s\"\n" +
+		"1:7 Assign \"=\"\n" +
+		"1:9 String \"a b\"\n" +
+		"1:14 Newline \"\\n\"\n" +
+		"2:1 Identifier \"x\"\n" +
+		"2:2 EOF \"\"\n"
+	if got := buffer.String(); got != want {
+		t.Errorf("DumpTokens wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestDumpTokensWriteError(t *testing.T) {
+	if err := DumpTokens(failingWriter{}, Scan("x").Tokens); err != errWrite {
+		t.Errorf("DumpTokens error = %v, want %v", err, errWrite)
+	}
+}
+
+func TestFormatTokens(t *testing.T) {
+	tokens := Scan("let name = 10\n\tx").Tokens
+	want := "INDEX  LINE  COLUMN  TYPE        TEXT\n" +
+		"0      1     1       Let         \"let\"\n" +
+		"1      1     5       Identifier  \"name\"\n" +
+		"2      1     10      Assign      \"=\"\n" +
+		"3      1     12      Number      \"10\"\n" +
+		"4      1     14      Newline     \"\\n\"\n" +
+		"5      2     2       Identifier  \"x\"\n" +
+		"6      2     3       EOF         \"\"\n"
+	if got := FormatTokens(tokens); got != want {
+		t.Errorf("FormatTokens =\n%s\nwant\n%s", got, want)
+	}
+	if got, want := FormatTokens(nil), "INDEX  LINE  COLUMN  TYPE  TEXT\n"; got != want {
+		t.Errorf("FormatTokens(nil) = %q, want %q", got, want)
+	}
+}
+
+func TestToReproScript(t *testing.T) {
+	tokens := Scan("print(\"a\\tb\", 'c') @").Tokens
+	want := "Identifier \"print\"\n" +
+		"LeftParen \"(\"\n" +
+		"String \"a\\\\tb\"\n" +
+		"Comma \",\"\n" +
+		"Char \"c\"\n" +
+		"RightParen \")\"\n" +
+		"Illegal \"@\"\n" +
+		"EOF \"\"\n"
+	if got := ToReproScript(tokens); got != want {
+		t.Errorf("ToReproScript =\n%s\nwant\n%s", got, want)
+	}
+	if got := ToReproScript(nil); got != "" {
+		t.Errorf("ToReproScript(nil) = %q, want \"\"", got)
+	}
+}
@@ -0,0 +1,72 @@
+package scan
+
+import (
+	"strings"
+	"sync"
+)
+
+// Interner keeps one copy of every identifier and keyword text it is given
+// and numbers them, so that tokens share storage for repeated names and a
+// symbol table can key on a small integer instead of a string. An Interner
+// is safe for concurrent use and can be shared by several scanners through
+// ScannerOptions.
+type Interner struct {
+	mu    sync.Mutex
+	ids   map[string]int
+	names []string
+}
+
+func NewInterner() *Interner {
+	return &Interner{ids: make(map[string]int)}
+}
+
+// Intern returns the shared copy of text, adding it if it is new.
+func (interner *Interner) Intern(text string) string {
+	name, _ := interner.add(text)
+	return name
+}
+
+// ID returns the number of text, adding it if it is new. IDs count up from 0
+// in the order the names were first interned.
+func (interner *Interner) ID(text string) int {
+	_, id := interner.add(text)
+	return id
+}
+
+// Lookup returns the number of text and whether it has been interned,
+// without adding it.
+func (interner *Interner) Lookup(text string) (int, bool) {
+	interner.mu.Lock()
+	defer interner.mu.Unlock()
+	id, ok := interner.ids[text]
+	return id, ok
+}
+
+// Name returns the text with the given ID. It panics if no name has that ID.
+func (interner *Interner) Name(id int) string {
+	interner.mu.Lock()
+	defer interner.mu.Unlock()
+	return interner.names[id]
+}
+
+// Len returns the number of names interned so far.
+func (interner *Interner) Len() int {
+	interner.mu.Lock()
+	defer interner.mu.Unlock()
+	return len(interner.names)
+}
+
+func (interner *Interner) add(text string) (string, int) {
+	interner.mu.Lock()
+	defer interner.mu.Unlock()
+	if id, ok := interner.ids[text]; ok {
+		return interner.names[id], id
+	}
+	// The text usually slices a larger source, which the copy must not keep
+	// alive.
+	name := strings.Clone(text)
+	id := len(interner.names)
+	interner.ids[name] = id
+	interner.names = append(interner.names, name)
+	return name, id
+}
@@ -0,0 +1,14 @@
+package scan
+
+import "encoding/json"
+
+// TokensToJSON encodes tokens as a JSON array with the token type and
+// number kind spelled out by name. The identifier in "let x" encodes as
+//
+//	{"type":"Identifier","line":1,"column":5,"text":"x","startOffset":4,"endOffset":5}
+//
+// Pos, Base, Kind and the trivia are left out when they are unset, so a
+// number adds "base":10,"kind":"Int".
+func TokensToJSON(tokens []Token) ([]byte, error) {
+	return json.Marshal(tokens)
+}
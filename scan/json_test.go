@@ -0,0 +1,17 @@
+package scan
+
+import "testing"
+
+func TestTokensToJSON(t *testing.T) {
+	data, err := TokensToJSON(Scan(`x 1 "s"`).Tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"type":"Identifier","line":1,"column":1,"text":"x","startOffset":0,"endOffset":1},` +
+		`{"type":"Number","line":1,"column":3,"text":"1","startOffset":2,"endOffset":3,"base":10,"kind":"Int"},` +
+		`{"type":"String","line":1,"column":5,"text":"s","startOffset":4,"endOffset":7},` +
+		`{"type":"EOF","line":1,"column":8,"text":"","startOffset":7,"endOffset":7}]`
+	if string(data) != want {
+		t.Errorf("TokensToJSON = %s\nwant %s", data, want)
+	}
+}
@@ -0,0 +1,68 @@
+package scan
+
+type ScannerOptions struct {
+	// GreedyNumbers lets a trailing '.' belong to the number, so "1." scans
+	// as Number("1.") instead of Number("1") followed by Dot.
+	GreedyNumbers bool
+	// Newlines selects what the scanner makes of line breaks. Line numbers
+	// are tracked either way.
+	Newlines NewlinePolicy
+	// MaxErrors stops scanning once that many errors have been reported,
+	// with EOF where scanning stopped. Zero means no limit.
+	MaxErrors int
+	// FinalNewline adds a synthetic Newline with empty text before EOF when
+	// the source does not end with a line break.
+	FinalNewline bool
+	// TrueLiterals and FalseLiterals replace the spellings of the boolean
+	// literals, e.g. []string{"yes", "on"}. Nil keeps "true" and "false".
+	TrueLiterals  []string
+	FalseLiterals []string
+	// Indentation emits Indent and Dedent tokens when the leading whitespace
+	// of a line grows or shrinks, for layout-sensitive grammars. A tab
+	// indents to the next multiple of TabWidth.
+	Indentation bool
+	// AttachTrivia keeps comments, attaching them and blank lines to the
+	// neighbouring tokens as LeadingTrivia and TrailingTrivia.
+	AttachTrivia bool
+	// KeepComments emits every comment as a Comment or DocComment token in
+	// the token stream instead of dropping it.
+	KeepComments bool
+	// TabWidth is the distance between tab stops: a tab advances the column
+	// counter to the next column after a multiple of TabWidth. The default
+	// of 1 counts a tab like any other character.
+	TabWidth int
+	// Interner receives the text of every identifier and keyword. Scanners
+	// given the same Interner share names and their IDs; nil gives each
+	// scanner its own.
+	Interner *Interner
+	// File is the file of a FileSet that holds the source. When set, tokens
+	// and errors get a Pos in it.
+	File *File
+}
+
+// NewlinePolicy tells the scanner which tokens to produce for line breaks.
+type NewlinePolicy int
+
+const (
+	// KeepNewlines produces a Newline token for every line break.
+	KeepNewlines NewlinePolicy = iota
+	// SuppressNewlines produces no tokens for line breaks.
+	SuppressNewlines
+	// CollapseNewlines produces one Newline for a run of line breaks, and
+	// none before the first token.
+	CollapseNewlines
+	// InsertSemicolons produces no Newline tokens but, like Go, inserts a
+	// SemiColon at a line break or at the end of the source when the line
+	// ends with a token that can end a statement: a name, a literal,
+	// return, a closing bracket, "++" or "--".
+	InsertSemicolons
+)
+
+func DefaultOptions() ScannerOptions {
+	return ScannerOptions{
+		GreedyNumbers: false,
+		Newlines:      KeepNewlines,
+		MaxErrors:     100,
+		TabWidth:      1,
+	}
+}
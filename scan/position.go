@@ -0,0 +1,150 @@
+package scan
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Pos is a compact position in a FileSet: the byte offset of a place in one
+// of its files plus that file's base. The zero Pos, NoPos, is no position.
+type Pos int
+
+const NoPos Pos = 0
+
+func (pos Pos) IsValid() bool {
+	return pos != NoPos
+}
+
+// Position is a Pos resolved to its file, line and column. Line and Column
+// start at 1 and Column counts characters, each tab being one.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (position Position) IsValid() bool {
+	return position.Line > 0
+}
+
+func (position Position) String() string {
+	if !position.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%s:%d:%d", position.Filename, position.Line, position.Column)
+}
+
+// FileSet hands out non-overlapping ranges of Pos values to the files added
+// to it, so that a single Pos identifies both a file and a place in it. It
+// is safe for concurrent use.
+type FileSet struct {
+	mu    sync.RWMutex
+	base  int
+	files []*File
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{base: 1}
+}
+
+// AddFile adds a file with the given name and source. Its positions follow
+// those of the files added before it.
+func (set *FileSet) AddFile(name, source string) *File {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	file := &File{name: name, base: set.base, source: source, lines: lineStarts(source)}
+	// One more position than the source has bytes, for the EOF token.
+	set.base += len(source) + 1
+	set.files = append(set.files, file)
+	return file
+}
+
+// File returns the file that pos belongs to, or nil if there is none.
+func (set *FileSet) File(pos Pos) *File {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	i := sort.Search(len(set.files), func(i int) bool { return set.files[i].base > int(pos) }) - 1
+	if i < 0 || int(pos) > set.files[i].base+len(set.files[i].source) {
+		return nil
+	}
+	return set.files[i]
+}
+
+// Position resolves pos, returning the zero Position if it is not in any
+// file of the set.
+func (set *FileSet) Position(pos Pos) Position {
+	if file := set.File(pos); file != nil {
+		return file.Position(pos)
+	}
+	return Position{}
+}
+
+// File is one source added to a FileSet.
+type File struct {
+	name   string
+	base   int
+	source string
+	// lines holds the byte offset at which every line starts.
+	lines []int
+}
+
+func (file *File) Name() string {
+	return file.name
+}
+
+func (file *File) Base() int {
+	return file.base
+}
+
+func (file *File) Size() int {
+	return len(file.source)
+}
+
+func (file *File) LineCount() int {
+	return len(file.lines)
+}
+
+// Pos returns the position of the byte offset in the file.
+func (file *File) Pos(offset int) Pos {
+	return Pos(file.base + offset)
+}
+
+// Offset returns the byte offset of pos in the file.
+func (file *File) Offset(pos Pos) int {
+	return int(pos) - file.base
+}
+
+// Position resolves pos, which must belong to the file.
+func (file *File) Position(pos Pos) Position {
+	offset := file.Offset(pos)
+	line := sort.Search(len(file.lines), func(i int) bool { return file.lines[i] > offset })
+	start := file.lines[line-1]
+	// The scanner does not count a leading byte order mark as a column.
+	if start == 0 && strings.HasPrefix(file.source, string(byteOrderMark)) && offset >= len(string(byteOrderMark)) {
+		start = len(string(byteOrderMark))
+	}
+	column := utf8.RuneCountInString(file.source[start:offset]) + 1
+	return Position{Filename: file.name, Offset: offset, Line: line, Column: column}
+}
+
+// lineStarts returns the offsets at which the lines of source start. A line
+// ends at "\n", "\r\n" or a lone "\r", like for the scanner.
+func lineStarts(source string) []int {
+	lines := []int{0}
+	for i := 0; i < len(source); i++ {
+		switch source[i] {
+		case '\r':
+			if i+1 < len(source) && source[i+1] == '\n' {
+				continue
+			}
+			lines = append(lines, i+1)
+		case '\n':
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,66 @@
+package scan
+
+import "testing"
+
+func TestFileSetPositions(t *testing.T) {
+	sources := []struct {
+		name, source string
+	}{
+		{"a.lol", "\uFEFFlet x = 1\n/* a\nb */ y \"\u00e9\" z\r\nq\rw\n`r\nr` 'c'\n"},
+		{"b.lol", "a\tb\n\n  c"},
+	}
+	set := NewFileSet()
+	for _, s := range sources {
+		file := set.AddFile(s.name, s.source)
+		options := DefaultOptions()
+		options.File = file
+		options.KeepComments = true
+		for _, token := range scanWith(t, s.source, options) {
+			if !token.Pos.IsValid() {
+				t.Errorf("%s: %s has no Pos", s.name, token)
+				continue
+			}
+			if set.File(token.Pos) != file {
+				t.Errorf("%s: %s resolves to another file", s.name, token)
+			}
+			position := set.Position(token.Pos)
+			if position.Filename != s.name || position.Offset != token.StartOffset {
+				t.Errorf("%s: %s at %s offset %d, want offset %d", s.name, token, position, position.Offset, token.StartOffset)
+			}
+			// EOF after a final line break is placed on the last line.
+			if token.Type != EOF && (position.Line != token.Line || position.Column != token.Column) {
+				t.Errorf("%s: %s at %d:%d, want %d:%d", s.name, token, position.Line, position.Column, token.Line, token.Column)
+			}
+		}
+	}
+}
+
+func TestFileSetBounds(t *testing.T) {
+	set := NewFileSet()
+	a := set.AddFile("a.lol", "ab\nc")
+	b := set.AddFile("b.lol", "d")
+	if a.Base() != 1 || b.Base() != a.Base()+a.Size()+1 {
+		t.Errorf("bases = %d, %d, want 1, %d", a.Base(), b.Base(), a.Size()+2)
+	}
+	tests := []struct {
+		pos  Pos
+		want string
+	}{
+		{NoPos, "-"},
+		{a.Pos(0), "a.lol:1:1"},
+		{a.Pos(3), "a.lol:2:1"},
+		// The position just past the end of a file belongs to it, for EOF.
+		{a.Pos(4), "a.lol:2:2"},
+		{b.Pos(0), "b.lol:1:1"},
+		{b.Pos(1), "b.lol:1:2"},
+		{b.Pos(2), "-"},
+	}
+	for _, test := range tests {
+		if got := set.Position(test.pos).String(); got != test.want {
+			t.Errorf("Position(%d) = %s, want %s", test.pos, got, test.want)
+		}
+	}
+	if a.LineCount() != 2 || b.LineCount() != 1 {
+		t.Errorf("line counts = %d, %d, want 2, 1", a.LineCount(), b.LineCount())
+	}
+}
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+)
+
+const chunkSize = 4096
+
+// NewScannerFromReader returns a scanner that reads its source from reader
+// as tokens are pulled with Consume, Peek or Tokens, keeping only the token
+// being scanned in memory instead of the whole source.
+func NewScannerFromReader(reader io.Reader) Scanner {
+	return NewScannerFromReaderWithOptions(reader, DefaultOptions())
+}
+
+func NewScannerFromReaderWithOptions(reader io.Reader, options ScannerOptions) Scanner {
+	scanner := NewScannerWithOptions("", options)
+	scanner.reader = reader
+	scanner.chunk = make([]byte, chunkSize)
+	return scanner
+}
+
+// available reports whether the source has a byte at offset, reading more
+// of it from the reader when the window ends before offset.
+func (scanner *Scanner) available(offset int) bool {
+	for offset >= len(scanner.source) && scanner.reader != nil {
+		scanner.fill()
+	}
+	return offset < len(scanner.source)
+}
+
+// fill appends the next chunk of the reader to the window.
+func (scanner *Scanner) fill() {
+	n, err := scanner.reader.Read(scanner.chunk)
+	scanner.source += string(scanner.chunk[:n])
+	if err == io.EOF {
+		scanner.reader = nil
+	} else if err != nil {
+		scanner.err(ErrRead, fmt.Sprintf("read error: %v", err))
+		scanner.reader = nil
+	}
+}
+
+// discard drops the part of the window before the current position, which
+// no token will look at again. The last byte is kept so that the scanner
+// can still tell whether the source ended with a line break.
+func (scanner *Scanner) discard() {
+	if scanner.current <= 1 {
+		return
+	}
+	keep := scanner.current - 1
+	scanner.source = scanner.source[keep:]
+	scanner.base += keep
+	scanner.start -= keep
+	scanner.current -= keep
+}
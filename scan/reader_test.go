@@ -0,0 +1,84 @@
+package scan
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var readerSources = []string{
+	"",
+	"let a = 1\n",
+	"\uFEFF#!/bin/lol\nfn f() {\n\treturn \"a ${b + \"c\"} d\" // e\r\n}\r",
+	"x = 1.2.3 @ 'ab' \"open",
+	"/* a /* b */\n*/ `raw\nstring` 0x_1F 1e+5f ü",
+	strings.Repeat("name_"+strings.Repeat("x", 100)+" = \"${y}\" /* z */\n", 100),
+}
+
+func TestReaderScanner(t *testing.T) {
+	readers := map[string]func(io.Reader) io.Reader{
+		"whole":    func(r io.Reader) io.Reader { return r },
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data err": iotest.DataErrReader,
+	}
+	configure := map[string]func(*ScannerOptions){
+		"default":       func(*ScannerOptions) {},
+		"keep comments": func(o *ScannerOptions) { o.KeepComments = true },
+		"indentation":   func(o *ScannerOptions) { o.Indentation = true },
+		"attach trivia": func(o *ScannerOptions) { o.AttachTrivia = true },
+		"final newline": func(o *ScannerOptions) { o.FinalNewline = true },
+	}
+	for name, set := range configure {
+		options := DefaultOptions()
+		set(&options)
+		for _, source := range readerSources {
+			want := NewScannerWithOptions(source, options)
+			wantTokens, wantErrors := want.Scan()
+			for kind, wrap := range readers {
+				scanner := NewScannerFromReaderWithOptions(wrap(strings.NewReader(source)), options)
+				tokens, errors := scanner.Scan()
+				if !reflect.DeepEqual(tokens, wantTokens) {
+					t.Errorf("%s, %s reader: scan %.40q = %q, want %q", name, kind, source, describe(tokens), describe(wantTokens))
+				}
+				if !reflect.DeepEqual(errors, wantErrors) {
+					t.Errorf("%s, %s reader: scan %.40q errors = %q, want %q", name, kind, source, messages(errors), messages(wantErrors))
+				}
+			}
+		}
+	}
+}
+
+func TestReaderWindow(t *testing.T) {
+	source := strings.Repeat("let value = \"some text\" + other\n", 10000)
+	scanner := NewScannerFromReader(strings.NewReader(source))
+	count := 0
+	for token := range scanner.Tokens() {
+		count++
+		// Only the part of the source around the current token is kept.
+		if len(scanner.source) > 2*chunkSize {
+			t.Fatalf("window holds %d bytes at %s", len(scanner.source), token)
+		}
+	}
+	if want := len(Scan(source).Tokens); count != want {
+		t.Errorf("scanned %d tokens, want %d", count, want)
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	failure := errors.New("disk on fire")
+	reader := io.MultiReader(strings.NewReader("let a = 1\nb"), iotest.ErrReader(failure))
+	scanner := NewScannerFromReader(reader)
+	tokens, errors := scanner.Scan()
+	wantTokens := []string{`Let "let"`, `Identifier "a"`, `Assign "="`, `Number "1"`, `Newline "\n"`, `Identifier "b"`, `EOF ""`}
+	if got := describe(tokens); !slices.Equal(got, wantTokens) {
+		t.Errorf("scan = %q, want %q", got, wantTokens)
+	}
+	if len(errors) != 1 || errors[0].Code != ErrRead || errors[0].Message != "read error: disk on fire" {
+		t.Errorf("errors = %q, want one read error", messages(errors))
+	}
+}
@@ -0,0 +1,224 @@
+package scan
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// lookahead bounds how many bytes past the end of a token the scanner may
+// look at to decide where the token ends.
+const lookahead = 3 * utf8.UTFMax
+
+// Range is the half-open span [Start, End) of byte offsets in a source.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Delta describes how Rescan changed the token stream: the Removed tokens,
+// which started at Index, were replaced by the Inserted ones. The tokens
+// after them were kept, moved to their new offsets and lines.
+type Delta struct {
+	Index    int
+	Removed  []Token
+	Inserted []Token
+}
+
+// Rescan replaces the source in edit with newText and updates the tokens and
+// errors of a completed Scan to match, reporting which tokens changed. Only
+// the tokens around the edit are scanned again: scanning restarts a few
+// characters before the edit and stops as soon as it produces a token the
+// old stream already had, on a line after the edit. Scanners that indent or
+// attach trivia carry more state from line to line, as do scans stopped by
+// MaxErrors, and are always scanned again in full. Tokens taken with Consume
+// are not restored. Scanners reading from an io.Reader or placing tokens in
+// a File cannot rescan, as the source of a File cannot change.
+func (scanner *Scanner) Rescan(edit Range, newText string) (Delta, error) {
+	if scanner.reader != nil || scanner.base != 0 {
+		return Delta{}, errors.New("cannot rescan a scanner reading from an io.Reader")
+	}
+	if scanner.options.File != nil {
+		return Delta{}, errors.New("cannot rescan a scanner with a File")
+	}
+	if edit.Start < 0 || edit.Start > edit.End || edit.End > len(scanner.source) {
+		return Delta{}, errors.New("edit range out of bounds")
+	}
+	if !scanner.done {
+		scanner.Scan()
+	}
+
+	old := scanner.tokens
+	source := scanner.source[:edit.Start] + newText + scanner.source[edit.End:]
+	if scanner.options.Indentation || scanner.options.AttachTrivia || scanner.aborted() {
+		return scanner.rescanAll(source), nil
+	}
+
+	// Interpolations are scanned with the string they belong to on a stack,
+	// so scanning can only restart after a token outside all of them.
+	depth := make([]int, len(old))
+	open := 0
+	for i, token := range old {
+		switch token.Type {
+		case StringStart:
+			open++
+		case StringEnd:
+			open--
+		}
+		depth[i] = open
+	}
+
+	// Scanning a token can look up to lookahead characters past its end, as
+	// in "1e+2", so the tokens just before the edit are scanned again too.
+	index := sort.Search(len(old), func(i int) bool { return old[i].EndOffset+lookahead > edit.Start })
+	for index > 0 && (depth[index-1] != 0 || old[index-1].Type == StringMid) {
+		index--
+	}
+
+	sub := NewScannerWithOptions(source, scanner.options)
+	sub.keywords = scanner.keywords
+	sub.interner = scanner.interner
+	restart := 0
+	if index > 0 {
+		restart = old[index-1].EndOffset
+	}
+	// The errors before the restart count towards MaxErrors.
+	for _, e := range scanner.errors {
+		if e.StartOffset < restart {
+			sub.errors = append(sub.errors, e)
+		}
+	}
+	if index > 0 {
+		previous := old[index-1]
+		sub.current, sub.line, sub.column = previous.StartOffset, previous.Line, previous.Column
+		sub.terminatorColumn = scanner.lineBreakColumn(previous.StartOffset)
+		for sub.current < previous.EndOffset {
+			sub.countLine(sub.advance())
+		}
+		for i := index - 1; i >= 0; i-- {
+			if old[i].Type != Comment && old[i].Type != DocComment {
+				sub.last = old[i]
+				break
+			}
+		}
+	}
+
+	shift := len(newText) - (edit.End - edit.Start)
+	lines := 0
+	editEnd := edit.Start + len(newText)
+
+	// Step until the newest token is one the old stream has at the same
+	// place, past the line of the edit and outside any interpolation. It
+	// must not be a comment, which would leave the last token unchanged.
+	resumed := len(old)
+	for scanned := 0; sub.step(); scanned = len(sub.tokens) {
+		if len(sub.tokens) == scanned || len(sub.interpolations) > 0 || sub.tooManyErrors() {
+			continue
+		}
+		token := sub.tokens[len(sub.tokens)-1]
+		if token.StartOffset < editEnd || token.Type == EOF || token.Type == Comment || token.Type == DocComment {
+			continue
+		}
+		if match := scanner.findToken(token.StartOffset-shift, token, edit.End); match >= 0 && depth[match] == 0 {
+			resumed = match + 1
+			lines = token.Line - old[match].Line
+			break
+		}
+	}
+
+	tail := make([]Token, 0, len(old)-resumed)
+	for _, token := range old[resumed:] {
+		token.StartOffset += shift
+		token.EndOffset += shift
+		token.Line += lines
+		tail = append(tail, token)
+	}
+
+	cutoff := len(scanner.source) + 1
+	if resumed < len(old) {
+		cutoff = old[resumed-1].EndOffset
+	}
+	errs := sub.errors
+	for _, e := range scanner.errors {
+		if e.StartOffset >= cutoff {
+			e.StartOffset += shift
+			e.EndOffset += shift
+			e.Line += lines
+			errs = append(errs, e)
+		}
+	}
+	// With the errors after the edit, a full scan would have stopped early.
+	if scanner.options.MaxErrors > 0 && len(errs) > len(sub.errors) && len(errs) >= scanner.options.MaxErrors {
+		return scanner.rescanAll(source), nil
+	}
+
+	delta := Delta{
+		Index:    index,
+		Removed:  slices.Clone(old[index:resumed]),
+		Inserted: slices.Clone(sub.tokens),
+	}
+	scanner.tokens = slices.Concat(old[:index], sub.tokens, tail)
+	scanner.errors = errs
+	scanner.source = source
+	scanner.start, scanner.current = len(source), len(source)
+	scanner.line += lines
+	if resumed == len(old) {
+		scanner.line, scanner.column, scanner.terminatorColumn = sub.line, sub.column, sub.terminatorColumn
+	}
+	scanner.eof = scanner.tokens[len(scanner.tokens)-1]
+	return delta, nil
+}
+
+// rescanAll scans source from the start, replacing every token.
+func (scanner *Scanner) rescanAll(source string) Delta {
+	removed := slices.Clone(scanner.tokens)
+	scanner.Reset(source)
+	inserted, _ := scanner.Scan()
+	return Delta{Index: 0, Removed: removed, Inserted: slices.Clone(inserted)}
+}
+
+// lineBreakColumn returns the column of the last line break in the source
+// before offset, or 0 if there is none.
+func (scanner *Scanner) lineBreakColumn(offset int) int {
+	end := strings.LastIndexAny(scanner.source[:offset], "\r\n")
+	if end < 0 {
+		return 0
+	}
+	if end > 0 && scanner.source[end-1:end+1] == "\r\n" {
+		end--
+	}
+	walker := Scanner{source: scanner.source[:end], options: scanner.options, column: 1}
+	walker.current = strings.LastIndexAny(walker.source, "\r\n") + 1
+	if walker.current == 0 && strings.HasPrefix(walker.source, string(byteOrderMark)) {
+		walker.current = len(string(byteOrderMark))
+	}
+	for !walker.end() {
+		walker.advance()
+	}
+	return walker.column
+}
+
+// aborted reports whether scanning stopped early because of MaxErrors.
+func (scanner *Scanner) aborted() bool {
+	return slices.ContainsFunc(scanner.errors, func(e Error) bool { return e.Code == ErrTooManyErrors })
+}
+
+// findToken returns the index of the old token that starts at offset and
+// has the same type and text as token, or -1 if there is none or it is on
+// the line where the edited range ended.
+func (scanner *Scanner) findToken(offset int, token Token, editEnd int) int {
+	old := scanner.tokens
+	i := sort.Search(len(old), func(i int) bool { return old[i].StartOffset >= offset })
+	for ; i < len(old) && old[i].StartOffset == offset; i++ {
+		if old[i].Type != token.Type || old[i].Text != token.Text {
+			continue
+		}
+		if offset < editEnd || !strings.ContainsAny(scanner.source[editEnd:offset], "\r\n") {
+			return -1
+		}
+		return i
+	}
+	return -1
+}
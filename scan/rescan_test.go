@@ -0,0 +1,237 @@
+package scan
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// checkRescan applies the edit to a scan of source with Rescan and compares
+// the result with a full scan of the edited source.
+func checkRescan(t *testing.T, source string, edit Range, newText string, options ScannerOptions) {
+	t.Helper()
+	scanner := NewScannerWithOptions(source, options)
+	old, _ := scanner.Scan()
+	old = slices.Clone(old)
+	delta, err := scanner.Rescan(edit, newText)
+	if err != nil {
+		t.Fatalf("Rescan(%v, %q) of %q: %v", edit, newText, source, err)
+	}
+
+	edited := source[:edit.Start] + newText + source[edit.End:]
+	fresh := NewScannerWithOptions(edited, options)
+	want, wantErrors := fresh.Scan()
+	name := fmt.Sprintf("replacing %v of %q with %q", edit, source, newText)
+	if !reflect.DeepEqual(scanner.tokens, want) {
+		t.Fatalf("%s: tokens\n%q\nwant\n%q", name, describe(scanner.tokens), describe(want))
+	}
+	if !reflect.DeepEqual(scanner.errors, wantErrors) {
+		t.Fatalf("%s: errors %q, want %q", name, messages(scanner.errors), messages(wantErrors))
+	}
+
+	// The delta turns the old tokens into the new ones.
+	end := delta.Index + len(delta.Removed)
+	if !reflect.DeepEqual(delta.Removed, old[delta.Index:end]) {
+		t.Fatalf("%s: removed %q, want %q", name, describe(delta.Removed), describe(old[delta.Index:end]))
+	}
+	if !reflect.DeepEqual(delta.Inserted, want[delta.Index:delta.Index+len(delta.Inserted)]) {
+		t.Fatalf("%s: inserted %q, want them at %d of %q", name, describe(delta.Inserted), delta.Index, describe(want))
+	}
+	if len(old)-len(delta.Removed)+len(delta.Inserted) != len(want) {
+		t.Fatalf("%s: delta removes %d and inserts %d of %d tokens, want %d", name,
+			len(delta.Removed), len(delta.Inserted), len(old), len(want))
+	}
+}
+
+func TestRescan(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		edit    Range
+		newText string
+	}{
+		{"rename", "let a = 1\nlet b = a + 2\nprint(b)\n", Range{14, 15}, "bee"},
+		{"insert at start", "let a = 1\n", Range{0, 0}, "x "},
+		{"append", "let a = 1", Range{9, 9}, "23\nb"},
+		{"delete everything", "let a = 1\nb\n", Range{0, 12}, ""},
+		{"join tokens", "a b\nc\n", Range{1, 2}, ""},
+		{"split a number", "x = 1234\ny\n", Range{6, 6}, " "},
+		{"exponent", "x = 1e\ny\n", Range{6, 6}, "+2"},
+		{"inside a string", "let s = \"hello\"\nlet t = 1\n", Range{10, 12}, "ipp"},
+		{"open a string", "a\nb + c\nd\n", Range{2, 2}, "\""},
+		{"close a string", "a = \"b\nc\nd\n", Range{6, 6}, "\""},
+		{"inside an interpolation", "let s = \"a ${b + c} d\"\nx\n", Range{15, 16}, "*"},
+		{"open an interpolation", "let s = \"a b c\"\nx\n", Range{11, 12}, "${b}"},
+		{"nested interpolation", "s = \"a ${\"b ${c} d\"} e\"\nx\n", Range{14, 15}, "cc"},
+		{"unclosed interpolation", "s = \"a ${b} c\"\nx\n", Range{10, 11}, ""},
+		{"crlf", "a = 1\r\nb = 2\r\nc = 3\r\n", Range{4, 5}, "10"},
+		{"break a crlf", "a\r\nb\r\nc\r\n", Range{2, 2}, "x"},
+		{"insert a crlf", "a b c\nd\n", Range{1, 2}, "\r\n"},
+		{"lone cr", "a\rb\rc\r", Range{2, 3}, "bb\rbb"},
+		{"multi-line insert", "let a = 1\nlet b = 2\n", Range{10, 10}, "fn f() {\n  return 1\n}\n"},
+		{"multi-line delete", "a\nb\nc\nd\ne\n", Range{2, 8}, ""},
+		{"replace lines", "a\nb\nc\nd\ne\n", Range{2, 6}, "x\ny\nz\nw\n"},
+		{"open a block comment", "a\nb\nc\n", Range{2, 2}, "/*"},
+		{"close a block comment", "a /* b\nc\nd\n", Range{7, 7}, "*/"},
+		{"nested block comment", "/* a /* b */ c */ d\ne\n", Range{5, 7}, ""},
+		{"line comment", "a // b\nc\n", Range{2, 4}, ""},
+		{"raw string", "a = `b\nc`\nd\n", Range{8, 9}, ""},
+		{"char literal", "c = 'a'\nd\n", Range{5, 6}, "ab"},
+		{"error after the edit", "a\n@\nb\n$\n", Range{0, 1}, "aa"},
+		{"fix an error", "a = 1.2.3\nb\n", Range{7, 9}, ""},
+		{"tabs", "\ta\n\t\tb\n", Range{0, 1}, ""},
+		{"unicode", "é = 1\nü\n", Range{0, 2}, "ß"},
+		{"byte order mark", "\uFEFFa\nb\n", Range{3, 4}, "c"},
+		{"line continuation", "a \\\nb\nc\n", Range{2, 3}, ""},
+		{"empty source", "", Range{0, 0}, "a"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkRescan(t, test.source, test.edit, test.newText, DefaultOptions())
+		})
+	}
+}
+
+func TestRescanOptions(t *testing.T) {
+	source := "fn f() {\n\tlet a = 1 // one\n\n\treturn a\n}\n"
+	edits := []struct {
+		edit    Range
+		newText string
+	}{
+		{Range{13, 14}, "bb"},
+		{Range{24, 27}, "two\nlet b = 2"},
+		{Range{9, 10}, ""},
+	}
+	configure := map[string]func(*ScannerOptions){
+		"indentation":       func(o *ScannerOptions) { o.Indentation = true },
+		"attach trivia":     func(o *ScannerOptions) { o.AttachTrivia = true },
+		"keep comments":     func(o *ScannerOptions) { o.KeepComments = true },
+		"insert semicolons": func(o *ScannerOptions) { o.Newlines = InsertSemicolons },
+		"suppress newlines": func(o *ScannerOptions) { o.Newlines = SuppressNewlines },
+		"final newline":     func(o *ScannerOptions) { o.FinalNewline = true },
+		"tab width":         func(o *ScannerOptions) { o.TabWidth = 4 },
+	}
+	for name, set := range configure {
+		options := DefaultOptions()
+		set(&options)
+		for _, e := range edits {
+			t.Run(name, func(t *testing.T) {
+				checkRescan(t, source, e.edit, e.newText, options)
+			})
+		}
+	}
+}
+
+func TestRescanMaxErrors(t *testing.T) {
+	options := DefaultOptions()
+	options.MaxErrors = 3
+	tests := []struct {
+		name    string
+		source  string
+		edit    Range
+		newText string
+	}{
+		{"below the limit", "@\na\n$\n", Range{2, 3}, "b"},
+		{"reach the limit before the edit", "@\n$\na\n", Range{4, 5}, "#"},
+		{"reach the limit after the edit", "a\n@\n$\n", Range{0, 1}, "#"},
+		{"drop below the limit", "@\n$\n#\na\n", Range{0, 1}, ""},
+		{"already stopped", "@\n$\n#\n%\na\n", Range{9, 10}, "b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkRescan(t, test.source, test.edit, test.newText, options)
+		})
+	}
+}
+
+// TestRescanEveryEdit makes small edits at every offset of a source with
+// strings, interpolations, comments and line breaks of each kind, with
+// and without an error limit it can reach.
+func TestRescanEveryEdit(t *testing.T) {
+	source := "let s = \"a ${b + \"c\"} d\" // e\r\nfn f(x: int) -> int {\r\treturn x ** 2 /* g\n*/ }\n`r\nr` 'c' 1e+3 $\n"
+	insertions := []string{"a", " ", "\n", "\r\n", "\"", "${", "}", "/*", "*/", "`", "1", "+", "@"}
+	limited := DefaultOptions()
+	limited.MaxErrors = 2
+	for _, options := range []ScannerOptions{DefaultOptions(), limited} {
+		for offset := 0; offset <= len(source); offset++ {
+			for _, text := range insertions {
+				checkRescan(t, source, Range{offset, offset}, text, options)
+			}
+			for n := 1; n <= 3 && offset+n <= len(source); n++ {
+				checkRescan(t, source, Range{offset, offset + n}, "", options)
+			}
+		}
+	}
+}
+
+func TestRescanTwice(t *testing.T) {
+	source := "let a = 1\nlet b = \"${a}\"\n"
+	scanner := NewScanner(source)
+	scanner.Scan()
+	edits := []struct {
+		edit    Range
+		newText string
+	}{
+		{Range{4, 5}, "aa"},
+		{Range{11, 11}, "let c = 3\n"},
+		{Range{0, 10}, ""},
+	}
+	for _, e := range edits {
+		if _, err := scanner.Rescan(e.edit, e.newText); err != nil {
+			t.Fatalf("Rescan(%v, %q): %v", e.edit, e.newText, err)
+		}
+		source = source[:e.edit.Start] + e.newText + source[e.edit.End:]
+		fresh := NewScanner(source)
+		want, _ := fresh.Scan()
+		if !reflect.DeepEqual(scanner.tokens, want) {
+			t.Fatalf("after replacing %v with %q: tokens\n%q\nwant\n%q", e.edit, e.newText, describe(scanner.tokens), describe(want))
+		}
+	}
+}
+
+func TestRescanErrors(t *testing.T) {
+	reader := NewScannerFromReader(strings.NewReader("let a = 1"))
+	reader.Scan()
+	if _, err := reader.Rescan(Range{0, 0}, "x"); err == nil {
+		t.Errorf("Rescan of a reader scanner succeeded")
+	}
+
+	set := NewFileSet()
+	options := DefaultOptions()
+	options.File = set.AddFile("a.lol", "let a = 1")
+	file := NewScannerWithOptions("let a = 1", options)
+	file.Scan()
+	if _, err := file.Rescan(Range{0, 0}, "x"); err == nil {
+		t.Errorf("Rescan of a scanner with a File succeeded")
+	}
+
+	for _, edit := range []Range{{-1, 0}, {2, 1}, {0, 10}} {
+		scanner := NewScanner("let a = 1")
+		scanner.Scan()
+		if _, err := scanner.Rescan(edit, "x"); err == nil {
+			t.Errorf("Rescan(%v) succeeded", edit)
+		}
+		if got := describe(scanner.tokens); len(got) != 5 {
+			t.Errorf("Rescan(%v) changed the tokens to %q", edit, got)
+		}
+	}
+}
+
+func TestRescanIsIncremental(t *testing.T) {
+	source := "let a = 1 + 2 + 3 + 4 + 5 + 6\nlet b = a\nprint(b)\nprint(a)\n"
+	scanner := NewScanner(source)
+	old, _ := scanner.Scan()
+	old = slices.Clone(old)
+	b := strings.Index(source, "b")
+	delta, err := scanner.Rescan(Range{Start: b, End: b + 1}, "bee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tokens a few characters before the edit are scanned again, up to
+	// the first token on a later line that is unchanged.
+	if delta.Index == 0 || delta.Index+len(delta.Removed) == len(old) {
+		t.Errorf("delta at %d removes %q, want the first and last lines kept", delta.Index, describe(delta.Removed))
+	}
+}
@@ -0,0 +1,61 @@
+package scan
+
+import "time"
+
+type ScanResult struct {
+	Tokens []Token
+	Errors []Error
+}
+
+// Scan tokenizes source with the default options.
+func Scan(source string) ScanResult {
+	scanner := NewScanner(source)
+	tokens, errors := scanner.Scan()
+	return ScanResult{Tokens: tokens, Errors: errors}
+}
+
+// ScanTimed is like Scan but also reports how long scanning took.
+func ScanTimed(source string) (ScanResult, time.Duration) {
+	begin := time.Now()
+	result := Scan(source)
+	return result, time.Since(begin)
+}
+
+// ScanPrefix scans at most n tokens of source followed by an EOF token,
+// leaving the rest of the source untouched. The EOF token sits at the start
+// of the first token left out. A negative n is taken as 0.
+func ScanPrefix(source string, n int) []Token {
+	n = max(n, 0)
+	scanner := NewScanner(source)
+	tokens := make([]Token, 0, n+1)
+	for token := range scanner.Tokens() {
+		if len(tokens) == n && token.Type != EOF {
+			token = Token{
+				Type:        EOF,
+				Line:        token.Line,
+				Column:      token.Column,
+				StartOffset: token.StartOffset,
+				EndOffset:   token.StartOffset,
+				Pos:         token.Pos,
+			}
+		}
+		tokens = append(tokens, token)
+		if token.Type == EOF {
+			break
+		}
+	}
+	return tokens
+}
+
+// Meaningful returns the tokens that are not trivia.
+// The trailing EOF token is kept.
+func (result ScanResult) Meaningful() []Token {
+	tokens := make([]Token, 0, len(result.Tokens))
+	for _, token := range result.Tokens {
+		if token.IsTrivia() {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
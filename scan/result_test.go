@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMeaningful(t *testing.T) {
+	options := DefaultOptions()
+	options.KeepComments = true
+	tokens := scanWith(t, "/// doc\nlet a = 1 // one\n\n/* two */ a\n", options)
+	result := ScanResult{Tokens: tokens}
+	got := describe(result.Meaningful())
+	want := []string{`Let "let"`, `Identifier "a"`, `Assign "="`, `Number "1"`, `Identifier "a"`, `EOF ""`}
+	if !slices.Equal(got, want) {
+		t.Errorf("Meaningful() = %q, want %q", got, want)
+	}
+	if len(result.Tokens) == len(want) {
+		t.Errorf("Tokens holds no trivia: %q", describe(result.Tokens))
+	}
+}
+
+func TestScanPrefix(t *testing.T) {
+	source := "let a = 1\n\tprint(a)"
+	tokens := ScanPrefix(source, 5)
+	want := []string{`Let "let"`, `Identifier "a"`, `Assign "="`, `Number "1"`, `Newline "\n"`, `EOF ""`}
+	if got := describe(tokens); !slices.Equal(got, want) {
+		t.Fatalf("ScanPrefix(%q, 5) = %q, want %q", source, got, want)
+	}
+	// EOF takes the place of "print", the first token left out.
+	eof := tokens[5]
+	if eof.Line != 2 || eof.Column != 2 || eof.StartOffset != 11 || eof.EndOffset != 11 {
+		t.Errorf("EOF at %d:%d [%d, %d), want 2:2 [11, 11)", eof.Line, eof.Column, eof.StartOffset, eof.EndOffset)
+	}
+
+	all := Scan(source).Tokens
+	if got := ScanPrefix(source, 100); !slices.Equal(describe(got), describe(all)) {
+		t.Errorf("ScanPrefix(%q, 100) = %q, want all tokens %q", source, describe(got), describe(all))
+	}
+}
+
+func TestScanPrefixEmpty(t *testing.T) {
+	source := "  let a = 1"
+	for _, n := range []int{0, -1, -2, -100} {
+		tokens := ScanPrefix(source, n)
+		if len(tokens) != 1 || tokens[0].Type != EOF {
+			t.Errorf("ScanPrefix(%q, %d) = %q, want only EOF", source, n, describe(tokens))
+			continue
+		}
+		if eof := tokens[0]; eof.Column != 3 || eof.StartOffset != 2 {
+			t.Errorf("ScanPrefix(%q, %d) puts EOF at column %d, offset %d, want column 3, offset 2", source, n, eof.Column, eof.StartOffset)
+		}
+	}
+	if tokens := ScanPrefix("", 0); len(tokens) != 1 || tokens[0].Type != EOF {
+		t.Errorf("ScanPrefix(\"\", 0) = %q, want only EOF", describe(tokens))
+	}
+}
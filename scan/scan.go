@@ -2,23 +2,63 @@ package scan
 
 import (
 	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Type int
 
 type Token struct {
-	Type Type
-	Line int
-	Text string
+	Type Type `json:"type"`
+	Line int  `json:"line"`
+	// Column is the 1-based column of the first character of the token.
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+	// StartOffset and EndOffset are the byte offsets of the token in the
+	// source, so source[StartOffset:EndOffset] is its full spelling.
+	StartOffset int `json:"startOffset"`
+	EndOffset   int `json:"endOffset"`
+	// Pos is where the token starts in the File of the scanner's options,
+	// or NoPos if the scanner has no File.
+	Pos Pos `json:"pos,omitempty"`
+	// Base is the radix a Number token is written in: 2, 8, 10 or 16. The
+	// Text of a number keeps its "0b", "0o" or "0x" prefix.
+	Base int `json:"base,omitempty"`
+	// Kind is the numeric type a Number token denotes.
+	Kind NumberKind `json:"kind,omitempty"`
+	// LeadingTrivia and TrailingTrivia hold the comments and blank lines
+	// around the token when the scanner attaches trivia.
+	LeadingTrivia  []Token `json:"leadingTrivia,omitempty"`
+	TrailingTrivia []Token `json:"trailingTrivia,omitempty"`
+}
+
+// IsTrivia reports whether the token only carries layout, not meaning.
+func (token Token) IsTrivia() bool {
+	switch token.Type {
+	case Newline, Comment, DocComment:
+		return true
+	default:
+		return false
+	}
 }
 
 func (token Token) String() string {
-	return fmt.Sprintf("%d %q", token.Type, token.Text)
+	return fmt.Sprintf("%s %q", token.Type, token.Text)
 }
 
 const (
 	EOF Type = iota
 	Newline
+	Indent  // increased indentation
+	Dedent  // decreased indentation
+	Illegal // text that does not form a valid token
 	// Single
 	LeftParen    // (
 	RightParen   // )
@@ -39,65 +79,335 @@ const (
 	Plus         // +
 	Minus        // -
 	Pipe         // |
+	Question     // ?
+	Percent      // %
+	Ampersand    // &
+	Caret        // ^
 
 	// Multiple
-	Equals        // ==
-	NotEquals     // !=
-	GreaterEquals // >=
-	LesserEquals  // <=
+	Equals           // ==
+	NotEquals        // !=
+	GreaterEquals    // >=
+	LesserEquals     // <=
+	ColonColon       // ::
+	StarStar         // **
+	QuestionQuestion // ??
+	Elvis            // ?:
+	Arrow            // ->
+	FatArrow         // =>
+	PipeForward      // |>
+	And              // &&
+	Or               // ||
+	PlusAssign       // +=
+	MinusAssign      // -=
+	StarAssign       // *=
+	SlashAssign      // /=
+	PlusPlus         // ++
+	MinusMinus       // --
+	Ellipsis         // ...
+	DotDot           // ..
 
 	// Literals
-	Identifier // foo
-	String     // "foo"
-	Number     // 1337
-	True       // true
-	False      // false
+	Identifier  // foo
+	String      // "foo"
+	StringStart // "foo ${
+	StringMid   // } foo ${
+	StringEnd   // } foo"
+	RawString   // `foo`
+	Char        // 'a'
+	Comment     // // foo
+	DocComment  // /// foo
+	Number      // 1337
+	True        // true
+	False       // false
 
 	// Keywords
-	Struct // struct
-	Return // return
-	Int    // int
-	Double // double
-	Float  // float
-	Bool   // bool
-	For    // for
-	In     // in
-	Let    // let
-	If     // if
-	Else   // else
+	Struct   // struct
+	Return   // return
+	Int      // int
+	Double   // double
+	Float    // float
+	Bool     // bool
+	For      // for
+	In       // in
+	Let      // let
+	If       // if
+	Else     // else
+	Fn       // fn
+	Match    // match
+	While    // while
+	Break    // break
+	Continue // continue
 )
 
-func keywordOrIdentifier(text string) Type {
-	switch text {
-	case "struct":
-		return Struct
-	case "return":
-		return Return
-	case "int":
-		return Int
-	case "double":
-		return Double
-	case "float":
-		return Float
-	case "bool":
-		return Bool
-	case "for":
-		return For
-	case "in":
-		return In
-	case "let":
-		return Let
-	case "if":
-		return If
-	case "else":
-		return Else
-	case "true":
-		return True
-	case "false":
-		return False
-	default:
-		return Identifier
+var typeNames = [...]string{
+	EOF:              "EOF",
+	Newline:          "Newline",
+	Indent:           "Indent",
+	Dedent:           "Dedent",
+	Illegal:          "Illegal",
+	LeftParen:        "LeftParen",
+	RightParen:       "RightParen",
+	LeftBracket:      "LeftBracket",
+	RightBracket:     "RightBracket",
+	LeftCurly:        "LeftCurly",
+	RightCurly:       "RightCurly",
+	LeftAngle:        "LeftAngle",
+	RightAngle:       "RightAngle",
+	Assign:           "Assign",
+	Comma:            "Comma",
+	Dot:              "Dot",
+	Colon:            "Colon",
+	SemiColon:        "SemiColon",
+	Bang:             "Bang",
+	Slash:            "Slash",
+	Star:             "Star",
+	Plus:             "Plus",
+	Minus:            "Minus",
+	Pipe:             "Pipe",
+	Question:         "Question",
+	Percent:          "Percent",
+	Ampersand:        "Ampersand",
+	Caret:            "Caret",
+	Equals:           "Equals",
+	NotEquals:        "NotEquals",
+	GreaterEquals:    "GreaterEquals",
+	LesserEquals:     "LesserEquals",
+	ColonColon:       "ColonColon",
+	StarStar:         "StarStar",
+	QuestionQuestion: "QuestionQuestion",
+	Elvis:            "Elvis",
+	Arrow:            "Arrow",
+	FatArrow:         "FatArrow",
+	PipeForward:      "PipeForward",
+	And:              "And",
+	Or:               "Or",
+	PlusAssign:       "PlusAssign",
+	MinusAssign:      "MinusAssign",
+	StarAssign:       "StarAssign",
+	SlashAssign:      "SlashAssign",
+	PlusPlus:         "PlusPlus",
+	MinusMinus:       "MinusMinus",
+	Ellipsis:         "Ellipsis",
+	DotDot:           "DotDot",
+	Identifier:       "Identifier",
+	String:           "String",
+	StringStart:      "StringStart",
+	StringMid:        "StringMid",
+	StringEnd:        "StringEnd",
+	RawString:        "RawString",
+	Char:             "Char",
+	Comment:          "Comment",
+	DocComment:       "DocComment",
+	Number:           "Number",
+	True:             "True",
+	False:            "False",
+	Struct:           "Struct",
+	Return:           "Return",
+	Int:              "Int",
+	Double:           "Double",
+	Float:            "Float",
+	Bool:             "Bool",
+	For:              "For",
+	In:               "In",
+	Let:              "Let",
+	If:               "If",
+	Else:             "Else",
+	Fn:               "Fn",
+	Match:            "Match",
+	While:            "While",
+	Break:            "Break",
+	Continue:         "Continue",
+}
+
+func (typ Type) String() string {
+	if typ >= 0 && int(typ) < len(typeNames) {
+		return typeNames[typ]
+	}
+	return fmt.Sprintf("Type(%d)", int(typ))
+}
+
+func (typ Type) MarshalText() ([]byte, error) {
+	return []byte(typ.String()), nil
+}
+
+func (typ *Type) UnmarshalText(text []byte) error {
+	parsed, err := ParseType(string(text))
+	if err != nil {
+		return err
+	}
+	*typ = parsed
+	return nil
+}
+
+var typesByName = func() map[string]Type {
+	types := make(map[string]Type, len(typeNames))
+	for typ, name := range typeNames {
+		types[name] = Type(typ)
+	}
+	return types
+}()
+
+// ParseType returns the type whose String is name, such as "LeftParen".
+func ParseType(name string) (Type, error) {
+	if typ, ok := typesByName[name]; ok {
+		return typ, nil
+	}
+	return 0, fmt.Errorf("unknown token type %q", name)
+}
+
+// NumberKind tells which numeric type a number literal has. An integer
+// without a suffix is an IntNumber and one with a fractional part or an
+// exponent a DoubleNumber; the suffixes 'i', 'f' and 'd' select a kind
+// explicitly.
+type NumberKind int
+
+const (
+	IntNumber NumberKind = iota + 1
+	FloatNumber
+	DoubleNumber
+)
+
+var numberKindNames = [...]string{
+	IntNumber:    "Int",
+	FloatNumber:  "Float",
+	DoubleNumber: "Double",
+}
+
+func (kind NumberKind) String() string {
+	if kind > 0 && int(kind) < len(numberKindNames) {
+		return numberKindNames[kind]
+	}
+	return fmt.Sprintf("NumberKind(%d)", int(kind))
+}
+
+func (kind NumberKind) MarshalText() ([]byte, error) {
+	return []byte(kind.String()), nil
+}
+
+func (kind *NumberKind) UnmarshalText(text []byte) error {
+	for k, name := range numberKindNames {
+		if name != "" && name == string(text) {
+			*kind = NumberKind(k)
+			return nil
+		}
 	}
+	return fmt.Errorf("unknown number kind %q", text)
+}
+
+var keywords = map[string]Type{
+	"struct":   Struct,
+	"return":   Return,
+	"int":      Int,
+	"double":   Double,
+	"float":    Float,
+	"bool":     Bool,
+	"for":      For,
+	"in":       In,
+	"let":      Let,
+	"if":       If,
+	"else":     Else,
+	"fn":       Fn,
+	"match":    Match,
+	"while":    While,
+	"break":    Break,
+	"continue": Continue,
+	"true":     True,
+	"false":    False,
+}
+
+var operators = map[string]Type{
+	"(":   LeftParen,
+	")":   RightParen,
+	"[":   LeftBracket,
+	"]":   RightBracket,
+	"{":   LeftCurly,
+	"}":   RightCurly,
+	"<":   LeftAngle,
+	">":   RightAngle,
+	"=":   Assign,
+	",":   Comma,
+	".":   Dot,
+	":":   Colon,
+	";":   SemiColon,
+	"!":   Bang,
+	"/":   Slash,
+	"*":   Star,
+	"+":   Plus,
+	"-":   Minus,
+	"|":   Pipe,
+	"==":  Equals,
+	"!=":  NotEquals,
+	">=":  GreaterEquals,
+	"<=":  LesserEquals,
+	"::":  ColonColon,
+	"**":  StarStar,
+	"?":   Question,
+	"??":  QuestionQuestion,
+	"?:":  Elvis,
+	"->":  Arrow,
+	"=>":  FatArrow,
+	"|>":  PipeForward,
+	"%":   Percent,
+	"&":   Ampersand,
+	"^":   Caret,
+	"&&":  And,
+	"||":  Or,
+	"+=":  PlusAssign,
+	"-=":  MinusAssign,
+	"*=":  StarAssign,
+	"/=":  SlashAssign,
+	"++":  PlusPlus,
+	"--":  MinusMinus,
+	"...": Ellipsis,
+	"..":  DotDot,
+}
+
+// Keywords returns the reserved words of the language in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Operators returns the spelling of every operator and punctuation token.
+// The returned map is a copy and may be modified by the caller.
+func Operators() map[string]Type {
+	ops := make(map[string]Type, len(operators))
+	for text, typ := range operators {
+		ops[text] = typ
+	}
+	return ops
+}
+
+// spellings holds the text of every operator type, indexed by type.
+var spellings = func() []string {
+	texts := make([]string, len(typeNames))
+	for text, typ := range operators {
+		texts[typ] = text
+	}
+	return texts
+}()
+
+// startsToken tells for every ASCII character whether canStartToken accepts
+// it, sparing the hot path a map lookup per character.
+var startsToken = func() (table [utf8.RuneSelf]bool) {
+	for c := range rune(utf8.RuneSelf) {
+		_, operator := operators[string(c)]
+		table[c] = operator || isAlpha(c) || isDigit(c) || isWhitespace(c) || c == 0 ||
+			c == '"' || c == '\'' || c == '`' || c == '\\'
+	}
+	return table
+}()
+
+func (scanner *Scanner) keywordOrIdentifier(text string) Type {
+	if typ, ok := scanner.keywords[text]; ok {
+		return typ
+	}
+	return Identifier
 }
 
 type Scanner struct {
@@ -106,119 +416,529 @@ type Scanner struct {
 	start   int
 	current int
 	line    int
-	errors  []string
+	// column is the column of current and startColumn that of start,
+	// both starting at 1.
+	column      int
+	startColumn int
+	// terminatorColumn is the column of the most recent line break.
+	terminatorColumn int
+	errors           []Error
+	options          ScannerOptions
+	done             bool
+	// stopped is set once MaxErrors is reached, ending the scan where it
+	// stands.
+	stopped bool
+	// interpolations holds, for every open "${", the number of '{' nested
+	// inside it that are still waiting for their '}'.
+	interpolations []int
+	// interner holds the text of the identifiers and keywords seen so far,
+	// so repeated names share storage.
+	interner *Interner
+	keywords map[string]Type
+	// indents is the stack of indentation widths of the enclosing blocks in
+	// Indentation mode, starting with the top level at width 0.
+	indents     []int
+	atLineStart bool
+	// last is the most recent token other than a comment added to the stream,
+	// and trivia the comments and blank lines waiting for the next token in
+	// AttachTrivia mode.
+	last   Token
+	trivia []Token
+	eof    Token
+	// reader supplies the rest of the source when the scanner reads from an
+	// io.Reader, in which case source only holds a window of it starting at
+	// byte offset base. It is nil once the reader is exhausted.
+	reader io.Reader
+	chunk  []byte
+	base   int
+
+	// reported is how many of the errors NextToken has already returned.
+	reported int
 }
 
+// bytesPerToken is a generous guess at the average number of source bytes
+// per token, used to allocate the token slice up front.
+const bytesPerToken = 4
+
 func NewScanner(source string) Scanner {
-	return Scanner{
-		tokens:  make([]Token, 0),
-		source:  source,
-		start:   0,
-		current: 0,
-		line:    1,
-		errors:  make([]string, 0),
+	return NewScannerWithOptions(source, DefaultOptions())
+}
+
+func NewScannerWithOptions(source string, options ScannerOptions) Scanner {
+	scanner := Scanner{
+		tokens:      make([]Token, 0, len(source)/bytesPerToken+1),
+		source:      source,
+		start:       0,
+		current:     0,
+		line:        1,
+		column:      1,
+		startColumn: 1,
+		errors:      make([]Error, 0),
+		options:     options,
+		interner:    options.Interner,
+		keywords:    maps.Clone(keywords),
+		indents:     []int{0},
+		atLineStart: true,
+		last:        Token{Type: Newline},
+	}
+
+	if scanner.interner == nil {
+		scanner.interner = NewInterner()
+	}
+	if options.TrueLiterals != nil {
+		delete(scanner.keywords, "true")
+		for _, word := range options.TrueLiterals {
+			scanner.keywords[word] = True
+		}
 	}
+	if options.FalseLiterals != nil {
+		delete(scanner.keywords, "false")
+		for _, word := range options.FalseLiterals {
+			scanner.keywords[word] = False
+		}
+	}
+	return scanner
 }
 
-func (scanner *Scanner) Scan() ([]Token, []string) {
-	for !scanner.end() {
-		scanner.start = scanner.current
-		scanner.scanToken()
+// AddKeyword reserves word so that it scans as typ instead of Identifier.
+// It only affects this scanner; the built-in keywords are left untouched.
+func (scanner *Scanner) AddKeyword(word string, typ Type) {
+	scanner.keywords[word] = typ
+}
+
+// Interner returns the interner holding the names the scanner has seen.
+func (scanner *Scanner) Interner() *Interner {
+	return scanner.interner
+}
+
+// Reset prepares the scanner for a new source while reusing its buffers.
+// Slices returned by a previous Scan are overwritten by the next one. The
+// interner is kept, so names keep their IDs from one source to the next.
+func (scanner *Scanner) Reset(source string) {
+	scanner.tokens = slices.Grow(scanner.tokens[:0], len(source)/bytesPerToken+1)
+	scanner.errors = scanner.errors[:0]
+	scanner.reported = 0
+	scanner.source = source
+	scanner.reader = nil
+	scanner.base = 0
+	scanner.start = 0
+	scanner.current = 0
+	scanner.column = 1
+	scanner.startColumn = 1
+	scanner.line = 1
+	scanner.done = false
+	scanner.stopped = false
+	scanner.interpolations = scanner.interpolations[:0]
+	scanner.indents = append(scanner.indents[:0], 0)
+	scanner.atLineStart = true
+	scanner.last = Token{Type: Newline}
+	scanner.trivia = nil
+}
+
+func (scanner *Scanner) Scan() ([]Token, []Error) {
+	for scanner.step() {
 	}
 
-	scanner.addToken(Token{Type: EOF, Line: scanner.line})
 	return scanner.tokens, scanner.errors
 }
 
+// ScanRange scans only source[start:end] with the scanner's options and
+// keywords, reporting the same lines, columns and offsets as a scan of the
+// whole source would. The range is scanned as if the source ended at end, so
+// a token crossing end is cut short there. The scanner itself is unaffected.
+// It is not supported by scanners reading from an io.Reader.
+//
+// Offsets outside the source are moved to its nearest end, and a start after
+// end to end, which scans an empty range.
+func (scanner *Scanner) ScanRange(start, end int) ([]Token, []Error) {
+	end = min(max(end, 0), len(scanner.source))
+	start = min(max(start, 0), end)
+	sub := NewScannerWithOptions(scanner.source[:end], scanner.options)
+	sub.keywords = scanner.keywords
+	for sub.current < start {
+		sub.countLine(sub.advance())
+	}
+	sub.atLineStart = start == 0 || isLineTerminator(rune(scanner.source[start-1]))
+	return sub.Scan()
+}
+
+// Tokens returns an iterator that scans lazily and yields every token up to
+// and including EOF. Yielded tokens are not kept by the scanner.
+func (scanner *Scanner) Tokens() iter.Seq[Token] {
+	return func(yield func(Token) bool) {
+		for {
+			token := scanner.Consume()
+			if !yield(token) || token.Type == EOF {
+				return
+			}
+		}
+	}
+}
+
+// Peek returns the token n positions ahead without consuming anything, so
+// Peek(0) is the token the next Consume returns. Peeking past the end keeps
+// returning EOF.
+//
+// With AttachTrivia, a comment later on its line still trails a token, so
+// the token after it is scanned before it is returned.
+func (scanner *Scanner) Peek(n int) Token {
+	need := n + 1
+	if scanner.options.AttachTrivia {
+		need++
+	}
+	for len(scanner.tokens) < need && scanner.step() {
+	}
+	if n < len(scanner.tokens) {
+		return scanner.tokens[n]
+	}
+	return scanner.eof
+}
+
+// Consume scans as far as needed and returns the next token. Once EOF has
+// been returned, every further call returns EOF again.
+func (scanner *Scanner) Consume() Token {
+	token := scanner.Peek(0)
+	if len(scanner.tokens) > 0 {
+		scanner.tokens = append(scanner.tokens[:0], scanner.tokens[1:]...)
+	}
+	return token
+}
+
+// NextToken consumes the next token like Consume and also returns the errors
+// reported since the previous call, joined into one, or nil if there were
+// none. After EOF it keeps returning EOF and a nil error.
+func (scanner *Scanner) NextToken() (Token, error) {
+	token := scanner.Consume()
+	err := joinErrors(scanner.errors[scanner.reported:])
+	scanner.reported = len(scanner.errors)
+	return token, err
+}
+
+// step scans the next token, or adds the final EOF token once the source is
+// exhausted. It returns false when there is nothing left to scan.
+func (scanner *Scanner) step() bool {
+	if scanner.done {
+		return false
+	}
+
+	if scanner.stopped || scanner.end() {
+		scanner.begin()
+		if len(scanner.interpolations) > 0 && !scanner.stopped {
+			scanner.err(ErrUnterminated, "unterminated string interpolation")
+			scanner.interpolations = scanner.interpolations[:0]
+		}
+		if scanner.options.Newlines == InsertSemicolons {
+			if endsStatement(scanner.last.Type) {
+				scanner.addToken(scanner.newToken(SemiColon, ""))
+			}
+		} else if scanner.options.FinalNewline && scanner.options.Newlines != SuppressNewlines && !scanner.stopped && scanner.missingFinalNewline() {
+			scanner.addToken(scanner.newToken(Newline, ""))
+		}
+		for len(scanner.indents) > 1 {
+			scanner.indents = scanner.indents[:len(scanner.indents)-1]
+			scanner.addToken(scanner.newToken(Dedent, ""))
+		}
+		// After an abort, EOF marks where scanning stopped.
+		scanner.eof = scanner.newToken(EOF, "")
+		if !scanner.stopped {
+			scanner.eof.Line, scanner.eof.Column = scanner.eofPosition()
+		}
+		scanner.addToken(scanner.eof)
+		scanner.done = true
+		return true
+	}
+
+	scanner.begin()
+	if scanner.options.Indentation && scanner.atLineStart {
+		scanner.atLineStart = false
+		scanner.indentation()
+		scanner.begin()
+		if scanner.end() {
+			return true
+		}
+	}
+	scanner.scanToken()
+
+	if scanner.tooManyErrors() {
+		scanner.err(ErrTooManyErrors, "too many errors, aborting")
+		scanner.stopped = true
+	}
+	return true
+}
+
 func (scanner *Scanner) scanToken() {
 	c := scanner.advance()
 
 	switch c {
 	case '(':
-		scanner.addToken(scanner.newToken(LeftParen, string(c)))
+		scanner.addOperator(LeftParen)
 	case ')':
-		scanner.addToken(scanner.newToken(RightParen, string(c)))
+		scanner.addOperator(RightParen)
 	case '[':
-		scanner.addToken(scanner.newToken(LeftBracket, string(c)))
+		scanner.addOperator(LeftBracket)
 	case ']':
-		scanner.addToken(scanner.newToken(RightBracket, string(c)))
+		scanner.addOperator(RightBracket)
 	case '{':
-		scanner.addToken(scanner.newToken(LeftCurly, string(c)))
+		if depth := len(scanner.interpolations); depth > 0 {
+			scanner.interpolations[depth-1]++
+		}
+		scanner.addOperator(LeftCurly)
 	case '}':
-		scanner.addToken(scanner.newToken(RightCurly, string(c)))
+		if depth := len(scanner.interpolations); depth > 0 {
+			if scanner.interpolations[depth-1] == 0 {
+				scanner.interpolations = scanner.interpolations[:depth-1]
+				scanner.stringSegment(StringEnd, StringMid)
+				break
+			}
+			scanner.interpolations[depth-1]--
+		}
+		scanner.addOperator(RightCurly)
 	case '<':
 		if scanner.match('=') {
-			scanner.addToken(scanner.newToken(LesserEquals, scanner.lexeme()))
+			scanner.addOperator(LesserEquals)
 		} else {
-			scanner.addToken(scanner.newToken(LeftAngle, string(c)))
+			scanner.addOperator(LeftAngle)
 		}
 	case '>':
 		if scanner.match('=') {
-			scanner.addToken(scanner.newToken(GreaterEquals, scanner.lexeme()))
+			scanner.addOperator(GreaterEquals)
 		} else {
-			scanner.addToken(scanner.newToken(RightAngle, string(c)))
+			scanner.addOperator(RightAngle)
 		}
 	case '=':
 		if scanner.match('=') {
-			scanner.addToken(scanner.newToken(Equals, scanner.lexeme()))
+			if scanner.peek() == '=' {
+				for scanner.match('=') {
+				}
+				scanner.err(ErrUnexpected, fmt.Sprintf("unexpected '%s'", scanner.lexeme()))
+				scanner.reject(scanner.line)
+			} else {
+				scanner.addOperator(Equals)
+			}
+		} else if scanner.match('>') {
+			scanner.addOperator(FatArrow)
 		} else {
-			scanner.addToken(scanner.newToken(Assign, string(c)))
+			scanner.addOperator(Assign)
 		}
 	case '!':
 		if scanner.match('=') {
-			scanner.addToken(scanner.newToken(NotEquals, scanner.lexeme()))
+			scanner.addOperator(NotEquals)
 		} else {
-			scanner.addToken(scanner.newToken(Bang, string(c)))
+			scanner.addOperator(Bang)
 		}
 	case ',':
-		scanner.addToken(scanner.newToken(Comma, string(c)))
+		scanner.addOperator(Comma)
 	case '.':
-		scanner.addToken(scanner.newToken(Dot, string(c)))
+		if scanner.peek() == '.' && scanner.peekNext() == '.' {
+			scanner.advance()
+			scanner.advance()
+			scanner.addOperator(Ellipsis)
+		} else if scanner.match('.') {
+			scanner.addOperator(DotDot)
+		} else {
+			scanner.addOperator(Dot)
+		}
 	case ':':
-		scanner.addToken(scanner.newToken(Colon, string(c)))
+		if scanner.match(':') {
+			scanner.addOperator(ColonColon)
+		} else {
+			scanner.addOperator(Colon)
+		}
 	case ';':
-		scanner.addToken(scanner.newToken(SemiColon, string(c)))
+		scanner.addOperator(SemiColon)
 	case '/':
 		if scanner.match('/') {
-			for scanner.peek() != '\n' && !scanner.end() {
-				scanner.advance()
-			}
+			scanner.skipLine()
+			scanner.comment(scanner.line)
 		} else if scanner.match('*') {
-			scanner.cComment()
+			line := scanner.line
+			if scanner.cComment() {
+				scanner.comment(line)
+			}
+		} else if scanner.match('=') {
+			scanner.addOperator(SlashAssign)
 		} else {
-			scanner.addToken(scanner.newToken(Slash, string(c)))
+			scanner.addOperator(Slash)
 		}
 	case '*':
 		if scanner.match('/') {
-			scanner.err("Unexpected comment ending")
+			scanner.err(ErrUnexpected, "Unexpected comment ending")
+			scanner.synchronize()
+			scanner.reject(scanner.line)
+		} else if scanner.match('*') {
+			scanner.addOperator(StarStar)
+		} else if scanner.match('=') {
+			scanner.addOperator(StarAssign)
 		} else {
-			scanner.addToken(scanner.newToken(Star, string(c)))
+			scanner.addOperator(Star)
 		}
 	case '+':
-		scanner.addToken(scanner.newToken(Plus, string(c)))
+		if scanner.match('=') {
+			scanner.addOperator(PlusAssign)
+		} else if scanner.match('+') {
+			scanner.addOperator(PlusPlus)
+		} else {
+			scanner.addOperator(Plus)
+		}
 	case '-':
-		scanner.addToken(scanner.newToken(Minus, string(c)))
+		if scanner.match('>') {
+			scanner.addOperator(Arrow)
+		} else if scanner.match('=') {
+			scanner.addOperator(MinusAssign)
+		} else if scanner.match('-') {
+			scanner.addOperator(MinusMinus)
+		} else {
+			scanner.addOperator(Minus)
+		}
 	case '|':
-		scanner.addToken(scanner.newToken(Pipe, string(c)))
+		if scanner.match('|') {
+			scanner.addOperator(Or)
+		} else if scanner.match('>') {
+			scanner.addOperator(PipeForward)
+		} else {
+			scanner.addOperator(Pipe)
+		}
+	case '&':
+		if scanner.match('&') {
+			scanner.addOperator(And)
+		} else {
+			scanner.addOperator(Ampersand)
+		}
+	case '%':
+		scanner.addOperator(Percent)
+	case '^':
+		scanner.addOperator(Caret)
+	case '#':
+		// A shebang line lets a script be run directly. It can only be the
+		// first thing in the source, which is the only place that is line 1,
+		// column 1.
+		if scanner.line == 1 && scanner.startColumn == 1 && scanner.match('!') {
+			scanner.skipLine()
+			scanner.comment(scanner.line)
+		} else {
+			scanner.illegal()
+		}
+	case '\\':
+		if scanner.match('\r') {
+			scanner.match('\n')
+			scanner.newLine(scanner.startColumn + 1)
+		} else if scanner.match('\n') {
+			scanner.newLine(scanner.startColumn + 1)
+		} else if scanner.end() {
+			// There is no next line to continue onto.
+			scanner.err(ErrLineContinuation, "line continuation at end of file")
+			scanner.reject(scanner.line)
+		} else {
+			scanner.err(ErrLineContinuation, "Unexpected '\\' not followed by a line break")
+			scanner.synchronize()
+			scanner.reject(scanner.line)
+		}
+	case '?':
+		if scanner.match(':') {
+			scanner.addOperator(Elvis)
+		} else if scanner.match('?') {
+			scanner.addOperator(QuestionQuestion)
+		} else {
+			scanner.addOperator(Question)
+		}
 	case '"':
 		scanner.stringLiteral()
+	case '\'':
+		scanner.charLiteral()
+	case '`':
+		scanner.rawStringLiteral()
+	case byteOrderMark:
+		if scanner.base+scanner.start != 0 {
+			scanner.err(ErrUnexpected, "Unexpected byte order mark")
+			scanner.reject(scanner.line)
+			break
+		}
+		// The mark is not part of the text, so the first line still starts
+		// at column 1.
+		scanner.column = 1
 	case ' ':
-	case '\r':
 	case '\t':
-	case '\n':
-		scanner.line++
-		scanner.addToken(scanner.newToken(Newline, string(c)))
+	case '\r', '\n':
+		if c == '\r' {
+			scanner.match('\n')
+		}
+		scanner.lineBreak()
+		scanner.newLine(scanner.startColumn)
+		scanner.atLineStart = true
 	default:
 		if isDigit(c) {
 			scanner.numberLiteral()
 		} else if isAlpha(c) {
 			scanner.identifier()
+		} else if c != 0 {
+			scanner.illegal()
+		}
+	}
+}
+
+// lineBreak emits what the Newlines option makes of the line break that was
+// just scanned, if anything.
+func (scanner *Scanner) lineBreak() {
+	switch scanner.options.Newlines {
+	case KeepNewlines:
+		scanner.addToken(scanner.newToken(Newline, scanner.lexeme()))
+	case CollapseNewlines:
+		if scanner.last.Type != Newline {
+			scanner.addToken(scanner.newToken(Newline, scanner.lexeme()))
+		}
+	case InsertSemicolons:
+		if endsStatement(scanner.last.Type) {
+			scanner.addToken(scanner.newToken(SemiColon, scanner.lexeme()))
+		}
+	}
+}
+
+// endsStatement reports whether a line ending with a token of type typ
+// gets a SemiColon under InsertSemicolons.
+func endsStatement(typ Type) bool {
+	switch typ {
+	case Identifier, Number, String, StringEnd, RawString, Char, True, False,
+		Return, RightParen, RightBracket, RightCurly, PlusPlus, MinusMinus:
+		return true
+	default:
+		return false
+	}
+}
+
+// indentation measures the leading whitespace of a line and emits an Indent
+// when it is wider than the enclosing block, or a Dedent for every block it
+// closes when it is narrower. A tab advances to the next multiple of the
+// TabWidth option.
+// Lines that are blank or hold only a line comment are ignored.
+func (scanner *Scanner) indentation() {
+	width := 0
+	tab := max(scanner.options.TabWidth, 1)
+	for scanner.peek() == ' ' || scanner.peek() == '\t' {
+		if scanner.advance() == '\t' {
+			width += tab - width%tab
 		} else {
-			if c != 0 {
-				scanner.err(fmt.Sprintf("Unexpected character '%c'", c))
-			}
+			width++
 		}
 	}
+
+	if scanner.end() || isLineTerminator(scanner.peek()) || (scanner.peek() == '/' && scanner.peekNext() == '/') {
+		return
+	}
+
+	top := scanner.indents[len(scanner.indents)-1]
+	if width > top {
+		scanner.indents = append(scanner.indents, width)
+		scanner.addToken(scanner.newToken(Indent, scanner.lexeme()))
+		return
+	}
+
+	for width < scanner.indents[len(scanner.indents)-1] {
+		scanner.indents = scanner.indents[:len(scanner.indents)-1]
+		scanner.addToken(scanner.newToken(Dedent, ""))
+	}
+	if width != scanner.indents[len(scanner.indents)-1] {
+		scanner.err(ErrIndentation, "dedent does not match any outer indentation level")
+	}
 }
 
 func (scanner *Scanner) identifier() {
@@ -226,126 +946,667 @@ func (scanner *Scanner) identifier() {
 		scanner.advance()
 	}
 
-	text := scanner.lexeme()
-	typ := keywordOrIdentifier(text)
+	text := scanner.interner.Intern(scanner.lexeme())
+	typ := scanner.keywordOrIdentifier(text)
 	scanner.addToken(scanner.newToken(typ, text))
 }
 
-func (scanner *Scanner) cComment() {
+// skipLine consumes the rest of the line, leaving the line terminator.
+func (scanner *Scanner) skipLine() {
+	for !isLineTerminator(scanner.peek()) && !scanner.end() {
+		scanner.advance()
+	}
+}
+
+// cComment skips a block comment and reports whether it was terminated.
+// Block comments nest, so every "/*" inside needs its own "*/".
+func (scanner *Scanner) cComment() bool {
 	// the '/*' has already been consumed
+	line := scanner.line
+	depth := 1
 	for !scanner.end() {
 		if scanner.peek() == '*' && scanner.peekNext() == '/' {
 			scanner.advance()
 			scanner.advance()
-			return
+			if depth--; depth == 0 {
+				return true
+			}
+		} else if scanner.peek() == '/' && scanner.peekNext() == '*' {
+			scanner.advance()
+			scanner.advance()
+			depth++
+		} else {
+			scanner.countLine(scanner.advance())
 		}
-		if scanner.peek() == '\n' {
-			scanner.line++
+	}
+
+	scanner.errStarting(ErrUnterminated, "unterminated block comment", line)
+	return false
+}
+
+// comment emits the comment that was just scanned, which started on line,
+// when comments are kept. A comment written as "/// ..." or "/** ... */" is
+// a DocComment.
+func (scanner *Scanner) comment(line int) {
+	if !scanner.options.AttachTrivia && !scanner.options.KeepComments {
+		return
+	}
+	text := scanner.lexeme()
+	typ := Comment
+	if isDocComment(text) {
+		typ = DocComment
+	}
+	token := scanner.newToken(typ, text)
+	token.Line = line
+	scanner.addToken(token)
+}
+
+func isDocComment(text string) bool {
+	if strings.HasPrefix(text, "///") {
+		return !strings.HasPrefix(text, "////")
+	}
+	return strings.HasPrefix(text, "/**") && !strings.HasPrefix(text, "/***") && text != "/**/"
+}
+
+// numberLiteral scans an integer or a number with a single fractional part
+// and an optional exponent such as "1.5e-3", followed by an optional kind
+// suffix that is left out of the token text. Unless GreedyNumbers is set, a
+// '.' only belongs to the number when a digit follows it, so "1." is
+// Number("1") followed by Dot; likewise an 'e' without digits is not an
+// exponent. A second fractional part such as in "1.2.3" is reported as a
+// malformed number. A leading "0x", "0o" or "0b" starts an integer in base
+// 16, 8 or 2 instead. Digits may be grouped with single underscores, as in
+// "1_000_000", which are also left out of the token text.
+func (scanner *Scanner) numberLiteral() {
+	if base := radix(scanner.peek()); base != 0 && scanner.lexeme() == "0" {
+		scanner.advance()
+		scanner.radixLiteral(base)
+		return
+	}
+
+	scanner.digits()
+
+	kind := IntNumber
+	if scanner.peek() == '.' && (isDigit(scanner.peekNext()) || scanner.options.GreedyNumbers) {
+		scanner.advance()
+		kind = DoubleNumber
+
+		scanner.digits()
+
+		if scanner.peek() == '.' && isDigit(scanner.peekNext()) {
+			for scanner.peek() == '.' && isDigit(scanner.peekNext()) {
+				scanner.advance()
+				for isDigit(scanner.peek()) {
+					scanner.advance()
+				}
+			}
+			scanner.err(ErrMalformedNumber, fmt.Sprintf("malformed number '%s'", scanner.lexeme()))
+			scanner.reject(scanner.line)
+			return
 		}
+	}
+
+	if scanner.exponent() {
+		kind = DoubleNumber
+	}
+
+	text := scanner.lexeme()
+	if !separatorsValid(text, 10) {
+		scanner.err(ErrMalformedNumber, fmt.Sprintf("misplaced '_' in number '%s'", text))
+		scanner.reject(scanner.line)
+		return
+	}
+	text = strings.ReplaceAll(text, "_", "")
+	if suffix := scanner.peek(); numberSuffixes[suffix] != 0 && !isAlphaNumeric(scanner.peekNext()) {
 		scanner.advance()
+		if numberSuffixes[suffix] == IntNumber && kind != IntNumber {
+			scanner.err(ErrMalformedNumber, fmt.Sprintf("invalid suffix '%c' on number '%s'", suffix, text))
+			scanner.reject(scanner.line)
+			return
+		}
+		kind = numberSuffixes[suffix]
 	}
 
-	scanner.err("unterminated c-style comment")
+	token := scanner.newToken(Number, text)
+	token.Base = 10
+	token.Kind = kind
+	scanner.addToken(token)
 }
 
-func (scanner *Scanner) numberLiteral() {
-	for isDigit(scanner.peek()) {
+var numberSuffixes = map[rune]NumberKind{
+	'i': IntNumber,
+	'f': FloatNumber,
+	'd': DoubleNumber,
+}
+
+// exponent consumes an exponent such as "e10" or "E-3" and reports whether
+// there was one. An 'e' that is not followed by digits is left alone.
+func (scanner *Scanner) exponent() bool {
+	if c := scanner.peek(); c != 'e' && c != 'E' {
+		return false
+	}
+
+	current, column := scanner.current, scanner.column
+	scanner.advance()
+	if !scanner.match('+') {
+		scanner.match('-')
+	}
+	if !isDigit(scanner.peek()) {
+		scanner.current, scanner.column = current, column
+		return false
+	}
+	scanner.digits()
+	return true
+}
+
+// digits consumes a run of decimal digits and digit separators.
+func (scanner *Scanner) digits() {
+	for isDigit(scanner.peek()) || scanner.peek() == '_' {
 		scanner.advance()
 	}
+}
 
-	if scanner.peek() == '.' && isDigit(scanner.peekNext()) {
+// separatorsValid reports whether every '_' in the number text sits between
+// two digits of base, so that "1__0", "1_" and "0x_f" are rejected.
+func separatorsValid(text string, base int) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(text)-1 || !isDigitIn(rune(text[i-1]), base) || !isDigitIn(rune(text[i+1]), base) {
+			return false
+		}
+	}
+	return true
+}
+
+// radixLiteral scans the digits of a number whose base prefix has already
+// been consumed. Every letter and digit up to the end of the word belongs to
+// the literal, so "0xZZ" is reported as one malformed number.
+func (scanner *Scanner) radixLiteral(base int) {
+	for isAlphaNumeric(scanner.peek()) {
 		scanner.advance()
+	}
 
-		for isDigit(scanner.peek()) {
-			scanner.advance()
+	digits := scanner.lexeme()[2:]
+	valid := digits != ""
+	for _, c := range digits {
+		if !isDigitIn(c, base) && c != '_' {
+			valid = false
 		}
 	}
+	if !valid {
+		scanner.err(ErrMalformedNumber, fmt.Sprintf("malformed number '%s'", scanner.lexeme()))
+		scanner.reject(scanner.line)
+		return
+	}
+	if !separatorsValid(scanner.lexeme(), base) {
+		scanner.err(ErrMalformedNumber, fmt.Sprintf("misplaced '_' in number '%s'", scanner.lexeme()))
+		scanner.reject(scanner.line)
+		return
+	}
+
+	token := scanner.newToken(Number, strings.ReplaceAll(scanner.lexeme(), "_", ""))
+	token.Base = base
+	token.Kind = IntNumber
+	scanner.addToken(token)
+}
+
+// radix returns the base selected by the letter after a leading '0' of a
+// number, or 0 if c does not select one.
+func radix(c rune) int {
+	switch c {
+	case 'x', 'X':
+		return 16
+	case 'o', 'O':
+		return 8
+	case 'b', 'B':
+		return 2
+	default:
+		return 0
+	}
+}
 
-	scanner.addToken(scanner.newToken(Number, scanner.lexeme()))
+func isDigitIn(c rune, base int) bool {
+	switch {
+	case base == 16:
+		return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+	default:
+		return c >= '0' && c < '0'+rune(base)
+	}
 }
 
+const byteOrderMark = '\uFEFF'
+
 func (scanner *Scanner) stringLiteral() {
+	scanner.stringSegment(String, StringStart)
+}
+
+// stringSegment scans string contents up to the closing quote or up to the
+// next "${", which opens an interpolation whose contents are scanned as
+// ordinary tokens until the matching '}'. A '$' not followed by '{' is part
+// of the string, and "\$" keeps a "${" from opening an interpolation.
+// The segment is emitted as closed when it ends the literal, or as open
+// when it is followed by an interpolation.
+func (scanner *Scanner) stringSegment(closed Type, open Type) {
+	begin := scanner.current
+	line := scanner.line
+	broken := false
 	for scanner.peek() != '"' && !scanner.end() {
-		if scanner.peek() == '\n' {
-			scanner.err("unterminated string")
+		if isLineTerminator(scanner.peek()) {
+			broken = true
 		}
-		scanner.advance()
+		if scanner.peek() == '\\' && scanner.peekNext() == '$' {
+			scanner.advance()
+		} else if scanner.peek() == '$' && scanner.peekNext() == '{' {
+			literal := scanner.source[begin:scanner.current]
+			scanner.advance()
+			scanner.advance()
+			if broken {
+				scanner.errStarting(ErrLineBreakInString, "line break in string", line)
+			}
+			scanner.addStringToken(open, literal, line)
+			scanner.interpolations = append(scanner.interpolations, 0)
+			return
+		}
+		scanner.countLine(scanner.advance())
 	}
 
 	if scanner.end() {
-		scanner.err("unterminated string")
+		scanner.errStarting(ErrUnterminated, "unterminated string", line)
+		scanner.reject(line)
+		return
+	}
+	if broken {
+		scanner.errStarting(ErrLineBreakInString, "line break in string", line)
+	}
+
+	literal := scanner.source[begin:scanner.current]
+	scanner.advance()
+	scanner.addStringToken(closed, literal, line)
+}
+
+func (scanner *Scanner) addStringToken(typ Type, literal string, line int) {
+	token := scanner.newToken(typ, literal)
+	token.Line = line
+	scanner.addToken(token)
+}
+
+// eofPosition is the position of the EOF token: the end of the last line of
+// the source, where a trailing line break ends that line rather than
+// starting a new one. Both "a" and "a\n" put EOF on line 1, and an empty
+// source puts it on line 1.
+func (scanner *Scanner) eofPosition() (int, int) {
+	if scanner.line > 1 && !scanner.missingFinalNewline() {
+		return scanner.line - 1, scanner.terminatorColumn
+	}
+	return scanner.line, scanner.column
+}
+
+func (scanner *Scanner) missingFinalNewline() bool {
+	return len(scanner.source) > 0 && !isLineTerminator(rune(scanner.source[len(scanner.source)-1]))
+}
+
+func (scanner *Scanner) tooManyErrors() bool {
+	return scanner.options.MaxErrors > 0 && len(scanner.errors) >= scanner.options.MaxErrors
+}
+
+// rawStringLiteral scans a backtick-quoted string. Everything up to the
+// closing backtick is taken verbatim, including backslashes and line breaks.
+// The token is reported on the line where the literal starts.
+func (scanner *Scanner) rawStringLiteral() {
+	line := scanner.line
+	for scanner.peek() != '`' && !scanner.end() {
+		scanner.countLine(scanner.advance())
+	}
+
+	if scanner.end() {
+		scanner.errStarting(ErrUnterminated, "unterminated raw string", line)
+		scanner.reject(line)
 		return
 	}
 
 	scanner.advance()
 
 	literal := scanner.source[scanner.start+1 : scanner.current-1]
-	scanner.addToken(scanner.newToken(String, literal))
+	scanner.addStringToken(RawString, literal, line)
+}
+
+// charLiteral scans a character literal such as 'a', '\n' or '\u{1F600}'.
+// The token text is the character itself, with any escape resolved.
+func (scanner *Scanner) charLiteral() {
+	var chars []rune
+	valid := true
+	for scanner.peek() != '\'' && !scanner.end() && !isLineTerminator(scanner.peek()) {
+		c := scanner.advance()
+		if c == '\\' {
+			var ok bool
+			c, ok = scanner.escape()
+			valid = valid && ok
+		}
+		chars = append(chars, c)
+	}
+
+	if !scanner.match('\'') {
+		scanner.err(ErrUnterminated, "unterminated character literal")
+		scanner.reject(scanner.line)
+		return
+	}
+	if !valid {
+		scanner.reject(scanner.line)
+		return
+	}
+	switch len(chars) {
+	case 0:
+		scanner.err(ErrMalformedChar, "empty character literal")
+		scanner.reject(scanner.line)
+	case 1:
+		scanner.addToken(scanner.newToken(Char, string(chars[0])))
+	default:
+		scanner.err(ErrMalformedChar, fmt.Sprintf("character literal %s has more than one character", scanner.lexeme()))
+		scanner.reject(scanner.line)
+	}
+}
+
+// escape resolves the escape sequence after a '\' in a character literal.
+// It reports false after an invalid escape, which has then been reported
+// unless the literal is cut short by the end of the line.
+func (scanner *Scanner) escape() (rune, bool) {
+	if scanner.end() || isLineTerminator(scanner.peek()) {
+		return 0, false
+	}
+
+	switch c := scanner.advance(); c {
+	case 'n':
+		return '\n', true
+	case 't':
+		return '\t', true
+	case 'r':
+		return '\r', true
+	case '0':
+		return 0, true
+	case '\\', '\'', '"':
+		return c, true
+	case 'u':
+		return scanner.unicodeEscape()
+	default:
+		scanner.err(ErrMalformedChar, fmt.Sprintf("invalid escape '\\%c' in character literal", c))
+		return 0, false
+	}
+}
+
+// unicodeEscape resolves the "{1F600}" part of a "\u{1F600}" escape: one to
+// six hexadecimal digits naming a valid code point.
+func (scanner *Scanner) unicodeEscape() (rune, bool) {
+	if !scanner.match('{') {
+		scanner.err(ErrMalformedChar, "invalid unicode escape in character literal")
+		return 0, false
+	}
+
+	begin := scanner.current
+	for isDigitIn(scanner.peek(), 16) {
+		scanner.advance()
+	}
+	digits := scanner.source[begin:scanner.current]
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if !scanner.match('}') || err != nil || len(digits) > 6 || !utf8.ValidRune(rune(value)) {
+		scanner.err(ErrMalformedChar, "invalid unicode escape in character literal")
+		return 0, false
+	}
+	return rune(value), true
+}
+
+// illegal scans a run of characters that cannot start a token, the first of
+// which has already been consumed, into a single Illegal token. The whole run
+// gets one error, so that corrupted input does not bury the real errors, and
+// scanning resumes with the first character that can start a token.
+func (scanner *Scanner) illegal() {
+	for !scanner.end() && !canStartToken(scanner.peek()) {
+		scanner.advance()
+	}
+
+	text := scanner.lexeme()
+	switch {
+	case !utf8.ValidString(text):
+		scanner.err(ErrInvalidEncoding, "invalid UTF-8 encoding")
+	case utf8.RuneCountInString(text) == 1:
+		scanner.err(ErrUnexpected, fmt.Sprintf("Unexpected character '%s'", text))
+	default:
+		scanner.err(ErrUnexpected, fmt.Sprintf("Unexpected characters '%s'", text))
+	}
+	scanner.reject(scanner.line)
+}
+
+// reject emits the text scanned since the start of the token, which started
+// on line and has just been reported as an error, as an Illegal token. That
+// way parsers still see something at every position of the source.
+func (scanner *Scanner) reject(line int) {
+	token := scanner.newToken(Illegal, scanner.lexeme())
+	token.Line = line
+	scanner.addToken(token)
+}
+
+// canStartToken reports whether c can begin a token, or the whitespace
+// between tokens. A '#' only starts the shebang at the top of the source and
+// is treated as illegal anywhere else.
+func canStartToken(c rune) bool {
+	if c < utf8.RuneSelf {
+		return startsToken[c]
+	}
+	return isAlpha(c)
 }
 
-func (scanner *Scanner) err(msg string) {
-	scanner.errors = append(scanner.errors, fmt.Sprintf("%s on line %d", msg, scanner.line))
+// synchronize skips the rest of a malformed word after an error, up to the
+// next whitespace or line break, so that one bad character does not cascade
+// into errors for its neighbours. Line breaks are left for scanToken.
+func (scanner *Scanner) synchronize() {
+	for !scanner.end() && !isWhitespace(scanner.peek()) {
+		scanner.advance()
+	}
+}
+
+// countLine bumps the line counter if c, having just been consumed, ends a
+// line. A '\r' directly followed by '\n' is left for the '\n' to count.
+func (scanner *Scanner) countLine(c rune) {
+	switch {
+	case c == '\n' && scanner.current >= 2 && scanner.source[scanner.current-2] == '\r':
+		scanner.newLine(scanner.column - 2)
+	case c == '\n' || (c == '\r' && scanner.peek() != '\n'):
+		scanner.newLine(scanner.column - 1)
+	}
+}
+
+// newLine moves the position to the start of the next line once its line
+// terminator, which started at column, has been consumed.
+func (scanner *Scanner) newLine(column int) {
+	scanner.terminatorColumn = column
+	scanner.line++
+	scanner.column = 1
+}
+
+// errStarting reports an error about the token being scanned, which opened
+// on line and may end far from where it started.
+func (scanner *Scanner) errStarting(code ErrorCode, msg string, line int) {
+	scanner.err(code, msg)
+	e := &scanner.errors[len(scanner.errors)-1]
+	e.Line = line
+	e.Starting = true
+}
+
+func (scanner *Scanner) err(code ErrorCode, msg string) {
+	scanner.errors = append(scanner.errors, Error{
+		Code:        code,
+		Message:     msg,
+		Line:        scanner.line,
+		Column:      scanner.startColumn,
+		StartOffset: scanner.base + scanner.start,
+		EndOffset:   scanner.base + scanner.current,
+		Pos:         scanner.pos(),
+	})
 }
 
-func isDigit(c byte) bool {
+// isDigit only accepts ASCII digits, which are the only digits numbers can
+// be written with.
+func isDigit(c rune) bool {
 	return c >= '0' && c <= '9'
 }
 
-func isAlpha(c byte) bool {
+func isAlpha(c rune) bool {
 	return (c >= 'a' && c <= 'z') ||
 		(c >= 'A' && c <= 'Z') ||
-		c == '_'
+		c == '_' ||
+		(c >= utf8.RuneSelf && unicode.IsLetter(c))
 }
 
-func isAlphaNumeric(c byte) bool {
-	return isAlpha(c) || isDigit(c)
+func isWhitespace(c rune) bool {
+	return c == ' ' || c == '\t' || isLineTerminator(c)
 }
 
-func (scanner *Scanner) match(c byte) bool {
+func isLineTerminator(c rune) bool {
+	return c == '\n' || c == '\r'
+}
+
+// isAlphaNumeric accepts the characters that may continue an identifier:
+// letters, '_' and decimal digits of any script.
+func isAlphaNumeric(c rune) bool {
+	return isAlpha(c) || isDigit(c) || (c >= utf8.RuneSelf && unicode.IsDigit(c))
+}
+
+func (scanner *Scanner) match(c rune) bool {
 	if scanner.end() {
 		return false
 	}
 
-	if scanner.source[scanner.current] != c {
+	if scanner.peek() != c {
 		return false
 	}
 
-	scanner.current++
+	scanner.advance()
 	return true
 }
 
-func (scanner *Scanner) peek() byte {
+func (scanner *Scanner) peek() rune {
 	if scanner.end() {
 		return 0
 	}
-	return scanner.source[scanner.current]
+	c, _ := scanner.decode(scanner.current)
+	return c
 }
 
-func (scanner *Scanner) peekNext() byte {
-	if scanner.current+1 >= len(scanner.source) {
+func (scanner *Scanner) peekNext() rune {
+	if scanner.end() {
 		return 0
 	}
-	return scanner.source[scanner.current+1]
+	_, width := scanner.decode(scanner.current)
+	if !scanner.available(scanner.current + width) {
+		return 0
+	}
+	c, _ := scanner.decode(scanner.current + width)
+	return c
+}
+
+// decode returns the rune starting at offset and its width in bytes. ASCII
+// is by far the most common case and skips the UTF-8 decoder.
+func (scanner *Scanner) decode(offset int) (rune, int) {
+	if c := scanner.source[offset]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	scanner.available(offset + utf8.UTFMax - 1)
+	return utf8.DecodeRuneInString(scanner.source[offset:])
 }
 
-func (scanner *Scanner) advance() byte {
+func (scanner *Scanner) advance() rune {
 	if scanner.end() {
 		return 0
 	}
-	scanner.current++
-	return scanner.source[scanner.current-1]
+	c, width := scanner.decode(scanner.current)
+	scanner.current += width
+	if width := scanner.options.TabWidth; c == '\t' && width > 1 {
+		scanner.column += width - (scanner.column-1)%width
+	} else {
+		scanner.column++
+	}
+	return c
 }
 
 func (scanner *Scanner) addToken(token Token) {
+	if scanner.options.AttachTrivia && scanner.attachTrivia(token) {
+		return
+	}
 	scanner.tokens = append(scanner.tokens, token)
+	if token.Type != Comment && token.Type != DocComment {
+		scanner.last = token
+	}
+}
+
+// attachTrivia files comments and blank lines under the token they belong
+// to instead of the token stream, and hands collected trivia to the next
+// real token. A comment on the same line as the previous token trails it;
+// any other comment leads the next token. Only the first Newline after a
+// real token stays in the stream. It returns whether token was absorbed.
+func (scanner *Scanner) attachTrivia(token Token) bool {
+	switch token.Type {
+	case Comment, DocComment:
+		// The previous token is still in the stream, as Peek holds it back
+		// until the token after it is scanned.
+		if last := len(scanner.tokens) - 1; last >= 0 && scanner.last.Type != Newline && scanner.last.Line == token.Line {
+			scanner.tokens[last].TrailingTrivia = append(scanner.tokens[last].TrailingTrivia, token)
+		} else {
+			scanner.trivia = append(scanner.trivia, token)
+		}
+		return true
+	case Newline:
+		if scanner.last.Type == Newline {
+			scanner.trivia = append(scanner.trivia, token)
+			return true
+		}
+		return false
+	default:
+		if len(scanner.trivia) > 0 {
+			token.LeadingTrivia = scanner.trivia
+			scanner.trivia = nil
+		}
+		scanner.tokens = append(scanner.tokens, token)
+		scanner.last = token
+		return true
+	}
+}
+
+// addOperator adds an operator token of type typ. Its text is the fixed
+// spelling of the operator rather than a slice of the source, so that it does
+// not keep a window of a reader's source alive.
+func (scanner *Scanner) addOperator(typ Type) {
+	scanner.addToken(scanner.newToken(typ, spellings[typ]))
 }
 
 func (scanner *Scanner) newToken(tokenType Type, text string) Token {
 	return Token{
-		Type: tokenType,
-		Text: text,
-		Line: scanner.line,
+		Type:        tokenType,
+		Text:        text,
+		Line:        scanner.line,
+		Column:      scanner.startColumn,
+		StartOffset: scanner.base + scanner.start,
+		EndOffset:   scanner.base + scanner.current,
+		Pos:         scanner.pos(),
+	}
+}
+
+// pos returns the position of the start of the token being scanned in the
+// scanner's File.
+func (scanner *Scanner) pos() Pos {
+	if scanner.options.File == nil {
+		return NoPos
+	}
+	return scanner.options.File.Pos(scanner.base + scanner.start)
+}
+
+// begin marks the current position as the start of the next token.
+func (scanner *Scanner) begin() {
+	if scanner.reader != nil {
+		scanner.discard()
 	}
+	scanner.start = scanner.current
+	scanner.startColumn = scanner.column
 }
 
 func (scanner *Scanner) lexeme() string {
@@ -353,5 +1614,5 @@ func (scanner *Scanner) lexeme() string {
 }
 
 func (scanner *Scanner) end() bool {
-	return scanner.current >= len(scanner.source)
+	return !scanner.available(scanner.current)
 }
@@ -0,0 +1,1641 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"unsafe"
+)
+
+// scanWith scans source with options and fails the test on scan errors.
+func scanWith(t *testing.T, source string, options ScannerOptions) []Token {
+	t.Helper()
+	scanner := NewScannerWithOptions(source, options)
+	tokens, errors := scanner.Scan()
+	if len(errors) > 0 {
+		t.Fatalf("scan %q: %v", source, errors)
+	}
+	return tokens
+}
+
+// describe returns the type and text of each token, as in `Number "1"`.
+func describe(tokens []Token) []string {
+	list := make([]string, len(tokens))
+	for i, token := range tokens {
+		list[i] = token.String()
+	}
+	return list
+}
+
+// messages returns the Error text of each error.
+func messages(errors []Error) []string {
+	list := make([]string, len(errors))
+	for i, err := range errors {
+		list[i] = err.Error()
+	}
+	return list
+}
+
+func TestGreedyNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		greedy bool
+		want   []string
+	}{
+		{"1.", false, []string{`Number "1"`, `Dot "."`, `EOF ""`}},
+		{"1.", true, []string{`Number "1."`, `EOF ""`}},
+		{"1.foo", false, []string{`Number "1"`, `Dot "."`, `Identifier "foo"`, `EOF ""`}},
+		{"1.foo", true, []string{`Number "1."`, `Identifier "foo"`, `EOF ""`}},
+		{"1.5", false, []string{`Number "1.5"`, `EOF ""`}},
+		{"1.5", true, []string{`Number "1.5"`, `EOF ""`}},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.GreedyNumbers = test.greedy
+		got := describe(scanWith(t, test.source, options))
+		if !slices.Equal(got, test.want) {
+			t.Errorf("scan %q with GreedyNumbers %t = %q, want %q", test.source, test.greedy, got, test.want)
+		}
+	}
+}
+
+func TestKeywords(t *testing.T) {
+	words := Keywords()
+	if !slices.Contains(words, "let") {
+		t.Errorf("Keywords() = %q, want it to contain \"let\"", words)
+	}
+	if !slices.IsSorted(words) {
+		t.Errorf("Keywords() = %q, want them sorted", words)
+	}
+	for _, word := range words {
+		tokens := scanWith(t, word, DefaultOptions())
+		if len(tokens) != 2 || tokens[0].Type == Identifier || tokens[0].Text != word {
+			t.Errorf("scan %q = %q, want one keyword token", word, describe(tokens))
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	ops := Operators()
+	if ops["=="] != Equals {
+		t.Errorf("Operators()[\"==\"] = %s, want Equals", ops["=="])
+	}
+	for text, typ := range ops {
+		tokens := scanWith(t, text, DefaultOptions())
+		if len(tokens) != 2 || tokens[0].Type != typ {
+			t.Errorf("scan %q = %q, want one %s token", text, describe(tokens), typ)
+		}
+	}
+	delete(ops, "==")
+	if Operators()["=="] != Equals {
+		t.Errorf("changing the map returned by Operators changed the scanner's table")
+	}
+}
+
+func TestSuppressNewlines(t *testing.T) {
+	source := "let a = 1\n\nlet b = `x\ny`\n/* c\n*/ b\r\nc"
+	options := DefaultOptions()
+	options.Newlines = SuppressNewlines
+	tokens := scanWith(t, source, options)
+	keep := scanWith(t, source, DefaultOptions())
+	keep = slices.DeleteFunc(keep, func(token Token) bool { return token.Type == Newline })
+	if len(tokens) != len(keep) {
+		t.Fatalf("got %q, want %q", describe(tokens), describe(keep))
+	}
+	for i, token := range tokens {
+		if token.Type == Newline {
+			t.Errorf("token %d is a Newline", i)
+		}
+		if token.Type != keep[i].Type || token.Line != keep[i].Line || token.Column != keep[i].Column {
+			t.Errorf("token %d = %s on line %d, column %d, want %s on line %d, column %d", i,
+				token, token.Line, token.Column, keep[i], keep[i].Line, keep[i].Column)
+		}
+	}
+	lines := make([]int, len(tokens))
+	for i, token := range tokens {
+		lines[i] = token.Line
+	}
+	want := []int{1, 1, 1, 1, 3, 3, 3, 3, 6, 7, 7}
+	if !slices.Equal(lines, want) {
+		t.Errorf("lines = %v, want %v", lines, want)
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		tokens []string
+		errors []string
+	}{
+		{"1.2.3", []string{`Illegal "1.2.3"`, `EOF ""`}, []string{"malformed number '1.2.3' on line 1"}},
+		{"\nx = 1.2.3.4 + 1", []string{`Newline "\n"`, `Identifier "x"`, `Assign "="`, `Illegal "1.2.3.4"`, `Plus "+"`, `Number "1"`, `EOF ""`},
+			[]string{"malformed number '1.2.3.4' on line 2"}},
+		// A '.' not followed by a digit is not part of the number.
+		{"1.", []string{`Number "1"`, `Dot "."`, `EOF ""`}, nil},
+		{"1.2.", []string{`Number "1.2"`, `Dot "."`, `EOF ""`}, nil},
+		{"1.2.x", []string{`Number "1.2"`, `Dot "."`, `Identifier "x"`, `EOF ""`}, nil},
+	}
+	for _, test := range tests {
+		result := Scan(test.source)
+		if got := describe(result.Tokens); !slices.Equal(got, test.tokens) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.tokens)
+		}
+		if errors := messages(result.Errors); !slices.Equal(errors, test.errors) {
+			t.Errorf("scan %q errors = %q, want %q", test.source, errors, test.errors)
+		}
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	source := "let a = 1\n" + strings.Repeat("@ ", 1000) + "\nlet b = 2\n"
+	options := DefaultOptions()
+	options.MaxErrors = 10
+	scanner := NewScannerWithOptions(source, options)
+	tokens, errors := scanner.Scan()
+	if len(errors) != options.MaxErrors+1 {
+		t.Fatalf("got %d errors, want %d", len(errors), options.MaxErrors+1)
+	}
+	if last := errors[len(errors)-1]; last.Code != ErrTooManyErrors || last.Error() != "too many errors, aborting on line 2" {
+		t.Errorf("last error = %v, want too many errors", last)
+	}
+	eof := tokens[len(tokens)-1]
+	if tokens[len(tokens)-2].Type != Illegal || eof.Type != EOF {
+		t.Fatalf("tokens end with %q, want the last Illegal and EOF", describe(tokens[len(tokens)-2:]))
+	}
+	// EOF sits right after the token that made the last error.
+	if eof.Line != 2 || eof.Column != 20 || eof.StartOffset != 29 || eof.EndOffset != 29 {
+		t.Errorf("EOF at %d:%d [%d, %d), want 2:20 [29, 29)", eof.Line, eof.Column, eof.StartOffset, eof.EndOffset)
+	}
+
+	reader := NewScannerFromReaderWithOptions(iotest.OneByteReader(strings.NewReader(source)), options)
+	readTokens, readErrors := reader.Scan()
+	if !reflect.DeepEqual(readTokens, tokens) {
+		last := readTokens[len(readTokens)-1]
+		t.Errorf("reader tokens end with %s at %d:%d [%d, %d), want them to match the string scanner", last, last.Line, last.Column, last.StartOffset, last.EndOffset)
+	}
+	if !reflect.DeepEqual(readErrors, errors) {
+		t.Errorf("reader errors = %v, want %v", readErrors, errors)
+	}
+}
+
+func TestMaxErrorsUnlimited(t *testing.T) {
+	options := DefaultOptions()
+	options.MaxErrors = 0
+	scanner := NewScannerWithOptions(strings.Repeat("@ ", 1000), options)
+	if _, errors := scanner.Scan(); len(errors) != 1000 {
+		t.Errorf("got %d errors, want 1000", len(errors))
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		source string
+		tokens []string
+		errors []string
+	}{
+		{`"plain"`, []string{`String "plain"`, `EOF ""`}, nil},
+		{`"cost $5 and \${x}"`, []string{`String "cost $5 and \\${x}"`, `EOF ""`}, nil},
+		{`"hello ${name}, you have ${count + 1} messages"`, []string{
+			`StringStart "hello "`, `Identifier "name"`,
+			`StringMid ", you have "`, `Identifier "count"`, `Plus "+"`, `Number "1"`,
+			`StringEnd " messages"`, `EOF ""`,
+		}, nil},
+		{`"${ {"k": 1} }"`, []string{
+			`StringStart ""`, `LeftCurly "{"`, `String "k"`, `Colon ":"`, `Number "1"`, `RightCurly "}"`,
+			`StringEnd ""`, `EOF ""`,
+		}, nil},
+		{`"a ${"b ${c} d"} e"`, []string{
+			`StringStart "a "`, `StringStart "b "`, `Identifier "c"`, `StringEnd " d"`,
+			`StringEnd " e"`, `EOF ""`,
+		}, nil},
+		{`"a ${b`, []string{`StringStart "a "`, `Identifier "b"`, `EOF ""`},
+			[]string{"unterminated string interpolation on line 1"}},
+	}
+	for _, test := range tests {
+		result := Scan(test.source)
+		if got := describe(result.Tokens); !slices.Equal(got, test.tokens) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.tokens)
+		}
+		if errors := messages(result.Errors); !slices.Equal(errors, test.errors) {
+			t.Errorf("scan %q errors = %q, want %q", test.source, errors, test.errors)
+		}
+	}
+}
+
+func TestUnterminatedStartLine(t *testing.T) {
+	body := strings.Repeat("let a = b + c\n", 200)
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"let s = \"never closed\n" + body, "unterminated string starting on line 1"},
+		{"x\n\nlet s = \"a ${b} c\n" + body, "unterminated string starting on line 3"},
+		{"x\n/* never closed\n" + body, "unterminated block comment starting on line 2"},
+		{"x\n/* /* nested */\n" + body, "unterminated block comment starting on line 2"},
+		{"x\nlet s = `never closed\n" + body, "unterminated raw string starting on line 2"},
+	}
+	for _, test := range tests {
+		result := Scan(test.source)
+		if errors := messages(result.Errors); len(errors) == 0 || errors[0] != test.want {
+			t.Errorf("scan %.20q... errors = %q, want %q first", test.source, errors, test.want)
+		}
+	}
+}
+
+// types returns the type of each token.
+func types(tokens []Token) []Type {
+	list := make([]Type, len(tokens))
+	for i, token := range tokens {
+		list[i] = token.Type
+	}
+	return list
+}
+
+func TestIndentation(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []Type
+		errors []string
+	}{
+		{"a\n  b\n    c\n  d\ne\n", []Type{
+			Identifier, Newline,
+			Indent, Identifier, Newline,
+			Indent, Identifier, Newline,
+			Dedent, Identifier, Newline,
+			Dedent, Identifier, Newline, EOF,
+		}, nil},
+		// A dedent to an earlier level closes every block in between, and
+		// the blocks still open at the end are closed before EOF.
+		{"a\n  b\n    c\nd\n  e", []Type{
+			Identifier, Newline,
+			Indent, Identifier, Newline,
+			Indent, Identifier, Newline,
+			Dedent, Dedent, Identifier, Newline,
+			Indent, Identifier, Dedent, EOF,
+		}, nil},
+		// Blank lines and lines holding only a comment leave the level alone.
+		{"a\n  b\n\n// note\n  c", []Type{
+			Identifier, Newline,
+			Indent, Identifier, Newline, Newline, Newline,
+			Identifier, Dedent, EOF,
+		}, nil},
+		{"a\n    b\n  c", []Type{
+			Identifier, Newline,
+			Indent, Identifier, Newline,
+			Dedent, Identifier, EOF,
+		}, []string{"dedent does not match any outer indentation level on line 3"}},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.Indentation = true
+		scanner := NewScannerWithOptions(test.source, options)
+		tokens, errors := scanner.Scan()
+		if got := types(tokens); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q = %v, want %v", test.source, got, test.want)
+		}
+		if got := messages(errors); !slices.Equal(got, test.errors) {
+			t.Errorf("scan %q errors = %q, want %q", test.source, got, test.errors)
+		}
+	}
+}
+
+func TestPeekConsume(t *testing.T) {
+	scanner := NewScanner("a + b")
+	for n, want := range []Type{Identifier, Plus, Identifier, EOF, EOF, EOF} {
+		if got := scanner.Peek(n).Type; got != want {
+			t.Errorf("Peek(%d) = %s, want %s", n, got, want)
+		}
+	}
+	if got := scanner.Peek(1).Text; got != "+" {
+		t.Errorf("Peek(1) again = %q, want \"+\"", got)
+	}
+	for i, want := range []string{"a", "+", "b", "", ""} {
+		token := scanner.Consume()
+		if token.Text != want {
+			t.Errorf("Consume %d = %s, want %q", i, token, want)
+		}
+		if next := scanner.Peek(0); i < 2 && next.StartOffset <= token.StartOffset {
+			t.Errorf("Peek(0) after Consume %d = %s, want the token after %s", i, next, token)
+		}
+	}
+	if got := scanner.Consume().Type; got != EOF {
+		t.Errorf("Consume after EOF = %s, want EOF", got)
+	}
+}
+
+func TestIndentationTabWidth(t *testing.T) {
+	source := "a\n\tb\n    c\n\t  d\n"
+	tests := []struct {
+		width  int
+		want   []Type
+		errors []string
+	}{
+		// A tab lines up with four spaces.
+		{4, []Type{
+			Identifier, Newline,
+			Indent, Identifier, Newline,
+			Identifier, Newline,
+			Indent, Identifier, Newline,
+			Dedent, Dedent, EOF,
+		}, nil},
+		// A tab is one column, narrower than the two spaces after it.
+		{1, []Type{
+			Identifier, Newline,
+			Indent, Identifier, Newline,
+			Indent, Identifier, Newline,
+			Dedent, Identifier, Newline,
+			Dedent, EOF,
+		}, []string{"dedent does not match any outer indentation level on line 4"}},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.Indentation = true
+		options.TabWidth = test.width
+		scanner := NewScannerWithOptions(source, options)
+		tokens, errors := scanner.Scan()
+		if got := types(tokens); !slices.Equal(got, test.want) {
+			t.Errorf("TabWidth %d: scan %q = %v, want %v", test.width, source, got, test.want)
+		}
+		if got := messages(errors); !slices.Equal(got, test.errors) {
+			t.Errorf("TabWidth %d: scan %q errors = %q, want %q", test.width, source, got, test.errors)
+		}
+	}
+}
+
+func TestTabWidthColumns(t *testing.T) {
+	tests := []struct {
+		source string
+		width  int
+		want   int
+	}{
+		{"\tx", 1, 2},
+		{"\tx", 4, 5},
+		{"\tx", 8, 9},
+		{"\t\tx", 4, 9},
+		{"\t\tx", 8, 17},
+		// A tab after other characters advances to the next tab stop.
+		{"  \tx", 4, 5},
+		{"    \tx", 4, 9},
+		{" \t x", 8, 10},
+		// Zero and negative widths count a tab as one column.
+		{"\tx", 0, 2},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.TabWidth = test.width
+		tokens := scanWith(t, test.source, options)
+		if got := tokens[0].Column; got != test.want {
+			t.Errorf("TabWidth %d: column of x in %q = %d, want %d", test.width, test.source, got, test.want)
+		}
+	}
+	if got := DefaultOptions().TabWidth; got != 1 {
+		t.Errorf("default TabWidth = %d, want 1", got)
+	}
+}
+
+func TestScanRange(t *testing.T) {
+	source := "let a = 1\nlet b = \"two\"\n\tprint(a + b)\nlet c = 3\n"
+	scanner := NewScanner(source)
+	all, _ := scanner.Scan()
+	start := strings.Index(source, "let b")
+	end := strings.Index(source, "let c")
+
+	var want []Token
+	for _, token := range all {
+		if token.StartOffset >= start && token.EndOffset <= end {
+			want = append(want, token)
+		}
+	}
+	tokens, errors := scanner.ScanRange(start, end)
+	if len(errors) > 0 {
+		t.Fatalf("ScanRange errors: %q", messages(errors))
+	}
+	if eof := tokens[len(tokens)-1]; eof.Type != EOF || eof.StartOffset != end {
+		t.Errorf("ScanRange ends with %s at %d, want EOF at %d", eof, eof.StartOffset, end)
+	}
+	if !reflect.DeepEqual(tokens[:len(tokens)-1], want) {
+		t.Errorf("ScanRange(%d, %d) = %q, want %q", start, end, describe(tokens), describe(want))
+	}
+	for i, token := range want {
+		if got := tokens[i]; got.Line != token.Line || got.Column != token.Column {
+			t.Errorf("token %s at %d:%d, want %d:%d", got, got.Line, got.Column, token.Line, token.Column)
+		}
+	}
+
+	// A token crossing end is cut short there.
+	cut := strings.Index(source, "two") + 1
+	tokens, errors = scanner.ScanRange(start, cut)
+	if got := describe(tokens); !slices.Equal(got, []string{`Let "let"`, `Identifier "b"`, `Assign "="`, `Illegal "\"t"`, `EOF ""`}) {
+		t.Errorf("ScanRange(%d, %d) = %q", start, cut, got)
+	}
+	if len(errors) != 1 || errors[0].Code != ErrUnterminated || errors[0].Line != 2 {
+		t.Errorf("ScanRange(%d, %d) errors = %q, want an unterminated string on line 2", start, cut, messages(errors))
+	}
+}
+
+func TestScanRangeBounds(t *testing.T) {
+	source := "a b\nc"
+	scanner := NewScanner(source)
+	tests := []struct {
+		start, end int
+		want       []string
+	}{
+		{-5, 3, []string{`Identifier "a"`, `Identifier "b"`, `EOF ""`}},
+		{2, 100, []string{`Identifier "b"`, `Newline "\n"`, `Identifier "c"`, `EOF ""`}},
+		{4, 2, []string{`EOF ""`}},
+		{100, 200, []string{`EOF ""`}},
+		{-3, -1, []string{`EOF ""`}},
+	}
+	for _, test := range tests {
+		tokens, errors := scanner.ScanRange(test.start, test.end)
+		if got := describe(tokens); !slices.Equal(got, test.want) || len(errors) > 0 {
+			t.Errorf("ScanRange(%d, %d) = %q, %q, want %q", test.start, test.end, got, messages(errors), test.want)
+		}
+	}
+}
+
+func TestEqualsRuns(t *testing.T) {
+	tests := []struct {
+		source string
+		tokens []string
+		errors []string
+	}{
+		{"a == b", []string{`Identifier "a"`, `Equals "=="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a = b", []string{`Identifier "a"`, `Assign "="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a => b", []string{`Identifier "a"`, `FatArrow "=>"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a==b==c", []string{`Identifier "a"`, `Equals "=="`, `Identifier "b"`, `Equals "=="`, `Identifier "c"`, `EOF ""`}, nil},
+		{"a===b", []string{`Identifier "a"`, `Illegal "==="`, `Identifier "b"`, `EOF ""`}, []string{"unexpected '===' on line 1"}},
+		{"a\n==== b", []string{`Identifier "a"`, `Newline "\n"`, `Illegal "===="`, `Identifier "b"`, `EOF ""`}, []string{"unexpected '====' on line 2"}},
+	}
+	for _, test := range tests {
+		result := Scan(test.source)
+		if got := describe(result.Tokens); !slices.Equal(got, test.tokens) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.tokens)
+		}
+		if errors := messages(result.Errors); !slices.Equal(errors, test.errors) {
+			t.Errorf("scan %q errors = %q, want %q", test.source, errors, test.errors)
+		}
+	}
+}
+
+func TestPipes(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []Type
+	}{
+		{"data |> filter |> map", []Type{Identifier, PipeForward, Identifier, PipeForward, Identifier, EOF}},
+		{"a || b", []Type{Identifier, Or, Identifier, EOF}},
+		{"a | b", []Type{Identifier, Pipe, Identifier, EOF}},
+		{"a ||> b", []Type{Identifier, Or, RightAngle, Identifier, EOF}},
+		{"a |>> b", []Type{Identifier, PipeForward, RightAngle, Identifier, EOF}},
+		{"a | > b", []Type{Identifier, Pipe, RightAngle, Identifier, EOF}},
+		{"a |||b", []Type{Identifier, Or, Pipe, Identifier, EOF}},
+	}
+	for _, test := range tests {
+		if got := types(scanWith(t, test.source, DefaultOptions())); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+// scanTest is a source with the tokens and errors it scans to.
+type scanTest struct {
+	source string
+	tokens []string
+	errors []string
+}
+
+func testScans(t *testing.T, tests []scanTest) {
+	t.Helper()
+	for _, test := range tests {
+		result := Scan(test.source)
+		if got := describe(result.Tokens); !slices.Equal(got, test.tokens) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.tokens)
+		}
+		if errors := messages(result.Errors); !slices.Equal(errors, test.errors) {
+			t.Errorf("scan %q errors = %q, want %q", test.source, errors, test.errors)
+		}
+	}
+}
+
+func TestCharLiterals(t *testing.T) {
+	testScans(t, []scanTest{
+		{"'a'", []string{`Char "a"`, `EOF ""`}, nil},
+		{"'é' '😀'", []string{`Char "é"`, `Char "😀"`, `EOF ""`}, nil},
+		{`'\n' '\t' '\'' '"' '\\'`, []string{`Char "\n"`, `Char "\t"`, `Char "'"`, `Char "\""`, `Char "\\"`, `EOF ""`}, nil},
+		{`'\u{1F600}'`, []string{`Char "😀"`, `EOF ""`}, nil},
+		{"''", []string{`Illegal "''"`, `EOF ""`}, []string{"empty character literal on line 1"}},
+		{"'ab'", []string{`Illegal "'ab'"`, `EOF ""`}, []string{"character literal 'ab' has more than one character on line 1"}},
+		{`'\q'`, []string{`Illegal "'\\q'"`, `EOF ""`}, []string{`invalid escape '\q' in character literal on line 1`}},
+		{"'a\nb", []string{`Illegal "'a"`, `Newline "\n"`, `Identifier "b"`, `EOF ""`}, []string{"unterminated character literal on line 1"}},
+		// A single quote no longer opens a string.
+		{"'it'", []string{`Illegal "'it'"`, `EOF ""`}, []string{"character literal 'it' has more than one character on line 1"}},
+		// and so is not mismatched inside a double-quoted one.
+		{`"it's"`, []string{`String "it's"`, `EOF ""`}, nil},
+		{`"it's`, []string{`Illegal "\"it's"`, `EOF ""`}, []string{"unterminated string starting on line 1"}},
+	})
+}
+
+// describeTrivia describes tokens like describe, followed by the trivia
+// around each one in brackets.
+func describeTrivia(tokens []Token) []string {
+	list := describe(tokens)
+	for i, token := range tokens {
+		if len(token.LeadingTrivia) > 0 {
+			list[i] = fmt.Sprintf("%q %s", describe(token.LeadingTrivia), list[i])
+		}
+		if len(token.TrailingTrivia) > 0 {
+			list[i] = fmt.Sprintf("%s %q", list[i], describe(token.TrailingTrivia))
+		}
+	}
+	return list
+}
+
+func TestAttachTrivia(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"let x = 1 // trailing\n// leading\nlet y = 2", []string{
+			`Let "let"`, `Identifier "x"`, `Assign "="`, `Number "1" ["Comment \"// trailing\""]`, `Newline "\n"`,
+			`["Comment \"// leading\"" "Newline \"\\n\""] Let "let"`, `Identifier "y"`, `Assign "="`, `Number "2"`, `EOF ""`}},
+		// Blank lines after the first line break lead the next token.
+		{"a\n\n\nb", []string{`Identifier "a"`, `Newline "\n"`, `["Newline \"\\n\"" "Newline \"\\n\""] Identifier "b"`, `EOF ""`}},
+		{"/* a */ b /* c */ /* d */", []string{`["Comment \"/* a */\""] Identifier "b" ["Comment \"/* c */\"" "Comment \"/* d */\""]`, `EOF ""`}},
+		// The line break after a line holding only a comment is trivia too.
+		{"/// doc\nfn", []string{`["DocComment \"/// doc\"" "Newline \"\\n\""] Fn "fn"`, `EOF ""`}},
+		{"a\n// last", []string{`Identifier "a"`, `Newline "\n"`, `["Comment \"// last\""] EOF ""`}},
+		{"a /* spans\nlines */ b", []string{`Identifier "a" ["Comment \"/* spans\\nlines */\""]`, `Identifier "b"`, `EOF ""`}},
+	}
+	options := DefaultOptions()
+	options.AttachTrivia = true
+	for _, test := range tests {
+		if got := describeTrivia(scanWith(t, test.source, options)); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestAttachTriviaTokens(t *testing.T) {
+	source := "let x = 1 // trailing\n// leading\nlet y = 2 /* a */ /* b */\n\n\n/* c */ z // d\n// e"
+	options := DefaultOptions()
+	options.AttachTrivia = true
+	want := scanWith(t, source, options)
+
+	scanner := NewScannerWithOptions(source, options)
+	got := slices.Collect(scanner.Tokens())
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens() = %q, want %q", describeTrivia(got), describeTrivia(want))
+	}
+
+	scanner = NewScannerWithOptions(source, options)
+	var next []Token
+	for {
+		token, err := scanner.NextToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		next = append(next, token)
+		if token.Type == EOF {
+			break
+		}
+	}
+	if !reflect.DeepEqual(next, want) {
+		t.Errorf("NextToken() = %q, want %q", describeTrivia(next), describeTrivia(want))
+	}
+
+	// Peeking ahead does not change what is attached either.
+	scanner = NewScannerWithOptions(source, options)
+	for i := range want {
+		if got := scanner.Peek(i); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("Peek(%d) = %q, want %q", i, describeTrivia([]Token{got}), describeTrivia(want[i:i+1]))
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	testScans(t, []scanTest{
+		{`"a b"`, []string{`String "a b"`, `EOF ""`}, nil},
+		{`""`, []string{`String ""`, `EOF ""`}, nil},
+		{"`a b`", []string{`RawString "a b"`, `EOF ""`}, nil},
+		// Each quote may appear inside a string opened by another.
+		{"\"it's `raw`\"", []string{"String \"it's `raw`\"", `EOF ""`}, nil},
+		{"`say \"hi\" it's`", []string{"RawString \"say \\\"hi\\\" it's\"", `EOF ""`}, nil},
+		{`"a" "b"`, []string{`String "a"`, `String "b"`, `EOF ""`}, nil},
+		{"\"a\nb\"", []string{`String "a\nb"`, `EOF ""`}, []string{"line break in string starting on line 1"}},
+		{`"a`, []string{`Illegal "\"a"`, `EOF ""`}, []string{"unterminated string starting on line 1"}},
+		{"`a", []string{"Illegal \"`a\"", `EOF ""`}, []string{"unterminated raw string starting on line 1"}},
+		{"\"a `b\"` c", []string{"String \"a `b\"", "Illegal \"` c\"", `EOF ""`}, []string{"unterminated raw string starting on line 1"}},
+	})
+}
+
+func TestReset(t *testing.T) {
+	sources := []string{
+		"let a = \"x ${b + {c: 1}[c]} y\" // one\n\tif a { b }\n",
+		"fn f() {\n  return 1.2.3 @\n}",
+		"\"unterminated ${",
+		"",
+		"/* a */ x\n\n\n  y // z\r\n",
+	}
+	configure := map[string]func(*ScannerOptions){
+		"default":           func(*ScannerOptions) {},
+		"indentation":       func(o *ScannerOptions) { o.Indentation = true },
+		"attach trivia":     func(o *ScannerOptions) { o.AttachTrivia = true },
+		"insert semicolons": func(o *ScannerOptions) { o.Newlines = InsertSemicolons },
+		"final newline":     func(o *ScannerOptions) { o.FinalNewline = true },
+		"max errors":        func(o *ScannerOptions) { o.MaxErrors = 1 },
+	}
+	for name, set := range configure {
+		options := DefaultOptions()
+		set(&options)
+		scanner := NewScannerWithOptions("", options)
+		// Every source is scanned three times by the same scanner, after
+		// the others, and must come out as it does from a new scanner.
+		for round := range 3 {
+			for _, source := range sources {
+				fresh := NewScannerWithOptions(source, options)
+				want, wantErrors := fresh.Scan()
+				scanner.Reset(source)
+				got, errors := scanner.Scan()
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("%s, round %d: scan %q after Reset = %q, want %q", name, round, source, describe(got), describe(want))
+				}
+				if !reflect.DeepEqual(errors, wantErrors) {
+					t.Errorf("%s, round %d: scan %q after Reset errors = %q, want %q", name, round, source, messages(errors), messages(wantErrors))
+				}
+			}
+		}
+	}
+}
+
+func TestResetFromReader(t *testing.T) {
+	scanner := NewScannerFromReader(strings.NewReader("let a = 1\nb"))
+	scanner.Scan()
+	scanner.Reset("x + y")
+	got, _ := scanner.Scan()
+	want := Scan("x + y").Tokens
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("scan after Reset = %q, want %q", describe(got), describe(want))
+	}
+}
+
+func TestResetAllocations(t *testing.T) {
+	source := "let a = b + 1 // c\nif a { print(\"x ${a} y\") }\n"
+	scanner := NewScanner(source)
+	scanner.Scan()
+	allocs := testing.AllocsPerRun(100, func() {
+		scanner.Reset(source)
+		scanner.Scan()
+	})
+	if allocs > 0 {
+		t.Errorf("Reset and Scan allocate %v times, want no allocations", allocs)
+	}
+}
+
+func TestLineTerminators(t *testing.T) {
+	tests := []struct {
+		source string
+		lines  []int
+		texts  []string
+	}{
+		{"a\nb\nc", []int{1, 1, 2, 2, 3, 3}, []string{"a", "\n", "b", "\n", "c", ""}},
+		{"a\r\nb\r\nc", []int{1, 1, 2, 2, 3, 3}, []string{"a", "\r\n", "b", "\r\n", "c", ""}},
+		{"a\rb\rc", []int{1, 1, 2, 2, 3, 3}, []string{"a", "\r", "b", "\r", "c", ""}},
+		{"a\r\n\nb\r\rc", []int{1, 1, 2, 3, 3, 4, 5, 5}, []string{"a", "\r\n", "\n", "b", "\r", "\r", "c", ""}},
+		// "\n\r" is two line breaks, not one.
+		{"a\n\rb", []int{1, 1, 2, 3, 3}, []string{"a", "\n", "\r", "b", ""}},
+		{"/* a\r\nb\rc\nd */ e", []int{4, 4}, []string{"e", ""}},
+		{"`a\r\nb\rc` d", []int{1, 3, 3}, []string{"a\r\nb\rc", "d", ""}},
+	}
+	for _, test := range tests {
+		tokens := scanWith(t, test.source, DefaultOptions())
+		lines := make([]int, len(tokens))
+		texts := make([]string, len(tokens))
+		for i, token := range tokens {
+			lines[i], texts[i] = token.Line, token.Text
+		}
+		if !slices.Equal(lines, test.lines) || !slices.Equal(texts, test.texts) {
+			t.Errorf("scan %q = %q on lines %v, want %q on lines %v", test.source, texts, lines, test.texts, test.lines)
+		}
+		// Columns start over after every kind of line break.
+		for _, token := range tokens {
+			if token.Type != Newline && token.Type != EOF && token.StartOffset > 0 &&
+				isLineTerminator(rune(test.source[token.StartOffset-1])) && token.Column != 1 {
+				t.Errorf("scan %q: %s after a line break is in column %d", test.source, token, token.Column)
+			}
+		}
+	}
+}
+
+func TestTokensIterator(t *testing.T) {
+	sources := []string{
+		"",
+		"let a = 1\nprint(\"${a + 1}\") // done\n",
+		"if a {\n  b @ c\n  1.2.3\n}\n",
+		"\"open",
+	}
+	for _, source := range sources {
+		scanner := NewScanner(source)
+		want, wantErrors := scanner.Scan()
+
+		scanner = NewScanner(source)
+		got := slices.Collect(scanner.Tokens())
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Tokens() of %q = %q, want %q", source, describe(got), describe(want))
+		}
+		if !reflect.DeepEqual(scanner.errors, wantErrors) {
+			t.Errorf("Tokens() of %q errors = %q, want %q", source, messages(scanner.errors), messages(wantErrors))
+		}
+
+		reader := NewScannerFromReader(iotest.OneByteReader(strings.NewReader(source)))
+		if got := slices.Collect(reader.Tokens()); !reflect.DeepEqual(got, want) {
+			t.Errorf("Tokens() of a reader of %q = %q, want %q", source, describe(got), describe(want))
+		}
+	}
+}
+
+func TestTokensIteratorStops(t *testing.T) {
+	scanner := NewScanner("a b c d")
+	var got []Token
+	for token := range scanner.Tokens() {
+		got = append(got, token)
+		if len(got) == 2 {
+			break
+		}
+	}
+	// The scanner picks up where the loop left off.
+	got = slices.AppendSeq(got, scanner.Tokens())
+	want := Scan("a b c d").Tokens
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens() in two loops = %q, want %q", describe(got), describe(want))
+	}
+	// After EOF, it only yields EOF.
+	if rest := slices.Collect(scanner.Tokens()); len(rest) != 1 || rest[0].Type != EOF {
+		t.Errorf("Tokens() after EOF = %q, want only EOF", describe(rest))
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	testScans(t, []scanTest{
+		{"a + \\\nb", []string{`Identifier "a"`, `Plus "+"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a + \\\r\nb", []string{`Identifier "a"`, `Plus "+"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a + \\\rb", []string{`Identifier "a"`, `Plus "+"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a \\\n\\\nb\nc", []string{`Identifier "a"`, `Identifier "b"`, `Newline "\n"`, `Identifier "c"`, `EOF ""`}, nil},
+		// Only a line break right after the backslash continues the line.
+		{"a \\ \nb", []string{`Identifier "a"`, `Illegal "\\"`, `Newline "\n"`, `Identifier "b"`, `EOF ""`},
+			[]string{`Unexpected '\' not followed by a line break on line 1`}},
+		{"a \\b c", []string{`Identifier "a"`, `Illegal "\\b"`, `Identifier "c"`, `EOF ""`},
+			[]string{`Unexpected '\' not followed by a line break on line 1`}},
+	})
+
+	// The continued line counts as a new line.
+	tokens := scanWith(t, "a \\\n  b", DefaultOptions())
+	if b := tokens[1]; b.Line != 2 || b.Column != 3 {
+		t.Errorf("b after a line continuation is at %d:%d, want 2:3", b.Line, b.Column)
+	}
+}
+
+func TestLineContinuationAtEOF(t *testing.T) {
+	testScans(t, []scanTest{
+		{"a \\", []string{`Identifier "a"`, `Illegal "\\"`, `EOF ""`}, []string{"line continuation at end of file on line 1"}},
+		{"\\", []string{`Illegal "\\"`, `EOF ""`}, []string{"line continuation at end of file on line 1"}},
+		{"a\nb\\", []string{`Identifier "a"`, `Newline "\n"`, `Identifier "b"`, `Illegal "\\"`, `EOF ""`}, []string{"line continuation at end of file on line 2"}},
+		// A continuation onto an empty last line is fine.
+		{"a \\\n", []string{`Identifier "a"`, `EOF ""`}, nil},
+	})
+}
+
+func TestFinalNewline(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"a", []string{`Identifier "a"`, `Newline ""`, `EOF ""`}},
+		{"a\n", []string{`Identifier "a"`, `Newline "\n"`, `EOF ""`}},
+		{"a\r\n", []string{`Identifier "a"`, `Newline "\r\n"`, `EOF ""`}},
+		{"a\r", []string{`Identifier "a"`, `Newline "\r"`, `EOF ""`}},
+		{"", []string{`EOF ""`}},
+		{"a // b", []string{`Identifier "a"`, `Newline ""`, `EOF ""`}},
+	}
+	options := DefaultOptions()
+	options.FinalNewline = true
+	for _, test := range tests {
+		if got := describe(scanWith(t, test.source, options)); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q with FinalNewline = %q, want %q", test.source, got, test.want)
+		}
+	}
+
+	// The synthetic Newline sits at the end of the last line.
+	tokens := scanWith(t, "a\nbc", options)
+	if newline := tokens[3]; newline.Line != 2 || newline.Column != 3 || newline.StartOffset != 4 || newline.EndOffset != 4 {
+		t.Errorf("Newline at %d:%d [%d, %d), want 2:3 [4, 4)", newline.Line, newline.Column, newline.StartOffset, newline.EndOffset)
+	}
+
+	// Newlines that are suppressed are not added either.
+	options.Newlines = SuppressNewlines
+	if got := describe(scanWith(t, "a", options)); !slices.Equal(got, []string{`Identifier "a"`, `EOF ""`}) {
+		t.Errorf("scan \"a\" with FinalNewline and SuppressNewlines = %q", got)
+	}
+}
+
+func TestEOFPosition(t *testing.T) {
+	tests := []struct {
+		source       string
+		line, column int
+	}{
+		{"", 1, 1},
+		{"a", 1, 2},
+		{"a\n", 1, 2},
+		{"a\r\n", 1, 2},
+		{"a\r", 1, 2},
+		{"a\nbc", 2, 3},
+		{"a\nbc\n", 2, 3},
+		// A blank last line is a line of its own.
+		{"a\n\n", 2, 1},
+		{"\n", 1, 1},
+		{"a // b\n", 1, 7},
+	}
+	for _, test := range tests {
+		tokens := scanWith(t, test.source, DefaultOptions())
+		if eof := tokens[len(tokens)-1]; eof.Line != test.line || eof.Column != test.column || eof.StartOffset != len(test.source) {
+			t.Errorf("scan %q puts EOF at %d:%d, offset %d, want %d:%d, offset %d", test.source,
+				eof.Line, eof.Column, eof.StartOffset, test.line, test.column, len(test.source))
+		}
+	}
+}
+
+func TestInternedText(t *testing.T) {
+	source := "let value = value + other\nlet other = value"
+	for _, scanner := range []Scanner{NewScanner(source), NewScannerFromReader(iotest.OneByteReader(strings.NewReader(source)))} {
+		tokens, _ := scanner.Scan()
+		// The texts are those of a plain scan.
+		for _, token := range tokens {
+			if want := source[token.StartOffset:token.EndOffset]; token.Text != want {
+				t.Errorf("%s at offset %d, want text %q", token, token.StartOffset, want)
+			}
+		}
+		// Every spelling of a name shares one copy of its text.
+		first := make(map[string]*byte)
+		for _, token := range tokens {
+			if token.Type != Identifier && token.Type != Let {
+				continue
+			}
+			data := unsafe.StringData(token.Text)
+			if shared, ok := first[token.Text]; !ok {
+				first[token.Text] = data
+			} else if data != shared {
+				t.Errorf("%s at offset %d has its own copy of its text", token, token.StartOffset)
+			}
+		}
+	}
+}
+
+func TestTokenOffsets(t *testing.T) {
+	source := "\uFEFFlet é = [1, 2.5] ** 2 != x ?: y // c\n\"s ${a} t\" `r` 'c' 0x1F_FF |> f\r\n"
+	options := DefaultOptions()
+	options.KeepComments = true
+	tokens := scanWith(t, source, options)
+	for _, token := range tokens {
+		spelling := source[token.StartOffset:token.EndOffset]
+		switch token.Type {
+		case String, StringStart, StringMid, StringEnd, RawString, Char, Number, EOF:
+			// Their text leaves out quotes, braces and digit separators,
+			// or resolves escapes.
+		default:
+			if token.Text != spelling {
+				t.Errorf("%s spans %q", token, spelling)
+			}
+		}
+	}
+	if got := source[tokens[0].StartOffset:tokens[0].EndOffset]; got != "let" {
+		t.Errorf("first token spans %q, want \"let\" after the byte order mark", got)
+	}
+
+	// Reading the source in pieces gives the same tokens.
+	reader := NewScannerFromReaderWithOptions(iotest.HalfReader(strings.NewReader(source)), options)
+	if got, _ := reader.Scan(); !reflect.DeepEqual(got, tokens) {
+		t.Errorf("scan of a reader = %q, want %q", describe(got), describe(tokens))
+	}
+}
+
+func TestAddKeyword(t *testing.T) {
+	scanner := NewScanner("unless x { loop }")
+	scanner.AddKeyword("unless", If)
+	scanner.AddKeyword("loop", While)
+	tokens, _ := scanner.Scan()
+	want := []string{`If "unless"`, `Identifier "x"`, `LeftCurly "{"`, `While "loop"`, `RightCurly "}"`, `EOF ""`}
+	if got := describe(tokens); !slices.Equal(got, want) {
+		t.Errorf("scan with keywords added = %q, want %q", got, want)
+	}
+
+	// Other scanners and the Keywords table are unaffected.
+	if got := describe(Scan("unless").Tokens); !slices.Equal(got, []string{`Identifier "unless"`, `EOF ""`}) {
+		t.Errorf("a new scanner scans \"unless\" as %q", got)
+	}
+	if slices.Contains(Keywords(), "unless") {
+		t.Errorf("Keywords() = %q, want it without \"unless\"", Keywords())
+	}
+
+	// A built-in keyword can be turned into another one.
+	scanner = NewScanner("fn")
+	scanner.AddKeyword("fn", Identifier)
+	if tokens, _ := scanner.Scan(); tokens[0].Type != Identifier {
+		t.Errorf("fn after AddKeyword(\"fn\", Identifier) scans as %s", tokens[0])
+	}
+}
+
+func TestBooleanLiterals(t *testing.T) {
+	tests := []struct {
+		name          string
+		trues, falses []string
+		source        string
+		want          []Type
+	}{
+		{"default", nil, nil, "true false yes", []Type{True, False, Identifier, EOF}},
+		{"replaced", []string{"yes", "on"}, []string{"no", "off"}, "yes on no off true false",
+			[]Type{True, True, False, False, Identifier, Identifier, EOF}},
+		{"only true replaced", []string{"yes"}, nil, "yes true false", []Type{True, Identifier, False, EOF}},
+		{"none", []string{}, []string{}, "true false", []Type{Identifier, Identifier, EOF}},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.TrueLiterals = test.trues
+		options.FalseLiterals = test.falses
+		if got := types(scanWith(t, test.source, options)); !slices.Equal(got, test.want) {
+			t.Errorf("%s: scan %q = %v, want %v", test.name, test.source, got, test.want)
+		}
+	}
+	if got := types(Scan("yes").Tokens); got[0] != Identifier {
+		t.Errorf("changing the literals of one scanner changed the default keywords")
+	}
+}
+
+func TestColonColon(t *testing.T) {
+	testScans(t, []scanTest{
+		{"std::io::read", []string{`Identifier "std"`, `ColonColon "::"`, `Identifier "io"`, `ColonColon "::"`, `Identifier "read"`, `EOF ""`}, nil},
+		{"a: b", []string{`Identifier "a"`, `Colon ":"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a : : b", []string{`Identifier "a"`, `Colon ":"`, `Colon ":"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a:::b", []string{`Identifier "a"`, `ColonColon "::"`, `Colon ":"`, `Identifier "b"`, `EOF ""`}, nil},
+	})
+}
+
+func TestStarStar(t *testing.T) {
+	testScans(t, []scanTest{
+		{"2 ** 10", []string{`Number "2"`, `StarStar "**"`, `Number "10"`, `EOF ""`}, nil},
+		{"a * *b", []string{`Identifier "a"`, `Star "*"`, `Star "*"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a***b", []string{`Identifier "a"`, `StarStar "**"`, `Star "*"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a **= b", []string{`Identifier "a"`, `StarStar "**"`, `Assign "="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a *= b", []string{`Identifier "a"`, `StarAssign "*="`, `Identifier "b"`, `EOF ""`}, nil},
+	})
+}
+
+func TestQuestion(t *testing.T) {
+	testScans(t, []scanTest{
+		{"int?", []string{`Int "int"`, `Question "?"`, `EOF ""`}, nil},
+		{"let a: int? = b", []string{`Let "let"`, `Identifier "a"`, `Colon ":"`, `Int "int"`, `Question "?"`, `Assign "="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a ?? b", []string{`Identifier "a"`, `QuestionQuestion "??"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a ? ? b", []string{`Identifier "a"`, `Question "?"`, `Question "?"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a???b", []string{`Identifier "a"`, `QuestionQuestion "??"`, `Question "?"`, `Identifier "b"`, `EOF ""`}, nil},
+	})
+}
+
+func TestElvis(t *testing.T) {
+	testScans(t, []scanTest{
+		{"a ?: b", []string{`Identifier "a"`, `Elvis "?:"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a ? b : c", []string{`Identifier "a"`, `Question "?"`, `Identifier "b"`, `Colon ":"`, `Identifier "c"`, `EOF ""`}, nil},
+		{"a?.b", []string{`Identifier "a"`, `Question "?"`, `Dot "."`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a ? : b", []string{`Identifier "a"`, `Question "?"`, `Colon ":"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a?::b", []string{`Identifier "a"`, `Elvis "?:"`, `Colon ":"`, `Identifier "b"`, `EOF ""`}, nil},
+	})
+}
+
+func TestArrow(t *testing.T) {
+	testScans(t, []scanTest{
+		{"-> int", []string{`Arrow "->"`, `Int "int"`, `EOF ""`}, nil},
+		{"a - > b", []string{`Identifier "a"`, `Minus "-"`, `RightAngle ">"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a->b", []string{`Identifier "a"`, `Arrow "->"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a -->b", []string{`Identifier "a"`, `MinusMinus "--"`, `RightAngle ">"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a ->= b", []string{`Identifier "a"`, `Arrow "->"`, `Assign "="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a -= b", []string{`Identifier "a"`, `MinusAssign "-="`, `Identifier "b"`, `EOF ""`}, nil},
+	})
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []Type
+	}{
+		{"a && b", []Type{Identifier, And, Identifier, EOF}},
+		{"a & b", []Type{Identifier, Ampersand, Identifier, EOF}},
+		{"a & & b", []Type{Identifier, Ampersand, Ampersand, Identifier, EOF}},
+		{"a &&& b", []Type{Identifier, And, Ampersand, Identifier, EOF}},
+		{"a || b", []Type{Identifier, Or, Identifier, EOF}},
+		{"a | | b", []Type{Identifier, Pipe, Pipe, Identifier, EOF}},
+		{"a % b", []Type{Identifier, Percent, Identifier, EOF}},
+		{"a %% b", []Type{Identifier, Percent, Percent, Identifier, EOF}},
+		{"a ^ b", []Type{Identifier, Caret, Identifier, EOF}},
+		{"a ^^ b", []Type{Identifier, Caret, Caret, Identifier, EOF}},
+		{"a -> b", []Type{Identifier, Arrow, Identifier, EOF}},
+		{"!a && !b", []Type{Bang, Identifier, And, Bang, Identifier, EOF}},
+		{"a&&b||c", []Type{Identifier, And, Identifier, Or, Identifier, EOF}},
+		{"a != b", []Type{Identifier, NotEquals, Identifier, EOF}},
+	}
+	for _, test := range tests {
+		if got := types(scanWith(t, test.source, DefaultOptions())); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q = %v, want %v", test.source, got, test.want)
+		}
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	testScans(t, []scanTest{
+		{"`a\\nb`", []string{"RawString \"a\\\\nb\"", `EOF ""`}, nil},
+		{"`${a}`", []string{"RawString \"${a}\"", `EOF ""`}, nil},
+		{"`a\nb` c", []string{"RawString \"a\\nb\"", `Identifier "c"`, `EOF ""`}, nil},
+		{"``", []string{"RawString \"\"", `EOF ""`}, nil},
+		{"`a", []string{"Illegal \"`a\"", `EOF ""`}, []string{"unterminated raw string starting on line 1"}},
+	})
+
+	// The literal is on the line it starts on, and what follows it on the
+	// lines it spans.
+	tokens := scanWith(t, "x\n`a\r\nb\rc` d", DefaultOptions())
+	if raw, d := tokens[2], tokens[3]; raw.Line != 2 || d.Line != 4 || d.Column != 4 {
+		t.Errorf("raw string on line %d and d at %d:%d, want line 2 and 4:4", raw.Line, d.Line, d.Column)
+	}
+}
+
+func TestSynchronize(t *testing.T) {
+	// After a stray comment end or backslash, the rest of the word is
+	// skipped, up to whitespace or a line break.
+	testScans(t, []scanTest{
+		{"a */b+c d", []string{`Identifier "a"`, `Illegal "*/b+c"`, `Identifier "d"`, `EOF ""`}, []string{"Unexpected comment ending on line 1"}},
+		{"*/x\ny", []string{`Illegal "*/x"`, `Newline "\n"`, `Identifier "y"`, `EOF ""`}, []string{"Unexpected comment ending on line 1"}},
+		{"*/\ty", []string{`Illegal "*/"`, `Identifier "y"`, `EOF ""`}, []string{"Unexpected comment ending on line 1"}},
+		{"a \\x\r\ny", []string{`Identifier "a"`, `Illegal "\\x"`, `Newline "\r\n"`, `Identifier "y"`, `EOF ""`},
+			[]string{`Unexpected '\' not followed by a line break on line 1`}},
+		// Each word gets its own error.
+		{"*/a */b", []string{`Illegal "*/a"`, `Illegal "*/b"`, `EOF ""`}, []string{"Unexpected comment ending on line 1", "Unexpected comment ending on line 1"}},
+	})
+}
+
+func TestIsTrivia(t *testing.T) {
+	trivia := map[Type]bool{Newline: true, Comment: true, DocComment: true}
+	for typ := range Type(len(typeNames)) {
+		token := Token{Type: typ}
+		if got := token.IsTrivia(); got != trivia[typ] {
+			t.Errorf("%s.IsTrivia() = %t, want %t", typ, got, trivia[typ])
+		}
+	}
+}
+
+func TestShebang(t *testing.T) {
+	testScans(t, []scanTest{
+		{"#!/usr/bin/env lol\nprint(1)", []string{`Newline "\n"`, `Identifier "print"`, `LeftParen "("`, `Number "1"`, `RightParen ")"`, `EOF ""`}, nil},
+		{"#!lol", []string{`EOF ""`}, nil},
+		{"\uFEFF#!lol\r\na", []string{`Newline "\r\n"`, `Identifier "a"`, `EOF ""`}, nil},
+		// Anywhere but at the very start, '#' is illegal.
+		{" #!lol", []string{`Illegal "#"`, `Bang "!"`, `Identifier "lol"`, `EOF ""`}, []string{"Unexpected character '#' on line 1"}},
+		{"a\n#!lol", []string{`Identifier "a"`, `Newline "\n"`, `Illegal "#"`, `Bang "!"`, `Identifier "lol"`, `EOF ""`}, []string{"Unexpected character '#' on line 2"}},
+		{"#lol", []string{`Illegal "#"`, `Identifier "lol"`, `EOF ""`}, []string{"Unexpected character '#' on line 1"}},
+	})
+
+	// With comments kept, the shebang is a comment on line 1.
+	options := DefaultOptions()
+	options.KeepComments = true
+	got := describe(scanWith(t, "#!/bin/lol\nx", options))
+	want := []string{`Comment "#!/bin/lol"`, `Newline "\n"`, `Identifier "x"`, `EOF ""`}
+	if !slices.Equal(got, want) {
+		t.Errorf("scan with KeepComments = %q, want %q", got, want)
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	testScans(t, []scanTest{
+		{"let café = 1", []string{`Let "let"`, `Identifier "café"`, `Assign "="`, `Number "1"`, `EOF ""`}, nil},
+		{"π × r", []string{`Identifier "π"`, `Illegal "×"`, `Identifier "r"`, `EOF ""`}, []string{"Unexpected character '×' on line 1"}},
+		{"日本語 Straße", []string{`Identifier "日本語"`, `Identifier "Straße"`, `EOF ""`}, nil},
+		// Digits of other scripts may follow a letter but not start a name.
+		{"x٣", []string{`Identifier "x٣"`, `EOF ""`}, nil},
+		{"٣x", []string{`Illegal "٣"`, `Identifier "x"`, `EOF ""`}, []string{"Unexpected character '٣' on line 1"}},
+		{"a😀b", []string{`Identifier "a"`, `Illegal "😀"`, `Identifier "b"`, `EOF ""`}, []string{"Unexpected character '😀' on line 1"}},
+		{"a\xffb", []string{`Identifier "a"`, `Illegal "\xff"`, `Identifier "b"`, `EOF ""`}, []string{"invalid UTF-8 encoding on line 1"}},
+	})
+
+	// Columns count characters, not bytes.
+	tokens := scanWith(t, "é ü x", DefaultOptions())
+	if x := tokens[2]; x.Column != 5 || x.StartOffset != 6 {
+		t.Errorf("x at column %d, offset %d, want column 5, offset 6", x.Column, x.StartOffset)
+	}
+}
+
+func TestNextToken(t *testing.T) {
+	scanner := NewScanner("a @\nb\n1.2.3 $ c")
+	var got []string
+	for {
+		token, err := scanner.NextToken()
+		entry := token.String()
+		if err != nil {
+			entry += " / " + strings.ReplaceAll(err.Error(), "\n", " / ")
+		}
+		got = append(got, entry)
+		if token.Type == EOF {
+			break
+		}
+	}
+	// Each error comes with the token that was scanned when it was found.
+	want := []string{
+		`Identifier "a"`,
+		`Illegal "@" / Unexpected character '@' on line 1`,
+		`Newline "\n"`,
+		`Identifier "b"`,
+		`Newline "\n"`,
+		`Illegal "1.2.3" / malformed number '1.2.3' on line 3`,
+		`Illegal "$" / Unexpected character '$' on line 3`,
+		`Identifier "c"`,
+		`EOF ""`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("NextToken = %q, want %q", got, want)
+	}
+	if token, err := scanner.NextToken(); token.Type != EOF || err != nil {
+		t.Errorf("NextToken after EOF = %s, %v, want EOF, nil", token, err)
+	}
+
+	// The error holds the structured errors.
+	scanner = NewScanner("@")
+	_, err := scanner.NextToken()
+	var scanErr Error
+	if !errors.As(err, &scanErr) || scanErr.Code != ErrUnexpected || scanErr.Column != 1 {
+		t.Errorf("NextToken error = %#v, want an Unexpected Error at column 1", err)
+	}
+}
+
+func TestBlockComments(t *testing.T) {
+	testScans(t, []scanTest{
+		{"a /* b */ c", []string{`Identifier "a"`, `Identifier "c"`, `EOF ""`}, nil},
+		{"a /* b /* c */ d */ e", []string{`Identifier "a"`, `Identifier "e"`, `EOF ""`}, nil},
+		{"/* /* /* */ */ */ x", []string{`Identifier "x"`, `EOF ""`}, nil},
+		{"/* a\n/* b\n*/ c */\nx", []string{`Newline "\n"`, `Identifier "x"`, `EOF ""`}, nil},
+		// A comment closed once too often leaves a stray "*/".
+		{"/* a */ */ x", []string{`Illegal "*/"`, `Identifier "x"`, `EOF ""`}, []string{"Unexpected comment ending on line 1"}},
+		{"/* a", []string{`EOF ""`}, []string{"unterminated block comment starting on line 1"}},
+		{"x\n/* a /* b */\n", []string{`Identifier "x"`, `Newline "\n"`, `EOF ""`}, []string{"unterminated block comment starting on line 2"}},
+	})
+
+	options := DefaultOptions()
+	options.KeepComments = true
+	tokens := scanWith(t, "/* a /* b */ c */ x", options)
+	want := []string{`Comment "/* a /* b */ c */"`, `Identifier "x"`, `EOF ""`}
+	if got := describe(tokens); !slices.Equal(got, want) {
+		t.Errorf("scan with KeepComments = %q, want %q", got, want)
+	}
+}
+
+// describeNumbers describes the Number tokens like describe, followed by
+// their base and kind.
+func describeNumbers(tokens []Token) []string {
+	var list []string
+	for _, token := range tokens {
+		if token.Type == Number {
+			list = append(list, fmt.Sprintf("%q %d %s", token.Text, token.Base, token.Kind))
+		}
+	}
+	return list
+}
+
+func TestRadixNumbers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"0x1F", []string{`"0x1F" 16 Int`}},
+		{"0XfF 0o17 0O7 0b101 0B1", []string{`"0XfF" 16 Int`, `"0o17" 8 Int`, `"0O7" 8 Int`, `"0b101" 2 Int`, `"0B1" 2 Int`}},
+		{"0 10 0.5", []string{`"0" 10 Int`, `"10" 10 Int`, `"0.5" 10 Double`}},
+		// Only a leading '0' selects a base.
+		{"10x", []string{`"10" 10 Int`}},
+	}
+	for _, test := range tests {
+		if got := describeNumbers(Scan(test.source).Tokens); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.want)
+		}
+	}
+
+	testScans(t, []scanTest{
+		{"0x", []string{`Illegal "0x"`, `EOF ""`}, []string{"malformed number '0x' on line 1"}},
+		{"0xZZ + 1", []string{`Illegal "0xZZ"`, `Plus "+"`, `Number "1"`, `EOF ""`}, []string{"malformed number '0xZZ' on line 1"}},
+		{"0o8", []string{`Illegal "0o8"`, `EOF ""`}, []string{"malformed number '0o8' on line 1"}},
+		{"0b102", []string{`Illegal "0b102"`, `EOF ""`}, []string{"malformed number '0b102' on line 1"}},
+		// A radix literal has no fractional part.
+		{"0x1.5", []string{`Number "0x1"`, `Dot "."`, `Number "5"`, `EOF ""`}, nil},
+	})
+}
+
+func TestNumberKinds(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"1e10 1E10 1.5e-3 2e+4", []string{`"1e10" 10 Double`, `"1E10" 10 Double`, `"1.5e-3" 10 Double`, `"2e+4" 10 Double`}},
+		{"1i 1f 1d", []string{`"1" 10 Int`, `"1" 10 Float`, `"1" 10 Double`}},
+		{"1.5f 1.5d 1e3f", []string{`"1.5" 10 Float`, `"1.5" 10 Double`, `"1e3" 10 Float`}},
+		// An 'e' without digits is not an exponent.
+		{"1e", []string{`"1" 10 Int`}},
+		{"1e+", []string{`"1" 10 Int`}},
+		// A letter after the suffix makes it part of a name instead.
+		{"1id", []string{`"1" 10 Int`}},
+	}
+	for _, test := range tests {
+		if got := describeNumbers(Scan(test.source).Tokens); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.want)
+		}
+	}
+
+	testScans(t, []scanTest{
+		{"1e", []string{`Number "1"`, `Identifier "e"`, `EOF ""`}, nil},
+		{"1e+", []string{`Number "1"`, `Identifier "e"`, `Plus "+"`, `EOF ""`}, nil},
+		{"1id", []string{`Number "1"`, `Identifier "id"`, `EOF ""`}, nil},
+		{"1.5i", []string{`Illegal "1.5i"`, `EOF ""`}, []string{"invalid suffix 'i' on number '1.5' on line 1"}},
+		{"1e3i", []string{`Illegal "1e3i"`, `EOF ""`}, []string{"invalid suffix 'i' on number '1e3' on line 1"}},
+	})
+}
+
+func TestDigitSeparators(t *testing.T) {
+	testScans(t, []scanTest{
+		{"1_000_000", []string{`Number "1000000"`, `EOF ""`}, nil},
+		{"1_0.2_5e1_0", []string{`Number "10.25e10"`, `EOF ""`}, nil},
+		{"0xFF_FF 0b1010_1010 0o7_7", []string{`Number "0xFFFF"`, `Number "0b10101010"`, `Number "0o77"`, `EOF ""`}, nil},
+		// A leading '_' starts a name.
+		{"_1", []string{`Identifier "_1"`, `EOF ""`}, nil},
+		{"1__0", []string{`Illegal "1__0"`, `EOF ""`}, []string{"misplaced '_' in number '1__0' on line 1"}},
+		{"1_", []string{`Illegal "1_"`, `EOF ""`}, []string{"misplaced '_' in number '1_' on line 1"}},
+		{"1_.5", []string{`Illegal "1_.5"`, `EOF ""`}, []string{"misplaced '_' in number '1_.5' on line 1"}},
+		{"0x_f", []string{`Illegal "0x_f"`, `EOF ""`}, []string{"misplaced '_' in number '0x_f' on line 1"}},
+		{"0b1_", []string{`Illegal "0b1_"`, `EOF ""`}, []string{"misplaced '_' in number '0b1_' on line 1"}},
+	})
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	testScans(t, []scanTest{
+		{"a += 1", []string{`Identifier "a"`, `PlusAssign "+="`, `Number "1"`, `EOF ""`}, nil},
+		{"a -= 1 a *= 2 a /= 3", []string{`Identifier "a"`, `MinusAssign "-="`, `Number "1"`, `Identifier "a"`, `StarAssign "*="`, `Number "2"`, `Identifier "a"`, `SlashAssign "/="`, `Number "3"`, `EOF ""`}, nil},
+		{"i++ i--", []string{`Identifier "i"`, `PlusPlus "++"`, `Identifier "i"`, `MinusMinus "--"`, `EOF ""`}, nil},
+		// The longest operator wins, so "+++" is "++" then "+".
+		{"a+++b", []string{`Identifier "a"`, `PlusPlus "++"`, `Plus "+"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a---b", []string{`Identifier "a"`, `MinusMinus "--"`, `Minus "-"`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a + = b", []string{`Identifier "a"`, `Plus "+"`, `Assign "="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a**=b", []string{`Identifier "a"`, `StarStar "**"`, `Assign "="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a/=b//c", []string{`Identifier "a"`, `SlashAssign "/="`, `Identifier "b"`, `EOF ""`}, nil},
+		{"a->=b", []string{`Identifier "a"`, `Arrow "->"`, `Assign "="`, `Identifier "b"`, `EOF ""`}, nil},
+	})
+}
+
+func TestKeepComments(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{"a // c", []string{`Identifier "a"`, `Comment "// c"`, `EOF ""`}},
+		{"/// doc\nf", []string{`DocComment "/// doc"`, `Newline "\n"`, `Identifier "f"`, `EOF ""`}},
+		{"//// rule", []string{`Comment "//// rule"`, `EOF ""`}},
+		{"/** doc */ f", []string{`DocComment "/** doc */"`, `Identifier "f"`, `EOF ""`}},
+		{"/*** box */ /**/", []string{`Comment "/*** box */"`, `Comment "/**/"`, `EOF ""`}},
+		{"a /* b\nc */ d", []string{`Identifier "a"`, `Comment "/* b\nc */"`, `Identifier "d"`, `EOF ""`}},
+	}
+	options := DefaultOptions()
+	options.KeepComments = true
+	for _, test := range tests {
+		if got := describe(scanWith(t, test.source, options)); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q = %q, want %q", test.source, got, test.want)
+		}
+	}
+
+	// A comment spanning lines is on the line it starts on.
+	tokens := scanWith(t, "\n/* a\nb */ x", options)
+	if tokens[1].Type != Comment || tokens[1].Line != 2 || tokens[2].Line != 3 {
+		t.Errorf("scan = %v, want a comment on line 2 and x on line 3", tokens)
+	}
+
+	// Without the option comments are dropped.
+	if got := describe(Scan("/// doc\n/* c */ a // c").Tokens); !slices.Equal(got, []string{`Newline "\n"`, `Identifier "a"`, `EOF ""`}) {
+		t.Errorf("scan without KeepComments = %q", got)
+	}
+}
+
+func TestNewlinePolicies(t *testing.T) {
+	tests := []struct {
+		source   string
+		newlines NewlinePolicy
+		want     []string
+	}{
+		{"a\n\n\nb\n", KeepNewlines, []string{`Identifier "a"`, `Newline "\n"`, `Newline "\n"`, `Newline "\n"`, `Identifier "b"`, `Newline "\n"`, `EOF ""`}},
+		{"a\n\n\nb\n", SuppressNewlines, []string{`Identifier "a"`, `Identifier "b"`, `EOF ""`}},
+		{"a\n\n\nb\n", CollapseNewlines, []string{`Identifier "a"`, `Newline "\n"`, `Identifier "b"`, `Newline "\n"`, `EOF ""`}},
+		{"\n\na\r\n\r\nb", CollapseNewlines, []string{`Identifier "a"`, `Newline "\r\n"`, `Identifier "b"`, `EOF ""`}},
+		// A comment between line breaks does not split the run.
+		{"a\n// c\n\nb", CollapseNewlines, []string{`Identifier "a"`, `Newline "\n"`, `Identifier "b"`, `EOF ""`}},
+		{"a\nb", InsertSemicolons, []string{`Identifier "a"`, `SemiColon "\n"`, `Identifier "b"`, `SemiColon ""`, `EOF ""`}},
+		{"a\n\n\nb\n", InsertSemicolons, []string{`Identifier "a"`, `SemiColon "\n"`, `Identifier "b"`, `SemiColon "\n"`, `EOF ""`}},
+		{"x = 1 +\n2", InsertSemicolons, []string{`Identifier "x"`, `Assign "="`, `Number "1"`, `Plus "+"`, `Number "2"`, `SemiColon ""`, `EOF ""`}},
+		{"f(\na,\nb)\n", InsertSemicolons, []string{`Identifier "f"`, `LeftParen "("`, `Identifier "a"`, `Comma ","`, `Identifier "b"`, `RightParen ")"`, `SemiColon "\n"`, `EOF ""`}},
+		{"return\ni++\n\"s\"\n'c'\ntrue", InsertSemicolons, []string{`Return "return"`, `SemiColon "\n"`, `Identifier "i"`, `PlusPlus "++"`, `SemiColon "\n"`, `String "s"`, `SemiColon "\n"`, `Char "c"`, `SemiColon "\n"`, `True "true"`, `SemiColon ""`, `EOF ""`}},
+		{"let\nif\n", InsertSemicolons, []string{`Let "let"`, `If "if"`, `EOF ""`}},
+	}
+	for _, test := range tests {
+		options := DefaultOptions()
+		options.Newlines = test.newlines
+		if got := describe(scanWith(t, test.source, options)); !slices.Equal(got, test.want) {
+			t.Errorf("scan %q with Newlines %d = %q, want %q", test.source, test.newlines, got, test.want)
+		}
+	}
+}
+
+func TestByteOrderMark(t *testing.T) {
+	testScans(t, []scanTest{
+		{"\uFEFFa", []string{`Identifier "a"`, `EOF ""`}, nil},
+		{"\uFEFF", []string{`EOF ""`}, nil},
+		{"\uFEFF\n", []string{`Newline "\n"`, `EOF ""`}, nil},
+		{"\uFEFF#!/bin/lol\nx", []string{`Newline "\n"`, `Identifier "x"`, `EOF ""`}, nil},
+		// Only the first character may be a byte order mark.
+		{"a\uFEFF", []string{`Identifier "a"`, `Illegal "\ufeff"`, `EOF ""`}, []string{"Unexpected byte order mark on line 1"}},
+		{"\uFEFF\uFEFFa", []string{`Illegal "\ufeff"`, `Identifier "a"`, `EOF ""`}, []string{"Unexpected byte order mark on line 1"}},
+		{"\"a\uFEFF\"", []string{`String "a\ufeff"`, `EOF ""`}, nil},
+	})
+
+	// The mark takes up no column.
+	tokens := Scan("\uFEFFlet a").Tokens
+	if tokens[0].Column != 1 || tokens[1].Column != 5 || tokens[0].StartOffset != 3 {
+		t.Errorf("tokens at columns %d and %d, offset %d, want 1, 5 and 3", tokens[0].Column, tokens[1].Column, tokens[0].StartOffset)
+	}
+
+	// The reader scanner skips it too.
+	scanner := NewScannerFromReader(strings.NewReader("\uFEFFa"))
+	got, errors := scanner.Scan()
+	if want := []string{`Identifier "a"`, `EOF ""`}; !slices.Equal(describe(got), want) || len(errors) > 0 {
+		t.Errorf("scan from reader = %q, %v, want %q", describe(got), errors, want)
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		name string
+		want Type
+	}{
+		{"EOF", EOF},
+		{"LeftParen", LeftParen},
+		{"Identifier", Identifier},
+		{"QuestionQuestion", QuestionQuestion},
+		{"MinusMinus", MinusMinus},
+		{"DocComment", DocComment},
+	}
+	for _, test := range tests {
+		if got, err := ParseType(test.name); got != test.want || err != nil {
+			t.Errorf("ParseType(%q) = %s, %v, want %s", test.name, got, err, test.want)
+		}
+	}
+
+	// Every type round-trips through its name.
+	for typ := range Type(len(typeNames)) {
+		if got, err := ParseType(typ.String()); got != typ || err != nil {
+			t.Errorf("ParseType(%q) = %s, %v", typ.String(), got, err)
+		}
+	}
+
+	for _, name := range []string{"", "leftparen", "Type(3)", "Unknown"} {
+		if _, err := ParseType(name); err == nil {
+			t.Errorf("ParseType(%q) succeeded", name)
+		}
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	tests := []struct {
+		token Token
+		want  string
+	}{
+		{Token{Type: LeftParen, Text: "("}, `LeftParen "("`},
+		{Token{Type: String, Text: "a\"b"}, `String "a\"b"`},
+		{Token{Type: EOF}, `EOF ""`},
+		{Token{Type: Type(len(typeNames)), Text: "x"}, fmt.Sprintf(`Type(%d) "x"`, len(typeNames))},
+		{Token{Type: -1}, `Type(-1) ""`},
+	}
+	for _, test := range tests {
+		if got := test.token.String(); got != test.want {
+			t.Errorf("String() = %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestIllegalRuns(t *testing.T) {
+	testScans(t, []scanTest{
+		{"a @ b", []string{`Identifier "a"`, `Illegal "@"`, `Identifier "b"`, `EOF ""`}, []string{"Unexpected character '@' on line 1"}},
+		{"a @$# b", []string{`Identifier "a"`, `Illegal "@$#"`, `Identifier "b"`, `EOF ""`}, []string{"Unexpected characters '@$#' on line 1"}},
+		// A run stops at the first character that can start a token.
+		{"@@(@@)", []string{`Illegal "@@"`, `LeftParen "("`, `Illegal "@@"`, `RightParen ")"`, `EOF ""`}, []string{"Unexpected characters '@@' on line 1", "Unexpected characters '@@' on line 1"}},
+		{"@@x", []string{`Illegal "@@"`, `Identifier "x"`, `EOF ""`}, []string{"Unexpected characters '@@' on line 1"}},
+		{"@ @", []string{`Illegal "@"`, `Illegal "@"`, `EOF ""`}, []string{"Unexpected character '@' on line 1", "Unexpected character '@' on line 1"}},
+		{"@\n@", []string{`Illegal "@"`, `Newline "\n"`, `Illegal "@"`, `EOF ""`}, []string{"Unexpected character '@' on line 1", "Unexpected character '@' on line 2"}},
+		{"€€", []string{`Illegal "€€"`, `EOF ""`}, []string{"Unexpected characters '€€' on line 1"}},
+		{"a\xff\xfeb", []string{`Identifier "a"`, `Illegal "\xff\xfe"`, `Identifier "b"`, `EOF ""`}, []string{"invalid UTF-8 encoding on line 1"}},
+		{"@\xff", []string{`Illegal "@\xff"`, `EOF ""`}, []string{"invalid UTF-8 encoding on line 1"}},
+	})
+
+	// The Illegal token spans the whole run.
+	tokens := Scan("x $$$ y").Tokens
+	if token := tokens[1]; token.Column != 3 || token.StartOffset != 2 || token.EndOffset != 5 {
+		t.Errorf("%s at column %d spans %d..%d, want column 3 and 2..5", token, token.Column, token.StartOffset, token.EndOffset)
+	}
+
+	// A run counts as one error towards MaxErrors.
+	options := DefaultOptions()
+	options.MaxErrors = 2
+	scanner := NewScannerWithOptions("@@@@ a $$$$ b", options)
+	tokens, errors := scanner.Scan()
+	want := []string{`Illegal "@@@@"`, `Identifier "a"`, `Illegal "$$$$"`, `EOF ""`}
+	wantErrors := []string{
+		"Unexpected characters '@@@@' on line 1",
+		"Unexpected characters '$$$$' on line 1",
+		"too many errors, aborting on line 1",
+	}
+	if !slices.Equal(describe(tokens), want) || !slices.Equal(messages(errors), wantErrors) {
+		t.Errorf("scan with MaxErrors 2 = %q, %q, want %q, %q", describe(tokens), messages(errors), want, wantErrors)
+	}
+}
+
+func TestIllegalTokens(t *testing.T) {
+	tests := []struct {
+		source string
+		text   string
+		line   int
+	}{
+		{"a === b", "===", 1},
+		{"a ==== b", "====", 1},
+		{"1.2.3", "1.2.3", 1},
+		{"0xG", "0xG", 1},
+		{"1__2", "1__2", 1},
+		{"''", "''", 1},
+		{"'ab'", "'ab'", 1},
+		{"'a", "'a", 1},
+		{"x\n\"abc", "\"abc", 2},
+		{"`a\nb", "`a\nb", 1},
+		{"*/", "*/", 1},
+		{"\\ x", "\\", 1},
+		{"a \\", "\\", 1},
+		{"a\uFEFF", "\uFEFF", 1},
+	}
+	for _, test := range tests {
+		result := Scan(test.source)
+		if len(result.Errors) == 0 {
+			t.Errorf("scan %q reported no error", test.source)
+		}
+		var illegal []Token
+		for _, token := range result.Tokens {
+			if token.Type == Illegal {
+				illegal = append(illegal, token)
+			}
+		}
+		if len(illegal) != 1 || illegal[0].Text != test.text || illegal[0].Line != test.line {
+			t.Errorf("scan %q = %q, want one Illegal %q on line %d", test.source, describe(result.Tokens), test.text, test.line)
+		}
+	}
+}
+
+func TestInterner(t *testing.T) {
+	interner := NewInterner()
+	for i, name := range []string{"a", "b", "a", "c", "b"} {
+		want := map[string]int{"a": 0, "b": 1, "c": 2}[name]
+		if got := interner.ID(name); got != want {
+			t.Errorf("ID #%d of %q = %d, want %d", i, name, got, want)
+		}
+	}
+	if got := interner.Len(); got != 3 {
+		t.Errorf("Len() = %d, want 3", got)
+	}
+	for id, want := range []string{"a", "b", "c"} {
+		if got := interner.Name(id); got != want {
+			t.Errorf("Name(%d) = %q, want %q", id, got, want)
+		}
+	}
+	if id, ok := interner.Lookup("c"); id != 2 || !ok {
+		t.Errorf("Lookup(\"c\") = %d, %t, want 2, true", id, ok)
+	}
+	if _, ok := interner.Lookup("d"); ok || interner.Len() != 3 {
+		t.Errorf("Lookup(\"d\") found it or added it")
+	}
+
+	// Intern keeps its own copy, not the slice of the larger text it got.
+	source := "name and more"
+	name := interner.Intern(source[:4])
+	if name != "name" || unsafe.StringData(name) == unsafe.StringData(source) {
+		t.Errorf("Intern(%q) = %q sharing the source", source[:4], name)
+	}
+	if again := interner.Intern(strings.Clone("name")); unsafe.StringData(again) != unsafe.StringData(name) {
+		t.Errorf("Intern returned a second copy of %q", name)
+	}
+	if id, _ := interner.Lookup("name"); id != 3 {
+		t.Errorf("ID of %q = %d, want 3", name, id)
+	}
+}
+
+func TestSharedInterner(t *testing.T) {
+	interner := NewInterner()
+	options := DefaultOptions()
+	options.Interner = interner
+	first := scanWith(t, "let total = count", options)
+	second := scanWith(t, "total = count + extra", options)
+
+	// Both scanners share one copy of each name.
+	if unsafe.StringData(first[1].Text) != unsafe.StringData(second[0].Text) {
+		t.Errorf("the scanners have their own copies of %q", first[1].Text)
+	}
+	var names []string
+	for id := range interner.Len() {
+		names = append(names, interner.Name(id))
+	}
+	if want := []string{"let", "total", "count", "extra"}; !slices.Equal(names, want) {
+		t.Errorf("interned %q, want %q", names, want)
+	}
+}
+
+func TestInternerConcurrent(t *testing.T) {
+	interner := NewInterner()
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	ids := make([][]int, 8)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range names {
+				// Every goroutine starts at a different name.
+				ids[i] = append(ids[i], interner.ID(names[(i+j)%len(names)]))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if interner.Len() != len(names) {
+		t.Fatalf("Len() = %d, want %d", interner.Len(), len(names))
+	}
+	for i, list := range ids {
+		for j, id := range list {
+			if name := names[(i+j)%len(names)]; interner.Name(id) != name {
+				t.Errorf("goroutine %d got ID %d for %q, which names %q", i, id, name, interner.Name(id))
+			}
+		}
+	}
+}
+
+func TestScanFiles(t *testing.T) {
+	dir := t.TempDir()
+	sources := []string{"let a = 1\n", "a + b @", "", "fn f() {\n\treturn \"${a}\"\n}\n", "b"}
+	var paths []string
+	for i, source := range sources {
+		path := filepath.Join(dir, fmt.Sprintf("%d.lol", i))
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	missing := filepath.Join(dir, "missing.lol")
+	paths = slices.Insert(paths, 2, missing)
+	sources = slices.Insert(sources, 2, "")
+
+	for _, concurrency := range []int{-1, 0, 1, 3, 100} {
+		results := ScanFiles(paths, concurrency)
+		if len(results) != len(paths) {
+			t.Fatalf("concurrency %d: %d results for %d paths", concurrency, len(results), len(paths))
+		}
+		for i, result := range results {
+			if result.Path != paths[i] {
+				t.Errorf("concurrency %d: result %d is for %s, want %s", concurrency, i, result.Path, paths[i])
+			}
+			if paths[i] == missing {
+				if !errors.Is(result.Err, os.ErrNotExist) || result.Tokens != nil {
+					t.Errorf("concurrency %d: missing file gave %v and %d tokens", concurrency, result.Err, len(result.Tokens))
+				}
+				continue
+			}
+			want := Scan(sources[i])
+			if result.Err != nil || !reflect.DeepEqual(result.ScanResult, want) {
+				t.Errorf("concurrency %d: %s = %q, %q, %v, want %q, %q", concurrency, paths[i],
+					describe(result.Tokens), messages(result.Errors), result.Err, describe(want.Tokens), messages(want.Errors))
+			}
+		}
+
+		// The files share their names.
+		if a, b := results[0].Tokens[1], results[1].Tokens[0]; unsafe.StringData(a.Text) != unsafe.StringData(b.Text) {
+			t.Errorf("concurrency %d: %s in two files has two copies", concurrency, a)
+		}
+	}
+
+	if results := ScanFiles(nil, 0); len(results) != 0 {
+		t.Errorf("ScanFiles(nil) = %v", results)
+	}
+}
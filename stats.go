@@ -0,0 +1,37 @@
+package main
+
+import (
+	"lol/scan"
+	"os"
+)
+
+type Summary struct {
+	Lines  int
+	Tokens int
+	Counts map[scan.Type]int
+	Errors int
+}
+
+// Stats scans filename and summarizes its tokens. The EOF token is not
+// counted.
+func Stats(filename string) (Summary, error) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	result := scan.Scan(string(source))
+	counts := scan.TokenStats(result.Tokens)
+	summary := Summary{
+		Lines:  scan.LineCount(result.Tokens),
+		Counts: counts,
+		Errors: len(result.Errors),
+	}
+	for _, count := range counts {
+		summary.Tokens += count
+	}
+	if len(source) == 0 {
+		summary.Lines = 0
+	}
+	return summary, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"lol/scan"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   Summary
+	}{
+		{"program", "let a = 1\nlet b = a + @\n", Summary{
+			Lines:  2,
+			Tokens: 12,
+			Counts: map[scan.Type]int{
+				scan.Let: 2, scan.Identifier: 3, scan.Assign: 2, scan.Number: 1,
+				scan.Plus: 1, scan.Illegal: 1, scan.Newline: 2,
+			},
+			Errors: 1,
+		}},
+		{"empty", "", Summary{Counts: map[scan.Type]int{}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "main.lol")
+			if err := os.WriteFile(filename, []byte(test.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Stats(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Lines != test.want.Lines || got.Tokens != test.want.Tokens || got.Errors != test.want.Errors ||
+				!maps.Equal(got.Counts, test.want.Counts) {
+				t.Errorf("Stats = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStatsMissingFile(t *testing.T) {
+	if _, err := Stats(filepath.Join(t.TempDir(), "missing.lol")); err == nil {
+		t.Errorf("Stats of a missing file succeeded")
+	}
+}